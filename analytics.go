@@ -0,0 +1,673 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SizeBucket is a histogram bucket of commits by lines changed, from Min
+// to Max lines or without an upper bound when Max is -1
+type SizeBucket struct {
+	Label string `json:"label"`
+	Min   int    `json:"min"`
+	Max   int    `json:"max"`
+	Count int    `json:"count"`
+}
+
+// SizeHistogram is the JSON document of -size-histogram
+type SizeHistogram struct {
+	Query   string       `json:"query,omitempty"`
+	Commits int          `json:"commits"`
+	Buckets []SizeBucket `json:"buckets"`
+	CommandLog
+}
+
+// getCommitSizes returns the lines changed (insertions plus deletions) of each
+// commit, restricted to commits whose message matches query when it's set
+func (g *GitSearchTool) getCommitSizes(query string) (map[string]int, error) {
+	args := []string{"log", "--numstat", "--pretty=format:commit %H"}
+	if g.ignoreWhitespace {
+		args = append(args, "--ignore-all-space")
+	}
+	if query != "" {
+		args = append(args, g.logGrepArgs(query)...)
+		if !g.commitsCaseSensitive() {
+			args = append(args, "-i")
+		}
+	}
+	if g.revRange != "" {
+		args = append(args, g.revRange)
+	}
+
+	cmd := g.gitCommand(args...)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		// Without any commits there are no sizes to read
+		if g.revRange == "" && g.noCommitsYet() {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read commit sizes: %v", err)
+	}
+
+	sizes := make(map[string]int)
+	var hash string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "commit ") {
+			hash = strings.TrimPrefix(line, "commit ")
+			sizes[hash] = 0
+			continue
+		}
+
+		// numstat lines are "added<TAB>deleted<TAB>path", with "-" for binary files
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 || hash == "" {
+			continue
+		}
+		added, _ := strconv.Atoi(fields[0])
+		deleted, _ := strconv.Atoi(fields[1])
+		sizes[hash] += added + deleted
+	}
+
+	return sizes, nil
+}
+
+// commitSizeHistogram buckets commits by the number of lines they changed
+func commitSizeHistogram(sizes map[string]int) []SizeBucket {
+	buckets := []SizeBucket{
+		{Label: "0", Min: 0, Max: 0},
+		{Label: "1-10", Min: 1, Max: 10},
+		{Label: "11-100", Min: 11, Max: 100},
+		{Label: "101-1000", Min: 101, Max: 1000},
+		{Label: "1000+", Min: 1001, Max: -1},
+	}
+
+	for _, size := range sizes {
+		for i := range buckets {
+			if size >= buckets[i].Min && (buckets[i].Max < 0 || size <= buckets[i].Max) {
+				buckets[i].Count++
+				break
+			}
+		}
+	}
+
+	return buckets
+}
+
+// displayCommitSizeHistogram renders the commit size histogram as ASCII bars
+func (g *GitSearchTool) displayCommitSizeHistogram(query string) {
+	sizes, err := g.getCommitSizes(query)
+	if err != nil {
+		g.searchErrorf("Error computing commit sizes: %v", err)
+		return
+	}
+	if g.format == "json" {
+		g.writeJSON(SizeHistogram{Query: query, Commits: len(sizes), Buckets: commitSizeHistogram(sizes), CommandLog: g.commandLog()})
+		return
+	}
+
+	if query != "" {
+		fmt.Printf("\n=== Commit Size Histogram for: \"%s\" ===\n", query)
+	} else {
+		fmt.Println("\n=== Commit Size Histogram ===")
+	}
+	if len(sizes) == 0 {
+		fmt.Println("No commits found.")
+		return
+	}
+
+	buckets := commitSizeHistogram(sizes)
+	largest := 0
+	for _, bucket := range buckets {
+		if bucket.Count > largest {
+			largest = bucket.Count
+		}
+	}
+
+	const width = 40
+	for _, bucket := range buckets {
+		bar := 0
+		if largest > 0 {
+			bar = bucket.Count * width / largest
+		}
+		if bar == 0 && bucket.Count > 0 {
+			bar = 1
+		}
+		fmt.Printf("%9s | %-*s %d\n", bucket.Label, width, strings.Repeat("#", bar), bucket.Count)
+	}
+	fmt.Printf("\n%d commits, lines changed (insertions + deletions) per commit.\n", len(sizes))
+}
+
+// FileDensity is a file's match count relative to its size
+type FileDensity struct {
+	Path    string  `json:"file"`
+	Matches int     `json:"matches"`
+	Size    int64   `json:"size"`
+	Density float64 `json:"density"`
+}
+
+// FileReport is the JSON document of the file rankings of -top-files,
+// -hotspots and -recent-files
+type FileReport struct {
+	Query      string `json:"query,omitempty"`
+	Expression string `json:"expression,omitempty"`
+	Files      any    `json:"files"`
+	CommandLog
+}
+
+// writeFileReport writes a file ranking as a JSON document
+func (g *GitSearchTool) writeFileReport(query string, files any) {
+	g.writeJSON(FileReport{Query: query, Expression: g.fileExpr, Files: files, CommandLog: g.commandLog()})
+}
+
+// countFileMatches returns the number of matching lines per file
+func (g *GitSearchTool) countFileMatches(query string) (map[string]int, error) {
+	cmd := g.gitCommand(g.grepArgs(g.fileSearchOptions(query), "-c", "-z")...)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return map[string]int{}, nil
+		}
+		return nil, fmt.Errorf("failed to count file matches: %v", err)
+	}
+
+	// Each file is "path<NUL>count\n", and paths may themselves contain
+	// newlines, so every NUL separated field after the first holds a count
+	// and the path of the next file
+	counts := make(map[string]int)
+	fields := strings.Split(string(output), "\x00")
+	path := fields[0]
+	for _, field := range fields[1:] {
+		number, next, _ := strings.Cut(field, "\n")
+		if count, err := strconv.Atoi(number); err == nil {
+			_, file := g.splitRevision(path)
+			counts[file] = count
+		}
+		path = next
+	}
+
+	return counts, nil
+}
+
+// blobSizes returns the size of each file of the search path in the tree
+// of rev, listed in one git ls-tree call
+func (g *GitSearchTool) blobSizes(rev string) (map[string]int64, error) {
+	args := []string{"ls-tree", "-r", "-l", "-z", rev}
+	if specs := g.searchPathspecs(g.pathFilters); len(specs) > 0 {
+		args = append(args, "--")
+		args = append(args, specs...)
+	}
+
+	output, err := g.run(g.gitCommand(args...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file sizes at %s: %v", rev, err)
+	}
+
+	// Entries are "<mode> <type> <object> <size>\t<path>", with a size of
+	// "-" for submodules
+	sizes := make(map[string]int64)
+	for _, entry := range strings.Split(string(output), "\x00") {
+		info, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) < 4 {
+			continue
+		}
+		if size, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+			sizes[path] = size
+		}
+	}
+	return sizes, nil
+}
+
+// rankFilesByDensity orders files by matches per KB, most dense first. The
+// sizes are those of the searched files: of the blobs of the searched tree
+// with -at or in a bare repository, otherwise of the working tree files.
+func (g *GitSearchTool) rankFilesByDensity(query string, limit int) ([]FileDensity, error) {
+	counts, err := g.countFileMatches(query)
+	if err != nil {
+		return nil, err
+	}
+	var sizes map[string]int64
+	if rev := g.grepRevision(); rev != "" {
+		if sizes, err = g.blobSizes(rev); err != nil {
+			return nil, err
+		}
+	}
+
+	ranked := []FileDensity{}
+	for path, count := range counts {
+		size, ok := sizes[path]
+		if sizes == nil {
+			info, err := os.Stat(filepath.Join(g.repoPath, path))
+			if err != nil {
+				continue
+			}
+			size, ok = info.Size(), true
+		}
+		if !ok {
+			continue
+		}
+
+		// Treat tiny files as 1KB so a single hit in a one-line file doesn't
+		// drown out everything else
+		kb := math.Max(float64(size)/1024, 1)
+		ranked = append(ranked, FileDensity{
+			Path:    path,
+			Matches: count,
+			Size:    size,
+			Density: float64(count) / kb,
+		})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Density != ranked[j].Density {
+			return ranked[i].Density > ranked[j].Density
+		}
+		return ranked[i].Path < ranked[j].Path
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked, nil
+}
+
+// displayTopFiles prints the files where matches are most concentrated
+func (g *GitSearchTool) displayTopFiles(query string, limit int) {
+	ranked, err := g.rankFilesByDensity(query, limit)
+	if err != nil {
+		g.searchErrorf("Error ranking files: %v", err)
+		return
+	}
+	if g.format == "json" {
+		g.writeFileReport(query, ranked)
+		return
+	}
+
+	label := query
+	if label == "" {
+		label = g.fileExpr
+	}
+	fmt.Printf("\n=== Top Files by Match Density for: \"%s\" ===\n", label)
+	if len(ranked) == 0 {
+		fmt.Println("No matches found in tracked files.")
+		return
+	}
+
+	for i, file := range ranked {
+		fmt.Printf("%d. %s - %d matches in %d bytes (%.2f per KB)\n",
+			i+1, file.Path, file.Matches, file.Size, file.Density)
+	}
+}
+
+// FileChurn is the number of commits that touched a file
+type FileChurn struct {
+	Path    string `json:"file"`
+	Commits int    `json:"commits"`
+}
+
+// listMatchingFiles returns the files containing the query, or every tracked
+// file when the query is empty
+func (g *GitSearchTool) listMatchingFiles(query string) ([]string, error) {
+	var cmd *exec.Cmd
+	if query == "" && len(g.fileExprArgs) == 0 {
+		args := []string{"ls-files", "-z"}
+		if rev := g.grepRevision(); rev != "" {
+			args = []string{"ls-tree", "-r", "-z", "--name-only", rev}
+		}
+		if specs := g.searchPathspecs(g.pathFilters); len(specs) > 0 {
+			args = append(args, "--")
+			args = append(args, specs...)
+		}
+		cmd = g.gitCommand(args...)
+	} else {
+		cmd = g.gitCommand(g.grepArgs(g.fileSearchOptions(query), "-l", "-z")...)
+	}
+
+	output, err := g.run(cmd)
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to list files: %v", err)
+	}
+
+	// Paths are NUL terminated so that they aren't quoted
+	var files []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			_, file := g.splitRevision(path)
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// displayMatchingFiles prints the paths of the files matching a query, up
+// to -max-files of them
+func (g *GitSearchTool) displayMatchingFiles(query string) {
+	files, err := g.listMatchingFiles(query)
+	if err != nil {
+		g.searchErrorf("Error searching files: %v", err)
+		return
+	}
+	if len(files) == 0 {
+		g.decorf("No matches found in tracked files.\n")
+		return
+	}
+
+	shown := g.allowResults(min(len(files), g.maxFiles))
+	for _, file := range files[:shown] {
+		fmt.Println(g.pathPrefix + file)
+	}
+	if shown == g.maxFiles {
+		g.decorf("... (showing first %d files)\n", g.maxFiles)
+	}
+}
+
+// FileCount is the number of matching lines in a file
+type FileCount struct {
+	Path  string `json:"file"`
+	Count int    `json:"count"`
+}
+
+// fileCounts returns the files matching a query with their number of
+// matching lines, highest count first and by path for equal counts
+func (g *GitSearchTool) fileCounts(query string) ([]FileCount, error) {
+	counts, err := g.countFileMatches(query)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileCount, 0, len(counts))
+	for path, count := range counts {
+		files = append(files, FileCount{Path: path, Count: count})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Count != files[j].Count {
+			return files[i].Count > files[j].Count
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// displayFileCounts prints the number of matching lines per file, up to
+// -max-files files, like grep -c but sorted by count
+func (g *GitSearchTool) displayFileCounts(query string) {
+	files, err := g.fileCounts(query)
+	if err != nil {
+		g.searchErrorf("Error counting file matches: %v", err)
+		return
+	}
+	if len(files) == 0 {
+		g.decorf("No matches found in tracked files.\n")
+		return
+	}
+
+	shown := g.allowResults(min(len(files), g.maxFiles))
+	width := len(strconv.Itoa(files[0].Count))
+	for _, file := range files[:shown] {
+		fmt.Printf("%*d  %s%s\n", width, file.Count, g.pathPrefix, file.Path)
+	}
+	if shown == g.maxFiles {
+		g.decorf("... (showing first %d files)\n", g.maxFiles)
+	}
+}
+
+// getCommitCountsByFile counts the commits touching each path in a single
+// history walk rather than one git log per file. Renames are followed, so
+// the commits made to a file under its earlier names count for its current
+// one.
+func (g *GitSearchTool) getCommitCountsByFile() (map[string]int, error) {
+	args := []string{"log", "--name-status", "-M", "-z", "--pretty=format:"}
+	if g.revRange != "" {
+		args = append(args, g.revRange)
+	}
+
+	cmd := g.gitCommand(args...)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		// Without any commits no file has changed
+		if g.revRange == "" && g.noCommitsYet() {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to count commits by file: %v", err)
+	}
+
+	// Each change is a status field followed by its path, or by the old and
+	// new paths of a rename or copy, all NUL terminated so that paths aren't
+	// quoted. Commits are listed newest first, so a rename is seen before
+	// the commits that used the old name.
+	counts := make(map[string]int)
+	renamed := make(map[string]string)
+	current := func(path string) string {
+		if name, ok := renamed[path]; ok {
+			return name
+		}
+		return path
+	}
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i < len(fields); i++ {
+		status := fields[i]
+		if status == "" {
+			continue
+		}
+		if (status[0] == 'R' || status[0] == 'C') && i+2 < len(fields) {
+			from, to := fields[i+1], current(fields[i+2])
+			counts[to]++
+			if status[0] == 'R' {
+				renamed[from] = to
+			}
+			i += 2
+			continue
+		}
+		if i+1 < len(fields) {
+			counts[current(fields[i+1])]++
+			i++
+		}
+	}
+	return counts, nil
+}
+
+// rankHotspots orders the files matching a query by how many commits touched them
+func (g *GitSearchTool) rankHotspots(query string, limit int) ([]FileChurn, error) {
+	files, err := g.listMatchingFiles(query)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := g.getCommitCountsByFile()
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]FileChurn, 0, len(files))
+	for _, file := range files {
+		ranked = append(ranked, FileChurn{Path: file, Commits: counts[file]})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Commits != ranked[j].Commits {
+			return ranked[i].Commits > ranked[j].Commits
+		}
+		return ranked[i].Path < ranked[j].Path
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked, nil
+}
+
+// displayHotspots prints the most frequently changed files matching a query
+func (g *GitSearchTool) displayHotspots(query string, limit int) {
+	ranked, err := g.rankHotspots(query, limit)
+	if err != nil {
+		g.searchErrorf("Error ranking hotspots: %v", err)
+		return
+	}
+	if g.format == "json" {
+		g.writeFileReport(query, ranked)
+		return
+	}
+
+	switch {
+	case query != "":
+		fmt.Printf("\n=== Hotspots for: \"%s\" ===\n", query)
+	case g.fileExpr != "":
+		fmt.Printf("\n=== Hotspots for: \"%s\" ===\n", g.fileExpr)
+	default:
+		fmt.Println("\n=== Hotspots ===")
+	}
+	if len(ranked) == 0 {
+		fmt.Println("No matching files found.")
+		return
+	}
+
+	for i, file := range ranked {
+		fmt.Printf("%d. %-50s %d commits\n", i+1, file.Path, file.Commits)
+	}
+}
+
+// FileRecency is the date of the most recent commit touching a file, the
+// zero time and no Date for files never committed
+type FileRecency struct {
+	Path    string    `json:"file"`
+	Changed time.Time `json:"-"`
+	Date    string    `json:"date,omitempty"`
+}
+
+// getLastChangeDates returns the author date of the most recent commit that
+// touched each file of the search path, read in one history walk and
+// remembered for later searches
+func (g *GitSearchTool) getLastChangeDates() (map[string]time.Time, error) {
+	if g.lastChanges != nil {
+		return g.lastChanges, nil
+	}
+
+	args := []string{"log", "--name-only", "-z", "--format=%x01%aI"}
+	if g.revRange != "" {
+		args = append(args, g.revRange)
+	}
+	if specs := g.searchPathspecs(g.pathFilters); len(specs) > 0 {
+		args = append(args, "--")
+		args = append(args, specs...)
+	}
+
+	cmd := g.gitCommand(args...)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		// Without any commits no file has changed
+		if g.revRange == "" && g.noCommitsYet() {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read the last changes of files: %v", err)
+	}
+
+	// Each commit is a SOH marked date followed by the files it changed, all
+	// NUL terminated and the first file after a newline. Commits are listed
+	// newest first, so the first date seen for a file is its last change.
+	changes := make(map[string]time.Time)
+	var (
+		changed time.Time
+		header  bool
+	)
+	for _, field := range strings.Split(string(output), "\x00") {
+		if date, ok := strings.CutPrefix(field, "\x01"); ok {
+			if changed, err = time.Parse(time.RFC3339, date); err != nil {
+				return nil, fmt.Errorf("failed to parse commit date %q: %v", date, err)
+			}
+			header = true
+			continue
+		}
+		if header {
+			field, header = strings.TrimPrefix(field, "\n"), false
+		}
+		if _, seen := changes[field]; field != "" && !seen {
+			changes[field] = changed
+		}
+	}
+
+	g.lastChanges = changes
+	return changes, nil
+}
+
+// rankRecentFiles orders the files matching a query by their last change, most
+// recent first
+func (g *GitSearchTool) rankRecentFiles(query string, limit int) ([]FileRecency, error) {
+	files, err := g.listMatchingFiles(query)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := g.getLastChangeDates()
+	if err != nil {
+		return nil, err
+	}
+
+	// Files untouched within the revision range keep the zero time
+	ranked := make([]FileRecency, 0, len(files))
+	for _, file := range files {
+		recency := FileRecency{Path: file, Changed: changes[file]}
+		if !recency.Changed.IsZero() {
+			recency.Date = recency.Changed.Format(time.RFC3339)
+		}
+		ranked = append(ranked, recency)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if !ranked[i].Changed.Equal(ranked[j].Changed) {
+			return ranked[i].Changed.After(ranked[j].Changed)
+		}
+		return ranked[i].Path < ranked[j].Path
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked, nil
+}
+
+// displayRecentFiles prints the most recently changed files matching a query
+func (g *GitSearchTool) displayRecentFiles(query string, limit int) {
+	ranked, err := g.rankRecentFiles(query, limit)
+	if err != nil {
+		g.searchErrorf("Error ranking recent files: %v", err)
+		return
+	}
+	if g.format == "json" {
+		g.writeFileReport(query, ranked)
+		return
+	}
+
+	switch {
+	case query != "":
+		fmt.Printf("\n=== Recently Changed Files for: \"%s\" ===\n", query)
+	case g.fileExpr != "":
+		fmt.Printf("\n=== Recently Changed Files for: \"%s\" ===\n", g.fileExpr)
+	default:
+		fmt.Println("\n=== Recently Changed Files ===")
+	}
+	if len(ranked) == 0 {
+		fmt.Println("No matching files found.")
+		return
+	}
+
+	for i, file := range ranked {
+		date := "never committed"
+		if !file.Changed.IsZero() {
+			date = file.Changed.Format("2006-01-02")
+		}
+		fmt.Printf("%d. %-50s %s\n", i+1, file.Path, date)
+	}
+}
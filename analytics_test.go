@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIgnoreWhitespaceCommitSizes(t *testing.T) {
+	r := newTestRepo(t)
+	add := r.commit("Add total", "calc.go", "total = a + b\n")
+	reindent := r.commit("Reindent total", "calc.go", "total  =  a + b\n")
+
+	tests := []struct {
+		ignoreWhitespace bool
+		want             map[string]int
+	}{
+		{false, map[string]int{add: 1, reindent: 2}},
+		{true, map[string]int{add: 1, reindent: 0}},
+	}
+	for _, tt := range tests {
+		g := r.tool()
+		g.ignoreWhitespace = tt.ignoreWhitespace
+		sizes, err := g.getCommitSizes("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for hash, want := range tt.want {
+			if sizes[hash] != want {
+				t.Errorf("ignoreWhitespace=%v: commit %s changed %d lines, want %d", tt.ignoreWhitespace, abbreviateHash(hash), sizes[hash], want)
+			}
+		}
+	}
+}
+
+func TestFileCounts(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add files",
+		"one.go", "error\n",
+		"three.go", "error\nError\nok\nerror, error\n",
+		"b.go", "error\nerror\n",
+		"a.go", "error\nerror\n",
+		"none.go", "ok\n")
+
+	counts, err := r.tool().fileCounts("error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Matching lines are counted, not matches, and equal counts are by path
+	want := []FileCount{{"three.go", 3}, {"a.go", 2}, {"b.go", 2}, {"one.go", 1}}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("fileCounts() = %v, want %v", counts, want)
+	}
+
+	stdout, stderr, status := r.gst("-quiet", "-count", "-query", "error")
+	if want := "3  three.go\n2  a.go\n2  b.go\n1  one.go\n"; status != exitMatch || stdout != want {
+		t.Errorf("-count: exit status %d, output %q, want %q; stderr:\n%s", status, stdout, want, stderr)
+	}
+}
+
+func TestCommitCountsByFile(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add client", "client.go", "package api\n\nfunc get() {}\n", "main.go", "package main\n")
+	r.commit("Fix client", "client.go", "package api\n\nfunc get() { retry() }\n")
+	r.git("mv", "client.go", "http.go")
+	r.commit("Rename client")
+	r.commit("Tune client", "http.go", "package api\n\nfunc get() { retry(); wait() }\n")
+	r.git("mv", "http.go", "api.go")
+	r.commit("Rename client again", "main.go", "package main // api\n")
+	// A new file under an old name has a history of its own
+	r.commit("Add another client", "client.go", "package client\n")
+
+	counts, err := r.tool().getCommitCountsByFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The churn of the earlier names is merged into the current one
+	want := map[string]int{"api.go": 5, "main.go": 2, "client.go": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("getCommitCountsByFile() = %v, want %v", counts, want)
+	}
+
+	ranked, err := r.tool().rankHotspots("package", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []FileChurn{{Path: "api.go", Commits: 5}, {Path: "main.go", Commits: 2}, {Path: "client.go", Commits: 1}}; !reflect.DeepEqual(ranked, want) {
+		t.Errorf("rankHotspots() = %+v, want %+v", ranked, want)
+	}
+}
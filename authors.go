@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// authorIdentity is a distinct author name and email with their commit count
+type authorIdentity struct {
+	name    string
+	email   string
+	commits int
+}
+
+// getAuthors lists every author identity in the history with its commit count
+func (g *GitSearchTool) getAuthors() ([]authorIdentity, error) {
+	// shortlog reads from stdin unless given a revision
+	cmd := g.gitCommand("shortlog", "-sne", "HEAD")
+
+	output, err := g.run(cmd)
+	if err != nil {
+		// Without any commits there are no authors
+		if g.noCommitsYet() {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list authors: %v", err)
+	}
+
+	var authors []authorIdentity
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		// Each line is "<count><TAB><name> <<email>>"
+		count, identity, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok {
+			continue
+		}
+
+		commits, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil {
+			continue
+		}
+
+		author := authorIdentity{name: identity, commits: commits}
+		if i := strings.LastIndex(identity, " <"); i >= 0 && strings.HasSuffix(identity, ">") {
+			author.name = identity[:i]
+			author.email = identity[i+2 : len(identity)-1]
+		}
+		authors = append(authors, author)
+	}
+
+	return authors, nil
+}
+
+// AuthorCount is an author identity and their number of commits in JSON
+// output
+type AuthorCount struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Commits int    `json:"commits"`
+}
+
+// AuthorSearch is the JSON document of -search-authors
+type AuthorSearch struct {
+	Query   string        `json:"query"`
+	Authors []AuthorCount `json:"authors"`
+	CommandLog
+}
+
+// searchAuthors returns the author identities whose name or email contains the query
+func (g *GitSearchTool) searchAuthors(query string) ([]authorIdentity, error) {
+	authors, err := g.getAuthors()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []authorIdentity
+	for _, author := range authors {
+		if containsFold(author.name, query) || containsFold(author.email, query) {
+			matches = append(matches, author)
+		}
+	}
+
+	return matches, nil
+}
+
+// displayAuthorSearch prints the author identities matching a query
+func (g *GitSearchTool) displayAuthorSearch(query string) {
+	authors, err := g.searchAuthors(query)
+	if err != nil {
+		g.searchErrorf("Error searching authors: %v", err)
+		return
+	}
+	if g.format == "json" {
+		search := AuthorSearch{Query: query, Authors: []AuthorCount{}, CommandLog: g.commandLog()}
+		for _, author := range authors {
+			search.Authors = append(search.Authors, AuthorCount{Name: author.name, Email: author.email, Commits: author.commits})
+		}
+		g.writeJSON(search)
+		return
+	}
+
+	fmt.Printf("\n=== Authors Matching: \"%s\" ===\n", query)
+	if len(authors) == 0 {
+		fmt.Println("No matching authors found.")
+		return
+	}
+
+	for i, author := range authors {
+		fmt.Printf("%d. %s <%s> (%d commits)\n", i+1, author.name, author.email, author.commits)
+	}
+}
+
+// countCommitsByAuthor groups commits by author name and email, so that
+// two people sharing a name are counted apart, most commits first and by
+// name and email for equal counts
+func countCommitsByAuthor(commits []CommitMatch) []authorIdentity {
+	counts := make(map[authorIdentity]int)
+	for _, commit := range commits {
+		counts[authorIdentity{name: commit.Author, email: commit.Email}]++
+	}
+
+	authors := make([]authorIdentity, 0, len(counts))
+	for author, count := range counts {
+		author.commits = count
+		authors = append(authors, author)
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		a, b := authors[i], authors[j]
+		if a.commits != b.commits {
+			return a.commits > b.commits
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		return a.email < b.email
+	})
+	return authors
+}
+
+// displayCommitsByAuthor prints how many of the commits matching a query
+// each author made. Every matching commit is counted, not only the first
+// -max-commits.
+func (g *GitSearchTool) displayCommitsByAuthor(query string) {
+	fmt.Printf("\n=== Commits Matching \"%s\" by Author ===\n", query)
+
+	opts := g.searchOptions(query)
+	opts.MaxResults = math.MaxInt32
+	commits, err := g.searchInCommitHistory(opts)
+	if err != nil {
+		g.searchErrorf("Error searching commits: %v", err)
+		return
+	}
+	if len(commits) == 0 {
+		fmt.Println("No matching commits found.")
+		return
+	}
+
+	authors := countCommitsByAuthor(commits)
+	width := len(strconv.Itoa(authors[0].commits))
+	for _, author := range authors {
+		fmt.Printf("%*d  %s <%s>\n", width, author.commits, author.name, author.email)
+	}
+	fmt.Printf("\n%d commits by %d authors.\n", len(commits), len(authors))
+}
+
+// coAuthors returns the people credited by the Co-authored-by trailers of a
+// commit message body
+func coAuthors(body string) []authorIdentity {
+	var authors []authorIdentity
+	for _, line := range strings.Split(body, "\n") {
+		key, identity, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || !strings.EqualFold(key, "Co-authored-by") {
+			continue
+		}
+
+		identity = strings.TrimSpace(identity)
+		author := authorIdentity{name: identity}
+		if i := strings.LastIndex(identity, "<"); i >= 0 && strings.HasSuffix(identity, ">") {
+			author.name = strings.TrimSpace(identity[:i])
+			author.email = identity[i+1 : len(identity)-1]
+		}
+		authors = append(authors, author)
+	}
+	return authors
+}
+
+// countCoAuthors counts the commits crediting each co-author, most first.
+// Co-authors are told apart by their email regardless of case, or by name
+// when there is none, and named as in the newest of the commits.
+func countCoAuthors(commits []CommitMatch) []authorIdentity {
+	var authors []authorIdentity
+	index := make(map[string]int)
+	for _, commit := range commits {
+		credited := make(map[string]bool)
+		for _, author := range coAuthors(commit.Body) {
+			key := strings.ToLower(author.email)
+			if key == "" {
+				key = normalizeName(author.name)
+			}
+			// A co-author listed twice in one commit counts once
+			if credited[key] {
+				continue
+			}
+			credited[key] = true
+
+			i, ok := index[key]
+			if !ok {
+				i = len(authors)
+				index[key] = i
+				authors = append(authors, author)
+			}
+			authors[i].commits++
+		}
+	}
+
+	sort.SliceStable(authors, func(i, j int) bool {
+		return authors[i].commits > authors[j].commits
+	})
+	return authors
+}
+
+// displayCoAuthors prints how many commits credit each co-author, of the
+// commits whose message matches query or of the whole searched history when
+// query is empty
+func (g *GitSearchTool) displayCoAuthors(query string) {
+	opts := g.searchOptions(query)
+	opts.MaxResults = math.MaxInt32
+	var (
+		commits []CommitMatch
+		err     error
+	)
+	if query != "" {
+		fmt.Printf("\n=== Co-authors of Commits Matching \"%s\" ===\n", query)
+		commits, err = g.searchInCommitHistory(opts)
+	} else {
+		fmt.Println("\n=== Co-authors ===")
+		commits, err = g.logCommits(nil, opts, nil)
+	}
+	if err != nil {
+		g.searchErrorf("Error searching commits: %v", err)
+		return
+	}
+
+	authors := countCoAuthors(commits)
+	if len(authors) == 0 {
+		fmt.Println("No Co-authored-by trailers found.")
+		return
+	}
+	width := len(strconv.Itoa(authors[0].commits))
+	for _, author := range authors {
+		if author.email == "" {
+			fmt.Printf("%*d  %s\n", width, author.commits, author.name)
+		} else {
+			fmt.Printf("%*d  %s <%s>\n", width, author.commits, author.name, author.email)
+		}
+	}
+	fmt.Printf("\n%d co-authors in %d commits.\n", len(authors), len(commits))
+}
+
+// normalizeName lowercases a name and drops everything but letters and digits
+// so "J. Smith" and "j smith" compare equal
+func normalizeName(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// nameSimilarity scores two names between 0 (unrelated) and 1 (identical
+// after normalization)
+func nameSimilarity(a, b string) float64 {
+	na, nb := normalizeName(a), normalizeName(b)
+	if na == "" || nb == "" {
+		return 0
+	}
+	longest := max(len([]rune(na)), len([]rune(nb)))
+	return 1 - float64(levenshtein(na, nb))/float64(longest)
+}
+
+// suggestMailmap clusters identities sharing an email or with names at least
+// threshold similar, and returns .mailmap lines mapping every other identity
+// of a cluster onto its most active one
+func suggestMailmap(authors []authorIdentity, threshold float64) []string {
+	// Union-find over the identities
+	parent := make([]int, len(authors))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range authors {
+		for j := i + 1; j < len(authors); j++ {
+			sameEmail := authors[i].email != "" && strings.EqualFold(authors[i].email, authors[j].email)
+			if sameEmail || nameSimilarity(authors[i].name, authors[j].name) >= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	clusters := make(map[int][]authorIdentity)
+	var roots []int
+	for i, author := range authors {
+		root := find(i)
+		if _, ok := clusters[root]; !ok {
+			roots = append(roots, root)
+		}
+		clusters[root] = append(clusters[root], author)
+	}
+
+	var lines []string
+	for _, root := range roots {
+		cluster := clusters[root]
+		if len(cluster) < 2 {
+			continue
+		}
+
+		// The identity with the most commits becomes canonical
+		sort.SliceStable(cluster, func(i, j int) bool {
+			return cluster[i].commits > cluster[j].commits
+		})
+		canonical := cluster[0]
+		for _, alias := range cluster[1:] {
+			lines = append(lines, fmt.Sprintf("%s <%s> %s <%s>",
+				canonical.name, canonical.email, alias.name, alias.email))
+		}
+	}
+
+	return lines
+}
+
+// displayAuthorMap prints suggested .mailmap entries for duplicate identities
+func (g *GitSearchTool) displayAuthorMap(threshold float64) {
+	authors, err := g.getAuthors()
+	if err != nil {
+		g.searchErrorf("Error listing authors: %v", err)
+		return
+	}
+
+	fmt.Println("\n=== Suggested .mailmap ===")
+	fmt.Println("# Suggestion only, review before use: identities sharing an email or with")
+	fmt.Printf("# names at least %.0f%% similar are mapped onto the most active one.\n", threshold*100)
+
+	lines := suggestMailmap(authors, threshold)
+	if len(lines) == 0 {
+		fmt.Println("# No duplicate identities found.")
+		return
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountCommitsByAuthor(t *testing.T) {
+	commits := []CommitMatch{
+		{Author: "Bob Jones", Email: "bob@example.org"},
+		{Author: "Alice Smith", Email: "alice@example.com"},
+		{Author: "Alex Kim", Email: "alex@work.example"},
+		{Author: "Bob Jones", Email: "bob@example.org"},
+		{Author: "Alex Kim", Email: "alex@home.example"},
+		{Author: "Alice Smith", Email: "alice@example.com"},
+		{Author: "Bob Jones", Email: "bob@example.org"},
+	}
+	// Most commits first, then by name and email; the two Alex Kims are
+	// told apart by email
+	want := []authorIdentity{
+		{name: "Bob Jones", email: "bob@example.org", commits: 3},
+		{name: "Alice Smith", email: "alice@example.com", commits: 2},
+		{name: "Alex Kim", email: "alex@home.example", commits: 1},
+		{name: "Alex Kim", email: "alex@work.example", commits: 1},
+	}
+	if got := countCommitsByAuthor(commits); !reflect.DeepEqual(got, want) {
+		t.Errorf("countCommitsByAuthor() = %+v, want %+v", got, want)
+	}
+	if got := countCommitsByAuthor(nil); len(got) != 0 {
+		t.Errorf("countCommitsByAuthor(nil) = %+v, want none", got)
+	}
+}
+
+func TestGroupByAuthor(t *testing.T) {
+	r := newTestRepo(t)
+	r.commitEnv(author("Alice Smith", "alice@example.com"), "Fix cache eviction")
+	r.commitEnv(author("Bob Jones", "bob@example.org"), "Fix cache size")
+	r.commitEnv(author("Bob Jones", "bob@example.org"), "Fix cache keys")
+	r.commitEnv(author("Bob Jones", "bob@example.org"), "Update readme")
+	r.commitEnv(author("Alice Smith", "alice@other.example"), "Fix cache stats")
+
+	stdout, stderr, status := r.gst("-no-banner", "-group-by-author", "-query", "cache")
+	if status != exitMatch {
+		t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+	}
+	want := `=== Commits Matching "cache" by Author ===
+2  Bob Jones <bob@example.org>
+1  Alice Smith <alice@example.com>
+1  Alice Smith <alice@other.example>
+
+4 commits by 3 authors.
+`
+	if !strings.Contains(stdout, want) {
+		t.Errorf("output:\n%s\nwant:\n%s", stdout, want)
+	}
+}
+
+func TestCoAuthors(t *testing.T) {
+	body := `Pair on the parser
+
+Co-authored-by: Alice Smith <alice@example.com>
+co-authored-by:Bob Jones <bob@example.org>
+  Co-Authored-By: Carol White
+Signed-off-by: Dan Brown <dan@example.net>
+Co-authored by: not a trailer <x@example.com>`
+	want := []authorIdentity{
+		{name: "Alice Smith", email: "alice@example.com"},
+		{name: "Bob Jones", email: "bob@example.org"},
+		{name: "Carol White"},
+	}
+	if got := coAuthors(body); !reflect.DeepEqual(got, want) {
+		t.Errorf("coAuthors() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCountCoAuthors(t *testing.T) {
+	commits := []CommitMatch{
+		{Body: "Co-authored-by: Alice S. <ALICE@example.com>\nCo-authored-by: Carol White"},
+		{Body: "Co-authored-by: Bob Jones <bob@example.org>\nCo-authored-by: Alice Smith <alice@example.com>"},
+		// Listed twice, counted once
+		{Body: "Co-authored-by: Bob Jones <bob@example.org>\nCo-authored-by: Bob Jones <bob@example.org>"},
+		{Body: "Co-authored-by: carol  white"},
+		{Body: "No trailers"},
+	}
+	// Alice is named as in the newest commit; ties keep the order they were
+	// first seen in
+	want := []authorIdentity{
+		{name: "Alice S.", email: "ALICE@example.com", commits: 2},
+		{name: "Carol White", commits: 2},
+		{name: "Bob Jones", email: "bob@example.org", commits: 2},
+	}
+	if got := countCoAuthors(commits); !reflect.DeepEqual(got, want) {
+		t.Errorf("countCoAuthors() = %+v, want %+v", got, want)
+	}
+	if got := countCoAuthors(commits[4:]); len(got) != 0 {
+		t.Errorf("countCoAuthors() without trailers = %+v, want none", got)
+	}
+}
+
+func TestCoAuthorsReport(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Fix parser crash\n\nCo-authored-by: Alice Smith <alice@example.com>")
+	r.commit("Fix parser leak\n\nCo-authored-by: Alice Smith <alice@example.com>\nCo-authored-by: Bob Jones <bob@example.org>")
+	r.commit("Update readme\n\nCo-authored-by: Bob Jones <bob@example.org>")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "=== Co-authors ===\n2  Bob Jones <bob@example.org>\n2  Alice Smith <alice@example.com>\n\n2 co-authors in 3 commits.\n"},
+		{[]string{"-query", "parser"}, "=== Co-authors of Commits Matching \"parser\" ===\n2  Alice Smith <alice@example.com>\n1  Bob Jones <bob@example.org>\n\n2 co-authors in 2 commits.\n"},
+		{[]string{"-query", "readme", "-author", "nobody"}, "No Co-authored-by trailers found.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			stdout, stderr, status := r.gst(append([]string{"-no-banner", "-coauthors"}, tt.args...)...)
+			if status != exitMatch {
+				t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+			}
+			if !strings.Contains(stdout, tt.want) {
+				t.Errorf("output:\n%s\nwant:\n%s", stdout, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"unicode/utf8"
+)
+
+// looksBinary reports whether matched content is binary rather than text,
+// using the same NUL byte heuristic as git plus a UTF-8 validity check
+func looksBinary(content string) bool {
+	return bytes.IndexByte([]byte(content), 0) >= 0 || !utf8.ValidString(content)
+}
+
+// binaryPreview renders up to max bytes of binary content as hex, centered on
+// the first occurrence of the query when it can be found
+func binaryPreview(content, query string, max int) string {
+	data := []byte(content)
+
+	start := 0
+	if i := bytes.Index(bytes.ToLower(data), bytes.ToLower([]byte(query))); i >= 0 && query != "" {
+		start = i - (max-len(query))/2
+		if start < 0 {
+			start = 0
+		}
+	}
+	end := start + max
+	if end > len(data) {
+		end = len(data)
+		if start = end - max; start < 0 {
+			start = 0
+		}
+	}
+
+	preview := hex.EncodeToString(data[start:end])
+	if start > 0 {
+		preview = "..." + preview
+	}
+	if end < len(data) {
+		preview += "..."
+	}
+	return fmt.Sprintf("[binary] %d bytes at offset %d: %s", end-start, start, preview)
+}
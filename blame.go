@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// addBlame fills in who last changed each matched line, running git blame
+// once per file for all of its matched lines
+func (g *GitSearchTool) addBlame(matches []FileMatch) error {
+	lines := make(map[string][]int)
+	var files []string
+	for _, match := range matches {
+		if _, ok := lines[match.Path]; !ok {
+			files = append(files, match.Path)
+		}
+		lines[match.Path] = append(lines[match.Path], match.LineNumber)
+	}
+
+	blamed := make(map[string]map[int]blameLine)
+	for _, file := range files {
+		result, err := g.blameLines(file, lines[file])
+		if err != nil {
+			return err
+		}
+		blamed[file] = result
+	}
+
+	for i := range matches {
+		if line, ok := blamed[matches[i].Path][matches[i].LineNumber]; ok {
+			matches[i].BlameCommit = line.commit
+			matches[i].BlameAuthor = line.author
+		}
+	}
+	return nil
+}
+
+// blameLine is the commit and author that last changed a line
+type blameLine struct {
+	commit string
+	author string
+}
+
+// blameLines runs git blame for the given lines of a file and returns the
+// last change of each by line number
+func (g *GitSearchTool) blameLines(file string, numbers []int) (map[int]blameLine, error) {
+	args := []string{"blame", "--porcelain"}
+	for _, n := range numbers {
+		args = append(args, "-L", fmt.Sprintf("%d,%d", n, n))
+	}
+	if rev := g.grepRevision(); rev != "" {
+		args = append(args, rev)
+	}
+	args = append(args, "--", file)
+
+	cmd := g.gitCommand(args...)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to blame %s: %v", file, err)
+	}
+
+	return parseBlamePorcelain(toUTF8(string(output))), nil
+}
+
+// parseBlamePorcelain reads git blame --porcelain output. Each line starts
+// with a "<hash> <orig-line> <final-line> [<count>]" header; the commit's
+// other headers, such as its author, only follow the first time it appears.
+func parseBlamePorcelain(output string) map[int]blameLine {
+	authors := make(map[string]string)
+	result := make(map[int]blameLine)
+
+	var hash string
+	var final int
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			result[final] = blameLine{commit: hash}
+		case strings.HasPrefix(line, "author "):
+			authors[hash] = strings.TrimPrefix(line, "author ")
+		default:
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && len(fields[0]) >= 40 {
+				if n, err := strconv.Atoi(fields[2]); err == nil {
+					hash, final = fields[0], n
+				}
+			}
+		}
+	}
+
+	for n, line := range result {
+		line.author = authors[line.commit]
+		result[n] = line
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultCommitTemplate matches conventional-commits style subjects such as
+// "fix(parser): handle empty input"
+const defaultCommitTemplate = `^(build|chore|ci|docs|feat|fix|perf|refactor|revert|style|test)(\([\w./-]+\))?!?: .+`
+
+// getCommitTemplate returns the gst.commitTemplate git config value, falling
+// back to the conventional-commits template when it is unset
+func (g *GitSearchTool) getCommitTemplate() string {
+	cmd := g.gitCommand("config", "--get", "gst.commitTemplate")
+
+	output, err := g.run(cmd)
+	if err != nil || strings.TrimSpace(string(output)) == "" {
+		return defaultCommitTemplate
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+// getRecentCommitSubjects retrieves the hash and subject of the most recent commits
+func (g *GitSearchTool) getRecentCommitSubjects(count int) ([]map[string]string, error) {
+	cmd := g.gitCommand("log", fmt.Sprintf("-%d", count), "--no-merges", logEncoding,
+		"--pretty=format:%H%x1f%an%x1f%ad%x1f%s", g.dateOption())
+
+	output, err := g.run(cmd)
+	if err != nil {
+		// Without any commits there is nothing to check
+		if g.noCommitsYet() {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list recent commits: %v", err)
+	}
+
+	var commits []map[string]string
+	for _, line := range strings.Split(toUTF8(strings.TrimSpace(string(output))), "\n") {
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, "\x1f", 4)
+		if len(parts) == 4 {
+			commits = append(commits, map[string]string{
+				"hash":    parts[0],
+				"author":  parts[1],
+				"date":    parts[2],
+				"subject": parts[3],
+			})
+		}
+	}
+
+	return commits, nil
+}
+
+// checkCommitTemplate lists recent commits whose subjects do not match the template
+func (g *GitSearchTool) checkCommitTemplate(template *regexp.Regexp, count int) (checked int, offenders []map[string]string, err error) {
+	commits, err := g.getRecentCommitSubjects(count)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, commit := range commits {
+		if !template.MatchString(commit["subject"]) {
+			offenders = append(offenders, commit)
+		}
+	}
+
+	return len(commits), offenders, nil
+}
+
+// displayCommitTemplateCheck reports commits violating the template and
+// returns whether all checked commits passed
+func (g *GitSearchTool) displayCommitTemplateCheck(template *regexp.Regexp, count int) (bool, error) {
+	checked, offenders, err := g.checkCommitTemplate(template, count)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("=== Commit Template Check: %s ===\n", template)
+	for i, commit := range offenders {
+		fmt.Printf("%d. [%s] %s - %s (%s)\n",
+			i+1, abbreviateHash(commit["hash"]), g.displaySubject(commit["subject"]),
+			commit["author"], commit["date"])
+	}
+
+	if len(offenders) == 0 {
+		fmt.Printf("PASS: all %d commits match the template.\n", checked)
+		return true, nil
+	}
+
+	fmt.Printf("FAIL: %d of %d commits do not match the template.\n", len(offenders), checked)
+	return false, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// checkpointFile keeps the progress of a -multi search in the -checkpoint
+// file, so that a search stopped halfway can be resumed with
+// -resume-checkpoint without searching the finished repositories again
+type checkpointFile struct {
+	path string
+	mu   sync.Mutex
+	data checkpoint
+}
+
+// checkpoint is the JSON document of a checkpoint file: the command line of
+// the search and the repositories it finished
+type checkpoint struct {
+	Args  []string         `json:"args"`
+	Repos []checkpointRepo `json:"repos"`
+}
+
+// checkpointRepo is a finished repository with the state it was searched in
+type checkpointRepo struct {
+	Path    string      `json:"path"`
+	State   string      `json:"state"`
+	Results RepoResults `json:"results"`
+}
+
+// presentationFlags only change how results are shown or logged, so a
+// search can be resumed with other values for them
+var presentationFlags = []string{
+	"resume-checkpoint", "quiet", "no-banner", "color", "format", "json-pretty", "output", "force",
+	"debug-json", "timing", "log-level", "no-pager", "concurrency",
+}
+
+// checkpointArgs returns the flags that identify a search in its
+// checkpoint, as name=value in name order
+func checkpointArgs(fs *flag.FlagSet) []string {
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		if !slices.Contains(presentationFlags, f.Name) {
+			args = append(args, f.Name+"="+f.Value.String())
+		}
+	})
+	return args
+}
+
+// openCheckpoint starts the checkpoint of the search run with args. When
+// resuming it takes over the repositories finished by an earlier run of the
+// same search; a missing file starts from scratch.
+func openCheckpoint(path string, args []string, resume bool) (*checkpointFile, error) {
+	c := &checkpointFile{path: path, data: checkpoint{Args: args}}
+	if !resume {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved checkpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("%s is not a checkpoint: %v", path, err)
+	}
+	if !slices.Equal(saved.Args, args) {
+		return nil, fmt.Errorf("%s is the checkpoint of another search (%s)", path, strings.Join(saved.Args, " "))
+	}
+	c.data.Repos = saved.Repos
+	return c, nil
+}
+
+// done returns the results of the repository at path from the resumed run,
+// provided it is still in the state it was searched in
+func (c *checkpointFile) done(path, state string) (RepoResults, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, repo := range c.data.Repos {
+		if repo.Path == path && repo.State == state {
+			return repo.Results, true
+		}
+	}
+	return RepoResults{}, false
+}
+
+// record adds the results of a finished repository and saves the
+// checkpoint. The file is replaced in one step, so that stopping the search
+// never leaves it half written.
+func (c *checkpointFile) record(path, state string, results RepoResults) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data.Repos = slices.DeleteFunc(c.data.Repos, func(repo checkpointRepo) bool { return repo.Path == path })
+	c.data.Repos = append(c.data.Repos, checkpointRepo{Path: path, State: state, Results: results})
+
+	data, err := json.Marshal(c.data)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// repoState identifies the commits a search of the repository sees, its
+// HEAD or with -all-branches every ref, so that the results of a checkpoint
+// taken before they moved aren't reused
+func (g *GitSearchTool) repoState() string {
+	args := []string{"rev-parse", "HEAD"}
+	if g.allBranches {
+		args = []string{"show-ref", "--head"}
+	}
+	output, err := g.run(g.gitCommand(args...))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(output)
+	return hex.EncodeToString(sum[:])
+}
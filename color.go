@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiDim    = "\033[2m"
+	ansiRed    = "\033[31m"
+	ansiYellow = "\033[33m"
+)
+
+// colorModes lists the values accepted by -color
+var colorModes = []string{"auto", "always", "never"}
+
+// colorMode decides when ANSI escape codes are emitted: "auto" colors only
+// when stdout is a terminal
+var colorMode = "auto"
+
+// stdoutIsTerminal reports whether stdout is attached to a terminal
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// stderrIsTerminal reports whether stderr is attached to a terminal
+func stderrIsTerminal() bool {
+	info, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor reports whether output should contain ANSI escape codes
+func useColor() bool {
+	switch colorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return stdoutIsTerminal()
+	}
+}
+
+// colorize wraps s in an ANSI code when color is in use
+func colorize(code, s string) string {
+	if !useColor() {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// dim renders s in a faint color
+func dim(s string) string {
+	return colorize(ansiDim, s)
+}
+
+// bold renders s in bold, used for headers
+func bold(s string) string {
+	return colorize(ansiBold, s)
+}
+
+// yellow renders s in yellow, used for commit hashes
+func yellow(s string) string {
+	return colorize(ansiYellow, s)
+}
+
+// highlight marks matched text, in bold red with color and with asterisks
+// otherwise
+func highlight(s string) string {
+	if useColor() {
+		return ansiBold + ansiRed + s + ansiReset
+	}
+	return "**" + s + "**"
+}
+
+// highlightPatterns colors every match of the patterns in text. Without
+// color text is returned unchanged so that plain output stays parseable.
+func highlightPatterns(text string, patterns []string, caseSensitive bool) string {
+	if !useColor() || len(patterns) == 0 {
+		return text
+	}
+
+	marked := matchedBytes(text, patterns, caseSensitive)
+	var out []byte
+	for i := 0; i < len(text); {
+		j := i
+		for j < len(text) && marked[j] == marked[i] {
+			j++
+		}
+		if marked[i] {
+			out = append(out, highlight(text[i:j])...)
+		} else {
+			out = append(out, text[i:j]...)
+		}
+		i = j
+	}
+	return string(out)
+}
+
+// matchedBytes marks the bytes of text matched by any of the patterns, so
+// that overlapping matches are only shown once. Patterns are tried as
+// regular expressions and fall back to literals when they don't compile or
+// match.
+func matchedBytes(text string, patterns []string, caseSensitive bool) []bool {
+	flags := "(?i)"
+	if caseSensitive {
+		flags = ""
+	}
+
+	marked := make([]bool, len(text))
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(flags + pattern)
+		if err != nil || re.FindStringIndex(text) == nil {
+			re = regexp.MustCompile(flags + regexp.QuoteMeta(pattern))
+		}
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			for i := loc[0]; i < loc[1]; i++ {
+				marked[i] = true
+			}
+		}
+	}
+	return marked
+}
+
+// underline returns a line of carets under the matches of the patterns in
+// text, or "" when nothing matched. Tabs before a match are copied so the
+// carets line up however wide the terminal draws them.
+func underline(text string, patterns []string, caseSensitive bool) string {
+	marked := matchedBytes(text, patterns, caseSensitive)
+	var sb strings.Builder
+	for i, r := range text {
+		switch {
+		case marked[i]:
+			sb.WriteByte('^')
+		case r == '\t':
+			sb.WriteByte('\t')
+		default:
+			sb.WriteByte(' ')
+		}
+	}
+	return strings.TrimRight(sb.String(), " \t")
+}
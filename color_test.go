@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestUnderline(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		patterns      []string
+		caseSensitive bool
+		want          string
+	}{
+		{"plain", "token := parse()", []string{"parse"}, false, "         ^^^^^"},
+		{"tab indented", "\t\treturn token", []string{"token"}, false, "\t\t       ^^^^^"},
+		{"tab between", "key\t= token", []string{"token"}, false, "   \t  ^^^^^"},
+		{"ignoring case", "Token token", []string{"token"}, false, "^^^^^ ^^^^^"},
+		{"case sensitive", "Token token", []string{"token"}, true, "      ^^^^^"},
+		{"after multibyte runes", "héllo wörld", []string{"wörld"}, false, "      ^^^^^"},
+		{"regex", "id=42 id=7", []string{`id=\d+`}, false, "^^^^^ ^^^^"},
+		{"overlapping patterns", "parser", []string{"pars", "rser"}, false, "^^^^^^"},
+		{"no match", "nothing here", []string{"token"}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := underline(tt.text, tt.patterns, tt.caseSensitive); got != tt.want {
+				t.Errorf("underline(%q, %q) =\n%q, want\n%q", tt.text, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// commitView is what -commit-format templates are executed with: the fields
+// of the commit plus its position in the section and abbreviated hash
+type commitView struct {
+	CommitMatch
+	Index     int
+	ShortHash string
+}
+
+// parseCommitFormat parses a -commit-format template and runs it once on an
+// empty commit, so that references to unknown fields are reported up front
+// rather than in the middle of a search
+func parseCommitFormat(text string) (*template.Template, error) {
+	tmpl, err := template.New("commit-format").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, commitView{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// formatCommit renders the line listing the i-th commit of a section, with
+// -commit-format when one is set
+func (g *GitSearchTool) formatCommit(i int, commit CommitMatch) string {
+	if g.commitFormat == nil {
+		return fmt.Sprintf("%d. [%s] %s - %s (%s)",
+			i+1, yellow(abbreviateHash(commit.Hash)), g.displaySubject(commit.Subject),
+			commit.Author, commit.Date)
+	}
+
+	commit.Subject = g.displaySubject(commit.Subject)
+	var sb strings.Builder
+	if err := g.commitFormat.Execute(&sb, commitView{commit, i + 1, abbreviateHash(commit.Hash)}); err != nil {
+		return fmt.Sprintf("%d. [%s] (-commit-format: %v)", i+1, abbreviateHash(commit.Hash), err)
+	}
+	return sb.String()
+}
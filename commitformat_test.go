@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCommitFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr string
+	}{
+		{"{{.ShortHash}} {{.Subject}}", ""},
+		{"{{.Index}}) {{.Author}} <{{.Email}}> {{.Date}}", ""},
+		{"{{.Subject", "unclosed action"},
+		{"{{.Title}}", "can't evaluate field Title"},
+		{"{{if .Hash}}", "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		_, err := parseCommitFormat(tt.format)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("parseCommitFormat(%q) = %v", tt.format, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("parseCommitFormat(%q) error = %v, want %q", tt.format, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCommitFormat(t *testing.T) {
+	r := newTestRepo(t)
+	r.commitEnv(author("Alice Smith", "alice@example.com"), "Fix cache eviction")
+	hash := r.commitEnv(author("Bob Jones", "bob@example.org"), "Add cache metrics")
+
+	stdout, stderr, status := r.gst("-quiet", "-commit-format", "{{.Index}}) {{.ShortHash}} {{.Author}} <{{.Email}}> {{.Date}}: {{.Subject}}", "-query", "cache")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	want := "1) " + hash[:8] + " Bob Jones <bob@example.org> 2024-01-01: Add cache metrics\n"
+	if !strings.HasPrefix(stdout, want) || !strings.Contains(stdout, "\n2) ") {
+		t.Errorf("output:\n%s\nwant it to start with:\n%s", stdout, want)
+	}
+
+	// An invalid template fails before any search runs
+	stdout, stderr, status = r.gst("-quiet", "-commit-format", "{{.Title}}", "-query", "cache")
+	if status != exitError || stdout != "" || !strings.Contains(stderr, "invalid -commit-format:") || !strings.Contains(stderr, "can't evaluate field Title") {
+		t.Errorf("invalid template: exit status %d, stdout %q, stderr:\n%s", status, stdout, stderr)
+	}
+}
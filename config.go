@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configFileName is the file of default flag values looked for in the
+// repository root and the home directory
+const configFileName = ".gstrc"
+
+// repoConfigFlags are the flags a repository's .gstrc may set. The file
+// comes with the repository, so it is limited to how results are searched
+// and shown; flags that run programs, read or write other files or pick
+// what is searched, such as -git-bin, -output or -multi, can only be set in
+// ~/.gstrc or on the command line.
+var repoConfigFlags = map[string]bool{
+	"format": true, "json-pretty": true, "color": true, "quiet": true, "no-banner": true,
+	"max-files": true, "max-commits": true, "max-results": true, "max-file-size": true,
+	"ext": true, "preset": true, "path-filter": true, "sort": true, "group": true,
+	"case-sensitive": true, "commit-case-sensitive": true, "file-case-sensitive": true,
+	"regex": true, "word": true, "match": true, "no-merges": true, "first-parent": true,
+	"date-format": true, "date-field": true, "date-order": true, "body-lines": true, "body-snippets": true,
+	"strip-emoji": true, "truncate": true, "underline": true, "dim-noise": true,
+	"noise-threshold": true, "repo-prefix": true, "with-stat": true, "ignore-whitespace": true,
+	"threads": true, "page-size": true, "no-pager": true, "timeout": true, "retries": true,
+	"log-level": true,
+}
+
+// configFile is a config file that applies to a search; repo marks the
+// repository's own, which may only set repoConfigFlags
+type configFile struct {
+	path string
+	repo bool
+}
+
+// configPaths returns the config files that apply to a search in dir, the
+// repository's before the home directory's
+func configPaths(dir string) []configFile {
+	var home string
+	if d, err := os.UserHomeDir(); err == nil {
+		home = filepath.Join(d, configFileName)
+	}
+
+	var files []configFile
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			// A repository in the home directory has the user's own file
+			if path := filepath.Join(d, configFileName); path != home {
+				files = append(files, configFile{path: path, repo: true})
+			}
+			break
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if home != "" {
+		files = append(files, configFile{path: home})
+	}
+	return files
+}
+
+// givenFlags returns the names of the flags set so far, before the config
+// files are applied those of the command line
+func givenFlags(flags *flag.FlagSet) map[string]bool {
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	return given
+}
+
+// applyConfig sets flags from the "name = value" lines of the config files,
+// skipping files that don't exist. Flags given on the command line keep
+// their value, and a flag set by an earlier file isn't changed by a later
+// one; repeating a flag within a file adds to repeatable flags such as
+// -ext. Flags a repository's file may not set are skipped with a warning.
+func applyConfig(flags *flag.FlagSet, files []configFile) error {
+	given := givenFlags(flags)
+
+	for _, file := range files {
+		path := file.path
+		values, err := readConfig(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		applied := make(map[string]bool)
+		for _, value := range values {
+			if flags.Lookup(value.name) == nil {
+				return fmt.Errorf("%s:%d: unknown flag -%s", path, value.line, value.name)
+			}
+			if file.repo && !repoConfigFlags[value.name] {
+				warnf("%s:%d: ignoring -%s, which can only be set in ~/%s or on the command line", path, value.line, value.name, configFileName)
+				continue
+			}
+			if given[value.name] {
+				continue
+			}
+			if err := flags.Set(value.name, value.value); err != nil {
+				return fmt.Errorf("%s:%d: invalid value %q for -%s: %v", path, value.line, value.value, value.name, err)
+			}
+			applied[value.name] = true
+		}
+		for name := range applied {
+			given[name] = true
+		}
+	}
+	return nil
+}
+
+// configValue is a "name = value" line of a config file
+type configValue struct {
+	name, value string
+	line        int
+}
+
+// readConfig parses a config file. Blank lines and lines starting with #
+// are skipped, and names may be written with their leading dash.
+func readConfig(path string) ([]configValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var values []configValue
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected name = value", path, n)
+		}
+		values = append(values, configValue{
+			name:  strings.TrimLeft(strings.TrimSpace(name), "-"),
+			value: strings.TrimSpace(value),
+			line:  n,
+		})
+	}
+	return values, scanner.Err()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplyConfig(t *testing.T) {
+	repo := writeConfig(t, "# search defaults\nmax-files = 5\n-ext = go\next = md\n\ngit-bin = /tmp/evil\n")
+	home := writeConfig(t, "max-files = 50\ncolor = never\ngit-bin = /usr/local/bin/git\n")
+
+	tests := []struct {
+		name      string
+		args      []string
+		files     []configFile
+		maxFiles  int
+		color     string
+		exts      []string
+		gitBin    string
+		wantError string
+	}{
+		{"built-in defaults", nil, nil, 20, "auto", nil, "git", ""},
+		{"config without flags", nil, []configFile{{path: home}}, 50, "never", nil, "/usr/local/bin/git", ""},
+		{"flag overrides config", []string{"-max-files", "3"}, []configFile{{path: home}}, 3, "never", nil, "/usr/local/bin/git", ""},
+		{"repository before home", nil, []configFile{{path: repo, repo: true}, {path: home}}, 5, "never", []string{"go", "md"}, "/usr/local/bin/git", ""},
+		{"flag overrides both", []string{"-max-files", "1", "-ext", "txt"}, []configFile{{path: repo, repo: true}, {path: home}}, 1, "never", []string{"txt"}, "/usr/local/bin/git", ""},
+		{"missing file", nil, []configFile{{path: filepath.Join(t.TempDir(), "missing")}}, 20, "auto", nil, "git", ""},
+		{"unknown flag", nil, []configFile{{path: writeConfig(t, "colour = never\n")}}, 0, "", nil, "", "unknown flag -colour"},
+		{"invalid value", nil, []configFile{{path: writeConfig(t, "max-files = many\n")}}, 0, "", nil, "", `invalid value "many" for -max-files`},
+		{"no equals sign", nil, []configFile{{path: writeConfig(t, "max-files 3\n")}}, 0, "", nil, "", "expected name = value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("gst", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			maxFiles := fs.Int("max-files", 20, "")
+			color := fs.String("color", "auto", "")
+			gitBin := fs.String("git-bin", "git", "")
+			var exts stringList
+			fs.Var(&exts, "ext", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyConfig(fs, tt.files)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Errorf("applyConfig() error = %v, want %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *maxFiles != tt.maxFiles || *color != tt.color || *gitBin != tt.gitBin || !slices.Equal(exts, tt.exts) {
+				t.Errorf("-max-files %d -color %s -git-bin %s -ext %q, want %d %s %s %q",
+					*maxFiles, *color, *gitBin, exts, tt.maxFiles, tt.color, tt.gitBin, tt.exts)
+			}
+		})
+	}
+}
+
+// writeConfig writes a config file with content and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), configFileName)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFile(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add handlers", "a.go", "handler\n", "b.go", "handler\n", "c.md", "handler\n")
+	r.write(configFileName, "max-files = 1\next = go\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"config", nil, "1. a.go:1:handler\n"},
+		{"flag overrides config", []string{"-max-files", "5"}, "1. a.go:1:handler\n2. b.go:1:handler\n"},
+		{"repeatable flag overrides config", []string{"-ext", "md"}, "1. c.md:1:handler\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, status := r.gst(append([]string{"-quiet", "-head-only", "-query", "handler"}, tt.args...)...)
+			if status != exitMatch || stdout != tt.want {
+				t.Errorf("exit status %d, output %q, want %q; stderr:\n%s", status, stdout, tt.want, stderr)
+			}
+		})
+	}
+}
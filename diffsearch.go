@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DiffMatch is a line added by the uncommitted changes that matched a search
+type DiffMatch struct {
+	Path       string
+	LineNumber int
+	Content    string
+	Staged     bool
+}
+
+// searchInDiff searches the lines added by the unstaged changes (git diff)
+// and the staged ones (git diff --cached) of the files in the search path.
+// Line numbers are those of the line in the working tree or index version of
+// the file, counted from the hunk headers.
+func (g *GitSearchTool) searchInDiff(opts SearchOptions, unstaged, staged bool) ([]DiffMatch, error) {
+	var matches []DiffMatch
+	for _, cached := range []bool{false, true} {
+		if (cached && !staged) || (!cached && !unstaged) {
+			continue
+		}
+
+		args := []string{"diff", "--no-color", "--no-ext-diff", "-U0"}
+		if cached {
+			args = append(args, "--cached")
+		}
+		cmd := g.gitCommand(args...)
+		if pathspecs := g.searchPathspecs(g.pathFilters); len(pathspecs) > 0 {
+			cmd.Args = append(cmd.Args, "--")
+			cmd.Args = append(cmd.Args, pathspecs...)
+		}
+
+		output, err := g.run(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the uncommitted changes: %v", err)
+		}
+		for _, match := range addedLines(string(output)) {
+			if g.matchesTerms(match.Content, opts) {
+				match.Staged = cached
+				matches = append(matches, match)
+			}
+		}
+	}
+	return matches, nil
+}
+
+// addedLines returns the lines added by a diff with the file and line number
+// each of them ends up at
+func addedLines(diff string) []DiffMatch {
+	var (
+		lines  []DiffMatch
+		file   string
+		next   int
+		inHunk bool
+	)
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file = diffFileName(line)
+			inHunk = false
+		case strings.HasPrefix(line, "@@ "):
+			next, inHunk = hunkStart(line), true
+		case !inHunk:
+		case strings.HasPrefix(line, "+"):
+			lines = append(lines, DiffMatch{Path: file, LineNumber: next, Content: line[1:]})
+			next++
+		case strings.HasPrefix(line, " "):
+			next++
+		}
+	}
+	return lines
+}
+
+// hunkStart returns the first line number of the new side of a hunk header
+// such as "@@ -12,3 +14,5 @@ func main() {"
+func hunkStart(header string) int {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0
+	}
+	start, _, _ := strings.Cut(fields[2][1:], ",")
+	n, _ := strconv.Atoi(start)
+	return n
+}
+
+// displayDiffSearch prints the lines added by the uncommitted changes that
+// match query and returns the exit status of the search
+func (g *GitSearchTool) displayDiffSearch(query string, unstaged, staged bool) int {
+	g.decorf("\n%s\n", bold(fmt.Sprintf("=== Uncommitted Changes Matching: %s ===", g.describeQuery(query))))
+
+	matches, err := g.searchInDiff(g.fileSearchOptions(query), unstaged, staged)
+	if err != nil {
+		errorf("Error searching the uncommitted changes: %v", err)
+		return exitError
+	}
+	if len(matches) == 0 {
+		g.decorf("No added lines match.\n\n")
+		return exitNoMatch
+	}
+
+	patterns := g.filePatternsOf(query)
+	for _, section := range []bool{false, true} {
+		title := "--- Unstaged ---"
+		if section {
+			title = "--- Staged ---"
+		}
+		shown := 0
+		for _, match := range matches {
+			if match.Staged != section {
+				continue
+			}
+			if shown == 0 {
+				g.decorf("\n%s\n", bold(title))
+			}
+			shown++
+			fmt.Printf("%d. %s:%d:+%s\n", shown, match.Path, match.LineNumber,
+				highlightPatterns(match.Content, patterns, g.filesCaseSensitive()))
+		}
+	}
+
+	g.decorf("\nFound %d matching added lines.\n\n", len(matches))
+	return exitMatch
+}
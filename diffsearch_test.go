@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHunkStart(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{"@@ -12,3 +14,5 @@ func main() {", 14},
+		{"@@ -1 +1 @@", 1},
+		{"@@ -0,0 +1,2 @@", 1},
+		{"@@ -3,2 +2,0 @@", 2},
+		{"@@ garbled", 0},
+	}
+	for _, tt := range tests {
+		if got := hunkStart(tt.header); got != tt.want {
+			t.Errorf("hunkStart(%q) = %d, want %d", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestAddedLines(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -3,0 +4,2 @@ import "fmt"
++	fmt.Println("debug")
++	// TODO: remove
+@@ -10 +12 @@ func main() {
+-	old()
++	new()
+diff --git a/docs/+plus.md b/docs/+plus.md
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/docs/+plus.md
+@@ -0,0 +1 @@
++++ not a header
+`
+	want := []DiffMatch{
+		{Path: "main.go", LineNumber: 4, Content: "\tfmt.Println(\"debug\")"},
+		{Path: "main.go", LineNumber: 5, Content: "\t// TODO: remove"},
+		{Path: "main.go", LineNumber: 12, Content: "\tnew()"},
+		{Path: "docs/+plus.md", LineNumber: 1, Content: "++ not a header"},
+	}
+	if got := addedLines(diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("addedLines() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSearchInDiff(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add main", "main.go", "package main\n\nfunc main() {\n\trun() // TODO\n}\n", "util.go", "package main\n")
+	// Staged and then unstaged lines, and a committed TODO that isn't
+	// a change
+	r.write("util.go", "package main\n\n// TODO: staged helper\n")
+	r.git("add", "util.go")
+	r.write("main.go", "package main\n\nfunc main() {\n\trun() // TODO\n\tfmt.Println(\"TODO\")\n}\n")
+
+	tests := []struct {
+		name             string
+		unstaged, staged bool
+		want             []DiffMatch
+	}{
+		{"both", true, true, []DiffMatch{
+			{Path: "main.go", LineNumber: 5, Content: "\tfmt.Println(\"TODO\")"},
+			{Path: "util.go", LineNumber: 3, Content: "// TODO: staged helper", Staged: true},
+		}},
+		{"unstaged", true, false, []DiffMatch{
+			{Path: "main.go", LineNumber: 5, Content: "\tfmt.Println(\"TODO\")"},
+		}},
+		{"staged", false, true, []DiffMatch{
+			{Path: "util.go", LineNumber: 3, Content: "// TODO: staged helper", Staged: true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.tool().searchInDiff(SearchOptions{Query: "todo"}, tt.unstaged, tt.staged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchInDiff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	stdout, stderr, status := r.gst("-no-banner", "-in-diff", "-query", "TODO")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	for _, want := range []string{"--- Unstaged ---\n1. main.go:5:+\tfmt.Println(\"TODO\")\n", "--- Staged ---\n1. util.go:3:+// TODO: staged helper\n", "Found 2 matching added lines."} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output is missing %q:\n%s", want, stdout)
+		}
+	}
+	if _, _, status := r.gst("-quiet", "-in-diff", "-query", "run()"); status != exitNoMatch {
+		t.Errorf("committed line: exit status %d, want %d", status, exitNoMatch)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// editorCommand builds the command line opening path at line with editor,
+// an $EDITOR value that may carry arguments of its own such as "emacs -nw"
+func editorCommand(editor, path string, line int) []string {
+	return append(strings.Fields(editor), fmt.Sprintf("+%d", line), path)
+}
+
+// resolveMatch returns the file match numbered arg in the last search's
+// results
+func (g *GitSearchTool) resolveMatch(arg string) (FileMatch, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return FileMatch{}, fmt.Errorf("expected the number of a file match, got %q", arg)
+	}
+	if len(g.lastFiles) == 0 {
+		return FileMatch{}, errors.New("the last search listed no file matches")
+	}
+	if n < 1 || n > len(g.lastFiles) {
+		return FileMatch{}, fmt.Errorf("no file match %d, the last search listed 1 to %d", n, len(g.lastFiles))
+	}
+	return g.lastFiles[n-1], nil
+}
+
+// openMatch opens a file match of the last search in $EDITOR at its line,
+// or prints where it is when there is no editor or no file on disk
+func (g *GitSearchTool) openMatch(arg string) {
+	match, err := g.resolveMatch(arg)
+	if err != nil {
+		fmt.Printf("Cannot open %q: %v\n", arg, err)
+		return
+	}
+
+	path := filepath.Join(g.repoPath, match.Path)
+	editor := os.Getenv("EDITOR")
+	if editor == "" || g.grepRevision() != "" {
+		fmt.Printf("%s:%d\n", path, match.LineNumber)
+		return
+	}
+
+	args := editorCommand(editor, path, match.LineNumber)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		errorf("Error running %s: %v", args[0], err)
+	}
+}
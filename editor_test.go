@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEditorCommand(t *testing.T) {
+	tests := []struct {
+		editor string
+		want   []string
+	}{
+		{"vim", []string{"vim", "+12", "/repo/main.go"}},
+		{"emacs -nw", []string{"emacs", "-nw", "+12", "/repo/main.go"}},
+		{"  code   --wait ", []string{"code", "--wait", "+12", "/repo/main.go"}},
+	}
+	for _, tt := range tests {
+		if got := editorCommand(tt.editor, "/repo/main.go", 12); !slices.Equal(got, tt.want) {
+			t.Errorf("editorCommand(%q) = %q, want %q", tt.editor, got, tt.want)
+		}
+	}
+}
+
+func TestResolveMatch(t *testing.T) {
+	g := &GitSearchTool{lastFiles: []FileMatch{
+		{Path: "a.go", LineNumber: 3},
+		{Path: "b.go", LineNumber: 7},
+	}}
+	tests := []struct {
+		arg     string
+		want    FileMatch
+		wantErr string
+	}{
+		{"1", FileMatch{Path: "a.go", LineNumber: 3}, ""},
+		{"2", FileMatch{Path: "b.go", LineNumber: 7}, ""},
+		{"0", FileMatch{}, "no file match 0, the last search listed 1 to 2"},
+		{"3", FileMatch{}, "no file match 3, the last search listed 1 to 2"},
+		{"-1", FileMatch{}, "no file match -1"},
+		{"two", FileMatch{}, `expected the number of a file match, got "two"`},
+	}
+	for _, tt := range tests {
+		got, err := g.resolveMatch(tt.arg)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("resolveMatch(%q) error = %v, want %q", tt.arg, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveMatch(%q) = %+v, %v, want %+v", tt.arg, got, err, tt.want)
+		}
+	}
+
+	// Before any file search there is nothing to open
+	if _, err := (&GitSearchTool{}).resolveMatch("1"); err == nil || !strings.Contains(err.Error(), "listed no file matches") {
+		t.Errorf("resolveMatch without results: error = %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// emojiShortcode matches a leading gitmoji style shortcode such as ":bug:"
+var emojiShortcode = regexp.MustCompile(`^:[a-z0-9_+-]+:`)
+
+// emojiRanges are the Unicode blocks gitmoji and most commit emoji come from
+var emojiRanges = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x2190, Hi: 0x21ff, Stride: 1}, // arrows
+		{Lo: 0x2300, Hi: 0x23ff, Stride: 1}, // miscellaneous technical
+		{Lo: 0x2600, Hi: 0x27bf, Stride: 1}, // miscellaneous symbols and dingbats
+		{Lo: 0x2b00, Hi: 0x2bff, Stride: 1}, // miscellaneous symbols and arrows
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1faff, Stride: 1}, // emoticons, pictographs, transport, ...
+	},
+}
+
+// isEmojiRune reports whether r is an emoji or a modifier joining emoji
+// together, such as a variation selector or zero width joiner
+func isEmojiRune(r rune) bool {
+	switch {
+	case unicode.Is(emojiRanges, r):
+		return true
+	case r == 0x200d, r >= 0xfe00 && r <= 0xfe0f:
+		return true
+	}
+	return false
+}
+
+// stripEmoji removes leading emoji and emoji shortcodes from a commit subject
+func stripEmoji(subject string) string {
+	s := subject
+	for {
+		s = strings.TrimLeft(s, " ")
+		if code := emojiShortcode.FindString(s); code != "" {
+			s = s[len(code):]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		if size == 0 || !isEmojiRune(r) {
+			break
+		}
+		s = s[size:]
+	}
+
+	// A subject that is nothing but emoji is left alone
+	if s == "" {
+		return subject
+	}
+	return s
+}
+
+// displaySubject returns a commit subject as shown in text output
+func (g *GitSearchTool) displaySubject(subject string) string {
+	if g.stripEmoji {
+		return stripEmoji(subject)
+	}
+	return subject
+}
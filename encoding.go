@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// logEncoding asks git log to re-encode messages from their commit's encoding
+// header to UTF-8, whatever i18n.logOutputEncoding says
+const logEncoding = "--encoding=UTF-8"
+
+// toUTF8 returns s unchanged when it is valid UTF-8. Otherwise it comes from a
+// commit git couldn't re-encode, typically Latin-1 text committed without an
+// encoding header, and each invalid byte is decoded as Latin-1
+func toUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError && size == 1 {
+			sb.WriteRune(rune(s[0]))
+		} else {
+			sb.WriteString(s[:size])
+		}
+		s = s[size:]
+	}
+	return sb.String()
+}
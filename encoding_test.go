@@ -0,0 +1,61 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"plain ascii", "plain ascii"},
+		{"déjà vu", "déjà vu"},
+		{"caf\xe9", "café"},
+		{"na\xefve \xa9 2024", "naïve © 2024"},
+		// Valid sequences are kept when only some bytes are invalid
+		{"\xe9t\xe9 – été", "été – été"},
+	}
+	for _, tt := range tests {
+		if got := toUTF8(tt.in); got != tt.want {
+			t.Errorf("toUTF8(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLatin1CommitSearch(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Initial commit", "README", "readme\n")
+	// One commit declares its Latin-1 encoding in its header, the other was
+	// made by a tool that didn't
+	r.git("-c", "i18n.commitEncoding=ISO-8859-1", "commit", "-q", "--allow-empty", "-m", "Fix caf\xe9 menu")
+	r.git("commit", "-q", "--allow-empty", "-m", "Update caf\xe9 prices")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"menu", []string{"Fix café menu"}},
+		{"prices", []string{"Update café prices"}},
+		{"café menu", []string{"Fix café menu"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			commits, err := r.tool().searchInCommitHistory(SearchOptions{Query: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := commitSubjects(commits)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+			for _, subject := range got {
+				if !utf8.ValidString(subject) {
+					t.Errorf("subject %q isn't valid UTF-8", subject)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// locateMatch finds where a git pattern matches text, first as a literal and
+// then as a case-insensitive regular expression like git's own matching; the
+// returned column is 1-based in runes and how says which interpretation hit
+func locateMatch(text, pattern string) (column int, how string, ok bool) {
+	if start, _ := indexRunes(text, pattern, false); start >= 0 {
+		return start + 1, "literal", true
+	}
+
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return 0, "", false
+	}
+	loc := re.FindStringIndex(text)
+	if loc == nil {
+		return 0, "", false
+	}
+	return len([]rune(text[:loc[0]])) + 1, "regex", true
+}
+
+// explainCommit describes which part of a commit message matched the query
+func explainCommit(commit CommitMatch, query string) string {
+	if column, how, ok := locateMatch(commit.Subject, query); ok {
+		return fmt.Sprintf("subject matched %q (%s) at column %d", query, how, column)
+	}
+
+	for i, line := range strings.Split(commit.Body, "\n") {
+		if column, how, ok := locateMatch(line, query); ok {
+			return fmt.Sprintf("body line %d matched %q (%s) at column %d", i+1, query, how, column)
+		}
+	}
+
+	return fmt.Sprintf("message matched %q using git's pattern rules", query)
+}
+
+// filePatternsOf returns the patterns a file search matches lines with: the
+// query terms, or every pattern of the -expr expression
+func (g *GitSearchTool) filePatternsOf(query string) []string {
+	if len(g.fileExprArgs) == 0 {
+		return g.queryTerms(query)
+	}
+
+	var patterns []string
+	for i, arg := range g.fileExprArgs {
+		if arg == "-e" && i+1 < len(g.fileExprArgs) {
+			patterns = append(patterns, g.fileExprArgs[i+1])
+		}
+	}
+	return patterns
+}
+
+// explainFileMatch describes which patterns matched a file line and where
+func (g *GitSearchTool) explainFileMatch(content, query string) string {
+	patterns := g.filePatternsOf(query)
+
+	var reasons []string
+	for _, pattern := range patterns {
+		if column, how, ok := locateMatch(content, pattern); ok {
+			reasons = append(reasons, fmt.Sprintf("%q (%s) at column %d", pattern, how, column))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return "line matched using git's pattern rules"
+	}
+	if len(g.fileExprArgs) > 0 {
+		return fmt.Sprintf("expression %q satisfied by %s", g.fileExpr, strings.Join(reasons, ", "))
+	}
+	return "matched " + reasons[0]
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// A grep expression is a small boolean language over search patterns that is
+// translated into git grep's -e/--and/--or/--not arguments:
+//
+//	expr    = or
+//	or      = and { "OR" and }
+//	and     = not { "AND" not }
+//	not     = "NOT" not | primary
+//	primary = "(" expr ")" | pattern
+//	pattern = word | '"' chars '"'
+//
+// Operators must be written in upper case so that lower-case "and", "or" and
+// "not" can still be searched for. NOT binds tighter than AND, which binds
+// tighter than OR. Quoted patterns may contain spaces, parentheses and
+// escaped quotes (\").
+
+type exprKind int
+
+const (
+	exprPattern exprKind = iota
+	exprNot
+	exprAnd
+	exprOr
+)
+
+type exprNode struct {
+	kind        exprKind
+	pattern     string
+	left, right *exprNode
+}
+
+type exprToken struct {
+	text   string
+	quoted bool
+}
+
+// parseGrepExpr parses a grep expression and returns the equivalent git grep
+// arguments
+func parseGrepExpr(input string) ([]string, error) {
+	tokens, err := tokenizeGrepExpr(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at token %d", p.tokens[p.pos].text, p.pos+1)
+	}
+
+	return node.args(), nil
+}
+
+// tokenizeGrepExpr splits an expression into words, quoted patterns and
+// parentheses
+func tokenizeGrepExpr(input string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, exprToken{text: string(r)})
+			i++
+		case r == '"':
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '"' {
+					sb.WriteRune('"')
+					i += 2
+					continue
+				}
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quoted pattern")
+			}
+			if sb.Len() == 0 {
+				return nil, fmt.Errorf("empty quoted pattern")
+			}
+			tokens = append(tokens, exprToken{text: sb.String(), quoted: true})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '"' {
+				i++
+			}
+			tokens = append(tokens, exprToken{text: string(runes[start:i])})
+		}
+	}
+
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+// isOperator reports whether the next token is the given unquoted keyword
+func (p *exprParser) isOperator(op string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	tok := p.tokens[p.pos]
+	return !tok.quoted && tok.text == op
+}
+
+func (p *exprParser) parseOr() (*exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{kind: exprOr, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (*exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{kind: exprAnd, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (*exprNode, error) {
+	if p.isOperator("NOT") {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNode{kind: exprNot, left: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (*exprNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	tok := p.tokens[p.pos]
+	if !tok.quoted {
+		switch tok.text {
+		case "(":
+			p.pos++
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOperator(")") {
+				return nil, fmt.Errorf("missing closing parenthesis")
+			}
+			p.pos++
+			return node, nil
+		case ")", "AND", "OR", "NOT":
+			return nil, fmt.Errorf("unexpected %q at token %d", tok.text, p.pos+1)
+		}
+	}
+
+	p.pos++
+	return &exprNode{kind: exprPattern, pattern: tok.text}, nil
+}
+
+// args renders the node as git grep arguments, grouping compound operands in
+// parentheses so git's own precedence rules never come into play
+func (n *exprNode) args() []string {
+	switch n.kind {
+	case exprNot:
+		return append([]string{"--not"}, n.left.groupedArgs()...)
+	case exprAnd:
+		args := append(n.left.groupedArgs(), "--and")
+		return append(args, n.right.groupedArgs()...)
+	case exprOr:
+		args := append(n.left.groupedArgs(), "--or")
+		return append(args, n.right.groupedArgs()...)
+	default:
+		return []string{"-e", n.pattern}
+	}
+}
+
+func (n *exprNode) groupedArgs() []string {
+	if n.kind == exprAnd || n.kind == exprOr {
+		args := append([]string{"("}, n.args()...)
+		return append(args, ")")
+	}
+	return n.args()
+}
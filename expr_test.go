@@ -0,0 +1,116 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseGrepExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{`foo`, []string{"-e", "foo"}},
+		{`foo AND bar`, []string{"-e", "foo", "--and", "-e", "bar"}},
+		{`foo OR bar`, []string{"-e", "foo", "--or", "-e", "bar"}},
+		{`NOT foo`, []string{"--not", "-e", "foo"}},
+		{`NOT NOT foo`, []string{"--not", "--not", "-e", "foo"}},
+		// NOT binds tighter than AND, which binds tighter than OR
+		{`foo AND NOT bar`, []string{"-e", "foo", "--and", "--not", "-e", "bar"}},
+		{`a OR b AND c`, []string{"-e", "a", "--or", "(", "-e", "b", "--and", "-e", "c", ")"}},
+		{`(a OR b) AND c`, []string{"(", "-e", "a", "--or", "-e", "b", ")", "--and", "-e", "c"}},
+		{`NOT (a AND b)`, []string{"--not", "(", "-e", "a", "--and", "-e", "b", ")"}},
+		{`a AND b AND c`, []string{"(", "-e", "a", "--and", "-e", "b", ")", "--and", "-e", "c"}},
+		// Lower-case and quoted operators are patterns
+		{`"AND" OR or`, []string{"-e", "AND", "--or", "-e", "or"}},
+		{`"two words" AND "(paren)"`, []string{"-e", "two words", "--and", "-e", "(paren)"}},
+		{`"say \"hi\""`, []string{"-e", `say "hi"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := parseGrepExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("parseGrepExpr(%q): %v", tt.expr, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseGrepExpr(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGrepExprErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{``, "empty expression"},
+		{`   `, "empty expression"},
+		{`foo AND`, "unexpected end of expression"},
+		{`AND foo`, `unexpected "AND" at token 1`},
+		{`foo bar`, `unexpected "bar" at token 2`},
+		{`error and`, `unexpected "and" at token 2`},
+		{`(foo OR bar`, "missing closing parenthesis"},
+		{`foo)`, `unexpected ")" at token 2`},
+		{`"foo`, "unterminated quoted pattern"},
+		{`""`, "empty quoted pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := parseGrepExpr(tt.expr)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("parseGrepExpr(%q) error = %v, want %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprSearch(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add files",
+		"a.txt", "foo one\nfoo bar\nbar only\n",
+		"b.txt", "foo here\n",
+		"c.txt", "nothing\n")
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"foo AND NOT bar", []string{"1. a.txt:1:foo one", "2. b.txt:1:foo here"}},
+		{"foo AND bar", []string{"1. a.txt:2:foo bar"}},
+		{"bar OR nothing", []string{"1. a.txt:2:foo bar", "2. a.txt:3:bar only", "3. c.txt:1:nothing"}},
+		{`"foo here" OR (only AND NOT foo)`, []string{"1. a.txt:3:bar only", "2. b.txt:1:foo here"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			stdout, stderr, status := r.gst("-quiet", "-expr", tt.expr)
+			if status != exitMatch {
+				t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+			}
+			if got := strings.Split(strings.TrimSpace(stdout), "\n"); !slices.Equal(got, tt.want) {
+				t.Errorf("output lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Without -quiet the results are headed by the expression and end with
+	// the summary, with no commit section as expressions only search files
+	stdout, _, _ := r.gst("-no-banner", "-expr", "foo AND NOT bar")
+	for _, want := range []string{
+		`=== Search Results for expression: "foo AND NOT bar" ===`,
+		"--- File Contents ---",
+		"Found 0 commit matches and 2 file matches across 2 files.",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output is missing %q:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "Commit") {
+		t.Errorf("expression search has a commit section:\n%s", stdout)
+	}
+
+	if _, stderr, status := r.gst("-quiet", "-expr", "foo AND"); status != exitError || !strings.Contains(stderr, "invalid -expr: unexpected end of expression") {
+		t.Errorf("invalid expression: status %d, stderr %q", status, stderr)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// parseSize parses a -max-file-size value, a number of bytes optionally
+// followed by k, M or G (powers of 1024) and a B, such as 500k or 1MB
+func parseSize(s string) (int64, error) {
+	number := strings.TrimSuffix(strings.TrimSuffix(s, "B"), "b")
+	unit := int64(1)
+	if number != "" {
+		switch number[len(number)-1] {
+		case 'k', 'K':
+			unit = 1 << 10
+		case 'm', 'M':
+			unit = 1 << 20
+		case 'g', 'G':
+			unit = 1 << 30
+		}
+		if unit > 1 {
+			number = number[:len(number)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected bytes or a number with k, M or G such as 500k", s)
+	}
+	return n * unit, nil
+}
+
+// fileSizeFilter tells whether the file of a match is larger than
+// -max-file-size, looking up the size of each file once
+type fileSizeFilter struct {
+	g         *GitSearchTool
+	oversized map[string]bool
+}
+
+func (g *GitSearchTool) newFileSizeFilter() *fileSizeFilter {
+	return &fileSizeFilter{g: g, oversized: make(map[string]bool)}
+}
+
+// skip reports whether match is in a file above the size limit. Files whose
+// size can't be found are kept.
+func (f *fileSizeFilter) skip(match FileMatch) bool {
+	if f.g.maxFileSize <= 0 {
+		return false
+	}
+
+	key := match.Tree + ":" + match.Path
+	oversized, ok := f.oversized[key]
+	if !ok {
+		size, err := f.g.fileSize(match)
+		oversized = err == nil && size > f.g.maxFileSize
+		f.oversized[key] = oversized
+	}
+	return oversized
+}
+
+// fileSize returns the size of the file of a match: of the blob in the
+// searched tree, or of the file in the working tree
+func (g *GitSearchTool) fileSize(match FileMatch) (int64, error) {
+	if match.Tree == "" {
+		info, err := os.Stat(filepath.Join(g.repoPath, match.Path))
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+
+	output, err := g.run(g.gitCommand("cat-file", "-s", match.Tree+":"+match.Path))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+}
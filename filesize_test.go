@@ -0,0 +1,80 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"2048", 2048, false},
+		{"500k", 500 << 10, false},
+		{"500KB", 500 << 10, false},
+		{"1M", 1 << 20, false},
+		{"1mb", 1 << 20, false},
+		{"2G", 2 << 30, false},
+		{"", 0, true},
+		{"k", 0, true},
+		{"-1k", 0, true},
+		{"1.5M", 0, true},
+		{"10T", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMaxFileSize(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add files",
+		"small.go", "// generated marker\n",
+		"large.go", strings.Repeat("// generated marker\n", 200))
+	r.git("tag", "v1")
+	// The working tree version of large.go shrinks after the tag
+	r.commit("Shrink large", "large.go", "// generated marker\n")
+
+	tests := []struct {
+		name    string
+		maxSize int64
+		at      string
+		want    []string
+	}{
+		{"no limit", 0, "v1", []string{"large.go", "small.go"}},
+		{"in a tree", 1 << 10, "v1", []string{"small.go"}},
+		{"working tree", 1 << 10, "", []string{"large.go", "small.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := r.tool()
+			g.maxFileSize, g.at = tt.maxSize, tt.at
+			matches, err := g.searchInFiles(SearchOptions{Query: "generated", MaxResults: 1000})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := slices.Compact(matchPaths(matches)); !slices.Equal(got, tt.want) {
+				t.Errorf("files = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	r.write("large.go", strings.Repeat("// generated marker\n", 200))
+	stdout, stderr, status := r.gst("-quiet", "-head-only", "-max-file-size", "1k", "-query", "generated")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	if stdout != "1. small.go:1:// generated marker\n" {
+		t.Errorf("output = %q, want only the small file", stdout)
+	}
+	if _, stderr, status := r.gst("-max-file-size", "1.5M", "-query", "generated"); status != exitError {
+		t.Errorf("-max-file-size 1.5M: exit status %d; stderr:\n%s", status, stderr)
+	}
+}
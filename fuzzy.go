@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// fuzzyMinScore is the score a subject needs to be a fuzzy match
+const fuzzyMinScore = 0.6
+
+// fuzzyScore scores how well a commit subject matches a query, between 0
+// and 1. Every query word is scored by its most similar subject word, so
+// typos, reordered words and extra words in the subject cost little while
+// each missing word costs its share.
+func fuzzyScore(query, subject string) float64 {
+	queryWords, subjectWords := strings.Fields(query), strings.Fields(subject)
+	if len(queryWords) == 0 || len(subjectWords) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, queryWord := range queryWords {
+		best := 0.0
+		for _, subjectWord := range subjectWords {
+			best = max(best, nameSimilarity(queryWord, subjectWord))
+		}
+		total += best
+	}
+	return total / float64(len(queryWords))
+}
+
+// searchFuzzyCommits ranks the subjects of the most recent fuzzyWindow
+// commits by their fuzzy score against the query terms, best first, using
+// the best scoring term (the worst with -match all)
+func (g *GitSearchTool) searchFuzzyCommits(opts SearchOptions) ([]CommitMatch, error) {
+	window := opts
+	window.MaxResults = g.fuzzyWindow
+	commits, err := g.logCommits(nil, window, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search commit history: %v", err)
+	}
+
+	var matches []CommitMatch
+	for _, commit := range commits {
+		terms := g.queryTerms(opts.Query)
+		score := fuzzyScore(terms[0], commit.Subject)
+		for _, term := range terms[1:] {
+			if g.matchAll {
+				score = min(score, fuzzyScore(term, commit.Subject))
+			} else {
+				score = max(score, fuzzyScore(term, commit.Subject))
+			}
+		}
+		if score >= fuzzyMinScore {
+			commit.Score = score
+			matches = append(matches, commit)
+		}
+	}
+
+	// Equal scores keep git's newest first order
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > opts.MaxResults {
+		matches = matches[:opts.MaxResults]
+	}
+	return matches, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	query := "fix login redirect"
+	exact := fuzzyScore(query, "Fix login redirect")
+	transposed := fuzzyScore(query, "Fix lgoin redirect")
+	missing := fuzzyScore(query, "Fix redirect")
+	unrelated := fuzzyScore(query, "Update readme badges")
+
+	if exact != 1 {
+		t.Errorf("exact subject scores %.2f, want 1", exact)
+	}
+	if !(exact > transposed && transposed > missing && missing > unrelated) {
+		t.Errorf("scores exact %.2f, transposition %.2f, missing word %.2f, unrelated %.2f aren't in that order",
+			exact, transposed, missing, unrelated)
+	}
+	if transposed < fuzzyMinScore || missing < fuzzyMinScore || unrelated >= fuzzyMinScore {
+		t.Errorf("with a minimum of %.2f: transposition %.2f and missing word %.2f should match, unrelated %.2f shouldn't",
+			fuzzyMinScore, transposed, missing, unrelated)
+	}
+	for _, subject := range []string{"", "   "} {
+		if score := fuzzyScore(query, subject); score != 0 {
+			t.Errorf("fuzzyScore(%q, %q) = %.2f, want 0", query, subject, score)
+		}
+	}
+}
+
+func TestSearchFuzzyCommits(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Fix redirect")
+	r.commit("Update readme badges")
+	r.commit("Fix lgoin redirect")
+	r.commit("Bump dependencies")
+
+	g := r.tool()
+	g.fuzzy, g.fuzzyWindow = true, 100
+	commits, err := g.searchInCommitHistory(SearchOptions{Query: "fix login redirect"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Fix lgoin redirect", "Fix redirect"}
+	if got := commitSubjects(commits); !slices.Equal(got, want) {
+		t.Errorf("subjects = %q, want %q", got, want)
+	}
+	for _, commit := range commits {
+		if commit.Score < fuzzyMinScore || commit.Score > 1 {
+			t.Errorf("%q scored %.2f", commit.Subject, commit.Score)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// historyFileName is the file in the home directory that keeps the queries
+// of past interactive sessions
+const historyFileName = ".gst_history"
+
+// searchHistory is the list of queries entered in interactive sessions,
+// oldest first, persisted to a file of at most size lines
+type searchHistory struct {
+	path    string
+	size    int
+	entries []string
+}
+
+// defaultHistoryPath returns the history file in the user's home directory
+func defaultHistoryPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %v", err)
+	}
+	return filepath.Join(home, historyFileName), nil
+}
+
+// loadHistory reads the history file at path, which need not exist yet
+func loadHistory(path string, size int) (*searchHistory, error) {
+	h := &searchHistory{path: path, size: size}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return h, nil
+		}
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	h.entries = trimHistory(h.entries, size)
+	return h, nil
+}
+
+// trimHistory keeps the last size entries
+func trimHistory(entries []string, size int) []string {
+	if len(entries) > size {
+		return entries[len(entries)-size:]
+	}
+	return entries
+}
+
+// add appends a query unless it repeats the previous one and writes the
+// history file, so that it survives the session being killed
+func (h *searchHistory) add(query string) error {
+	if n := len(h.entries); n > 0 && h.entries[n-1] == query {
+		return nil
+	}
+	h.entries = trimHistory(append(h.entries, query), h.size)
+	return h.save()
+}
+
+// save writes the history file, one query per line
+func (h *searchHistory) save() error {
+	data := strings.Join(h.entries, "\n") + "\n"
+	if err := os.WriteFile(h.path, []byte(data), 0o600); err != nil {
+		return fmt.Errorf("failed to save history: %v", err)
+	}
+	return nil
+}
+
+// recall returns the query of a "!N" history reference
+func (h *searchHistory) recall(ref string) (string, bool) {
+	var n int
+	if _, err := fmt.Sscanf(ref, "!%d", &n); err != nil || n < 1 || n > len(h.entries) {
+		return "", false
+	}
+	return h.entries[n-1], true
+}
+
+// display prints the history, numbered for recall with "!N"
+func (h *searchHistory) display() {
+	if len(h.entries) == 0 {
+		fmt.Println("No search history yet.")
+		return
+	}
+	for i, query := range h.entries {
+		fmt.Printf("%4d  %s\n", i+1, query)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTrimHistory(t *testing.T) {
+	tests := []struct {
+		entries []string
+		size    int
+		want    []string
+	}{
+		{nil, 3, nil},
+		{[]string{"a", "b"}, 3, []string{"a", "b"}},
+		{[]string{"a", "b", "c"}, 3, []string{"a", "b", "c"}},
+		{[]string{"a", "b", "c", "d", "e"}, 3, []string{"c", "d", "e"}},
+		{[]string{"a", "b"}, 1, []string{"b"}},
+	}
+	for _, tt := range tests {
+		if got := trimHistory(tt.entries, tt.size); !slices.Equal(got, tt.want) {
+			t.Errorf("trimHistory(%q, %d) = %q, want %q", tt.entries, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryLoadSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyFileName)
+
+	// A missing file is an empty history
+	h, err := loadHistory(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.entries) != 0 {
+		t.Fatalf("entries of a missing file = %q, want none", h.entries)
+	}
+
+	for _, query := range []string{"alpha", "beta", "beta", "gamma", "delta"} {
+		if err := h.add(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Repeats of the previous query are dropped and only the last 3 kept
+	want := []string{"beta", "gamma", "delta"}
+	if !slices.Equal(h.entries, want) {
+		t.Errorf("entries = %q, want %q", h.entries, want)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "beta\ngamma\ndelta\n" {
+		t.Errorf("history file = %q", got)
+	}
+
+	// A later session reads it back, trimmed to its own size
+	h, err = loadHistory(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gamma", "delta"}; !slices.Equal(h.entries, want) {
+		t.Errorf("reloaded entries = %q, want %q", h.entries, want)
+	}
+
+	// Blank lines, e.g. from editing the file by hand, are skipped
+	if err := os.WriteFile(path, []byte("one\n\ntwo\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if h, err = loadHistory(path, 10); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"one", "two"}; !slices.Equal(h.entries, want) {
+		t.Errorf("entries with blank lines = %q, want %q", h.entries, want)
+	}
+}
+
+func TestHistoryLoadError(t *testing.T) {
+	// A directory can't be read as a history file
+	if _, err := loadHistory(t.TempDir(), 10); err == nil {
+		t.Error("loadHistory of a directory succeeded")
+	}
+}
+
+func TestHistoryRecall(t *testing.T) {
+	h := &searchHistory{entries: []string{"alpha", "beta", "gamma"}}
+	tests := []struct {
+		ref    string
+		want   string
+		wantOK bool
+	}{
+		{"!1", "alpha", true},
+		{"!3", "gamma", true},
+		{"!0", "", false},
+		{"!4", "", false},
+		{"!-1", "", false},
+		{"!x", "", false},
+		{"1", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := h.recall(tt.ref)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("recall(%q) = %q, %v, want %q, %v", tt.ref, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
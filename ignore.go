@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// ignoreFileName is the file of the repository root listing paths that file
+// searches leave out, on top of .gitignore
+const ignoreFileName = ".gstignore"
+
+// readIgnoreFile turns the glob patterns of an ignore file, one per line,
+// into exclude pathspecs relative to the repository root. Blank lines and
+// lines starting with # are skipped. As in .gitignore, a pattern without a
+// slash but at its end matches in any directory, and a pattern matching a
+// directory excludes all of its files. A missing file excludes nothing.
+func readIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var specs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		// A trailing slash only matches directories
+		dirOnly := strings.HasSuffix(pattern, "/")
+		pattern = strings.TrimSuffix(pattern, "/")
+		if strings.Contains(pattern, "/") {
+			pattern = strings.TrimPrefix(pattern, "/")
+		} else {
+			pattern = "**/" + pattern
+		}
+		if !dirOnly {
+			specs = append(specs, ":(top,exclude,glob)"+pattern)
+		}
+		if !strings.HasSuffix(pattern, "/**") {
+			specs = append(specs, ":(top,exclude,glob)"+pattern+"/**")
+		}
+	}
+	return specs, scanner.Err()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadIgnoreFile(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add sources",
+		"api/service.go", "token\n",
+		"api/service.pb.go", "token\n",
+		"vendor/lib/lib.go", "token\n",
+		"src/vendor/dep.go", "token\n",
+		"tools/vendor", "token\n",
+		"build/out.go", "token\n",
+		"src/build/keep.go", "token\n",
+		"a/b/c.go", "token\n",
+		"x/a/b/d.go", "token\n",
+		"docs/guide.md", "token\n",
+		"docs/api/ref.md", "token\n",
+		"testdata/in.txt", "token\n")
+	all := []string{
+		"a/b/c.go", "api/service.go", "api/service.pb.go", "build/out.go", "docs/api/ref.md", "docs/guide.md",
+		"src/build/keep.go", "src/vendor/dep.go", "testdata/in.txt", "tools/vendor", "vendor/lib/lib.go", "x/a/b/d.go",
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"empty", "", all},
+		{"comments and blank lines", "# generated code\n\n   \n", all},
+		{"file pattern", "*.pb.go\n", slices.DeleteFunc(slices.Clone(all), func(p string) bool { return p == "api/service.pb.go" })},
+		// A directory name excludes its files at any depth, and a file of
+		// that name
+		{"name", "vendor\n", []string{
+			"a/b/c.go", "api/service.go", "api/service.pb.go", "build/out.go", "docs/api/ref.md", "docs/guide.md",
+			"src/build/keep.go", "testdata/in.txt", "x/a/b/d.go",
+		}},
+		{"directory name", "vendor/\n", []string{
+			"a/b/c.go", "api/service.go", "api/service.pb.go", "build/out.go", "docs/api/ref.md", "docs/guide.md",
+			"src/build/keep.go", "testdata/in.txt", "tools/vendor", "x/a/b/d.go",
+		}},
+		// A leading or inner slash anchors the pattern at the root
+		{"anchored", "/build\na/b\ndocs/\ntestdata/**\n", []string{
+			"api/service.go", "api/service.pb.go", "src/build/keep.go", "src/vendor/dep.go", "tools/vendor",
+			"vendor/lib/lib.go", "x/a/b/d.go",
+		}},
+		{"glob in a directory", "docs/*.md\n", slices.DeleteFunc(slices.Clone(all), func(p string) bool { return p == "docs/guide.md" })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ignoreFileName)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			specs, err := readIgnoreFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output := r.git(append([]string{"grep", "-l", "token", "--", "."}, specs...)...)
+			if got := strings.Fields(output); !slices.Equal(got, tt.want) {
+				t.Errorf("git grep with %q found %q, want %q", specs, got, tt.want)
+			}
+		})
+	}
+
+	if got, err := readIgnoreFile(filepath.Join(t.TempDir(), ignoreFileName)); got != nil || err != nil {
+		t.Errorf("readIgnoreFile() of a missing file = %q, %v, want nothing", got, err)
+	}
+}
+
+func TestIgnoreFile(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add sources",
+		"api/service.go", "// token handling\n",
+		"api/service.pb.go", "// token generated\n",
+		"vendor/lib/lib.go", "// token vendored\n",
+		"docs/token.md", "token docs\n")
+
+	tests := []struct {
+		name   string
+		ignore *string
+		dir    string
+		want   []string
+	}{
+		{"missing", nil, "", []string{"api/service.go", "api/service.pb.go", "docs/token.md", "vendor/lib/lib.go"}},
+		{"empty", new(string), "", []string{"api/service.go", "api/service.pb.go", "docs/token.md", "vendor/lib/lib.go"}},
+		{"patterns", ptr("*.pb.go\nvendor/\n"), "", []string{"api/service.go", "docs/token.md"}},
+		// Patterns are relative to the root when searching from a
+		// subdirectory too
+		{"from a subdirectory", ptr("/docs/\n*.pb.go\n"), "api", []string{"api/service.go", "vendor/lib/lib.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(r.dir, ignoreFileName)
+			os.Remove(path)
+			if tt.ignore != nil {
+				r.write(ignoreFileName, *tt.ignore)
+			}
+			stdout, stderr, status := runGst(t, filepath.Join(r.dir, tt.dir), "-quiet", "-head-only", "-query", "token")
+			if status != exitMatch {
+				t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+			}
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+				_, match, _ := strings.Cut(line, ". ")
+				file, _, _ := strings.Cut(match, ":")
+				got = append(got, file)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("files = %q, want %q:\n%s", got, tt.want, stdout)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// interruptHandler turns Ctrl-C into canceling the running search. Outside
+// of a search, e.g. at the interactive prompt, it calls idle instead.
+type interruptHandler struct {
+	mu          sync.Mutex
+	cancel      context.CancelFunc
+	interrupted bool
+}
+
+// handleInterrupts catches SIGINT for the rest of the process
+func handleInterrupts(idle func()) *interruptHandler {
+	h := &interruptHandler{}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		for range signals {
+			h.mu.Lock()
+			cancel := h.cancel
+			if cancel != nil {
+				h.interrupted = true
+			}
+			h.mu.Unlock()
+
+			if cancel != nil {
+				cancel()
+			} else {
+				idle()
+			}
+		}
+	}()
+	return h
+}
+
+// cancelOnInterrupt makes Ctrl-C cancel the git commands of the current
+// search, up to the returned function being called
+func (g *GitSearchTool) cancelOnInterrupt() func() {
+	h := g.interrupts
+	if h == nil {
+		return func() {}
+	}
+
+	parent := g.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	g.ctx = ctx
+	h.mu.Lock()
+	h.cancel, h.interrupted = cancel, false
+	h.mu.Unlock()
+	return func() {
+		h.mu.Lock()
+		h.cancel = nil
+		h.mu.Unlock()
+		cancel()
+	}
+}
+
+// interrupted reports whether Ctrl-C canceled the last search
+func (g *GitSearchTool) interrupted() bool {
+	if g.interrupts == nil {
+		return false
+	}
+	g.interrupts.mu.Lock()
+	defer g.interrupts.mu.Unlock()
+	return g.interrupts.interrupted
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCancelOnInterrupt(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser")
+	g := r.tool()
+	g.interrupts = &interruptHandler{}
+
+	stop := g.cancelOnInterrupt()
+	// What the handler does on SIGINT during a search
+	g.interrupts.mu.Lock()
+	cancel := g.interrupts.cancel
+	g.interrupts.interrupted = true
+	g.interrupts.mu.Unlock()
+	cancel()
+
+	start := time.Now()
+	if _, err := g.run(g.gitCommand("log")); err == nil {
+		t.Error("git command of a canceled search succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("canceled git command took %s", elapsed)
+	}
+	if !g.interrupted() || g.exitStatus() != exitInterrupted {
+		t.Errorf("interrupted() = %v, exit status %d, want true and %d", g.interrupted(), g.exitStatus(), exitInterrupted)
+	}
+	stop()
+
+	// The next search starts afresh
+	g.ctx = nil
+	stop = g.cancelOnInterrupt()
+	defer stop()
+	if g.interrupted() {
+		t.Error("interrupted() is still true in the next search")
+	}
+	if _, err := g.run(g.gitCommand("log")); err != nil {
+		t.Errorf("git command of the next search: %v", err)
+	}
+}
+
+// interruptGst starts the gst command line with a git whose grep hangs,
+// writing input to it, and sends it SIGINT once the first search reached git
+// grep. It returns what gst wrote and its exit status.
+func interruptGst(t *testing.T, r *testRepo, input string, args ...string) (stdout, stderr string, status int) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGINT can't be sent on Windows")
+	}
+	started := filepath.Join(t.TempDir(), "started")
+	hang := fakeGit(t, `if [ "$1" = grep ] && [ ! -e `+shellQuote([]string{started})+`.done ]; then
+	touch `+shellQuote([]string{started})+`
+	exec sleep 10
+fi
+exec "$realGit" "$@"
+`)
+
+	cmd := exec.Command(os.Args[0], append([]string{"-git-bin", hang}, args...)...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), "GST_TEST_MAIN=1")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut strings.Builder
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(stdin, input)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(started); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			cmd.Wait()
+			t.Fatalf("git grep didn't start; stderr:\n%s", errOut.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Later searches run git grep for real
+	if err := os.Rename(started, started+".done"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	stdin.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		<-done
+		t.Fatalf("gst didn't exit after SIGINT; stderr:\n%s", errOut.String())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		status = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), status
+}
+
+func TestInterrupt(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser", "parser.go", "package parser\n")
+
+	// A single search stops with the status of a process killed by SIGINT
+	_, stderr, status := interruptGst(t, r, "", "-quiet", "-query", "parser")
+	if status != exitInterrupted {
+		t.Errorf("single search: exit status %d, want %d; stderr:\n%s", status, exitInterrupted, stderr)
+	}
+	if !strings.Contains(stderr, "Search interrupted.") || strings.Contains(stderr, "ERROR") {
+		t.Errorf("single search: stderr isn't a plain interruption:\n%s", stderr)
+	}
+
+	// In a session only the search is canceled, and the next one runs
+	stdout, stderr, status := interruptGst(t, r, "parser\nparser\nquit\n", "-no-banner")
+	if status != exitMatch {
+		t.Errorf("session: exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	if !strings.Contains(stderr, "Search interrupted.") {
+		t.Errorf("session: stderr doesn't report the interruption:\n%s", stderr)
+	}
+	if !strings.Contains(stdout, "parser.go:1:package parser") || !strings.Contains(stdout, "Goodbye!") {
+		t.Errorf("session didn't go on to the next search:\n%s", stdout)
+	}
+}
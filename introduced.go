@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// firstIntroduced returns the oldest commit of the searched history whose
+// changes added or removed s, which is the one that introduced it, and
+// false when no commit did
+func (g *GitSearchTool) firstIntroduced(s string) (CommitMatch, bool, error) {
+	// logCommits takes the oldest commits from the whole history when
+	// reversed, rather than the oldest of the newest ones
+	reverse := g.reverse
+	g.reverse = true
+	defer func() { g.reverse = reverse }()
+
+	opts := g.searchOptions(s)
+	opts.MaxResults = 1
+	commits, err := g.searchInDiffs(opts)
+	if err != nil || len(commits) == 0 {
+		return CommitMatch{}, false, err
+	}
+	return commits[0], true, nil
+}
+
+// displayFirstIntroduced prints the details of the commit that introduced
+// s and returns the exit status
+func (g *GitSearchTool) displayFirstIntroduced(s string) int {
+	fmt.Printf("\n%s\n", bold(fmt.Sprintf("=== First Commit Introducing %q ===", s)))
+
+	commit, found, err := g.firstIntroduced(s)
+	if err != nil {
+		errorf("Error searching code changes: %v", err)
+		return exitError
+	}
+	if g.dryRun {
+		return exitMatch
+	}
+	if !found {
+		fmt.Printf("%q not found in history.\n\n", s)
+		return exitNoMatch
+	}
+
+	details, err := g.getCommitDetails(commit.Hash)
+	if err != nil {
+		errorf("Error getting commit details: %v", err)
+		return exitError
+	}
+	fmt.Printf("Hash:    %s\n", yellow(details["hash"]))
+	g.writeCommitDetails(os.Stdout, details)
+	fmt.Println()
+	return exitMatch
+}
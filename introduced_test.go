@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFirstIntroduced(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Initial commit", "main.go", "package main\n")
+	added := r.commit("Add retry budget", "retry.go", "const retryBudget = 3\n")
+	raised := r.commit("Raise retry budget", "retry.go", "const retryBudget = 5\n")
+	r.commit("Remove retry budget", "retry.go", "")
+	// Over -max-commits of newer commits, so that the oldest has to come
+	// from the whole history
+	for range 12 {
+		r.commit("Touch retryBudget docs", "main.go", "package main\n// retryBudget\n")
+		r.commit("Untouch docs", "main.go", "package main\n")
+	}
+
+	tests := []struct {
+		s     string
+		want  string
+		found bool
+	}{
+		{"retryBudget", added, true},
+		{"retryBudget = 5", raised, true},
+		{"retryDeadline", "", false},
+	}
+	for _, tt := range tests {
+		commit, found, err := r.tool().firstIntroduced(tt.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != tt.found || commit.Hash != tt.want {
+			t.Errorf("firstIntroduced(%q) = %s, %v, want %s, %v", tt.s, commit.Hash, found, tt.want, tt.found)
+		}
+	}
+
+	stdout, stderr, status := r.gst("-no-banner", "-first-introduced", "retryBudget")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	for _, want := range []string{"Hash:    " + added, "Author:  Test User <test@example.com>", "Subject: Add retry budget"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output is missing %q:\n%s", want, stdout)
+		}
+	}
+
+	stdout, _, status = r.gst("-no-banner", "-first-introduced", "retryDeadline")
+	if status != exitNoMatch || !strings.Contains(stdout, `"retryDeadline" not found in history.`) {
+		t.Errorf("missing string: exit status %d, want %d; stdout:\n%s", status, exitNoMatch, stdout)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// logLevel is the severity of a message written to stderr
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// logLevelNames lists the levels accepted by -log-level, by severity
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+// minLogLevel is the least severe level that is written, set by -log-level
+var minLogLevel = levelInfo
+
+func (l logLevel) String() string {
+	return strings.ToUpper(logLevelNames[l])
+}
+
+// parseLogLevel returns the level named by a -log-level value
+func parseLogLevel(name string) (logLevel, bool) {
+	for i, level := range logLevelNames {
+		if strings.EqualFold(name, level) {
+			return logLevel(i), true
+		}
+	}
+	return 0, false
+}
+
+// logf writes a message to stderr prefixed with its level, unless it is
+// less severe than -log-level
+func logf(level logLevel, format string, args ...any) {
+	if level < minLogLevel {
+		return
+	}
+	log.Printf("%s %s", level, fmt.Sprintf(format, args...))
+}
+
+func debugf(format string, args ...any) { logf(levelDebug, format, args...) }
+func warnf(format string, args ...any)  { logf(levelWarn, format, args...) }
+func errorf(format string, args ...any) { logf(levelError, format, args...) }
+
+// logCommand writes a git command that ran and its exit status at the debug
+// level
+func logCommand(cmd *exec.Cmd, err error) {
+	if minLogLevel > levelDebug {
+		return
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		debugf("%s: exit status 0", shellQuote(cmd.Args))
+	case errors.As(err, &exitErr):
+		debugf("%s: exit status %d", shellQuote(cmd.Args), exitErr.ExitCode())
+	default:
+		debugf("%s: %v", shellQuote(cmd.Args), err)
+	}
+}
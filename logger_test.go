@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		want logLevel
+		ok   bool
+	}{
+		{"debug", levelDebug, true},
+		{"INFO", levelInfo, true},
+		{"Warn", levelWarn, true},
+		{"error", levelError, true},
+		{"warning", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseLogLevel(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("parseLogLevel(%q) = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLogf(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(flags int, level logLevel) {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+		minLogLevel = level
+	}(log.Flags(), minLogLevel)
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+
+	tests := []struct {
+		min  logLevel
+		want string
+	}{
+		{levelDebug, "DEBUG d\nWARN w\nERROR e\n"},
+		{levelInfo, "WARN w\nERROR e\n"},
+		{levelWarn, "WARN w\nERROR e\n"},
+		{levelError, "ERROR e\n"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		minLogLevel = tt.min
+		debugf("d")
+		warnf("w")
+		errorf("e")
+		if got := buf.String(); got != tt.want {
+			t.Errorf("at %v wrote %q, want %q", tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser", "parser.go", "package parser\n")
+	args := []string{"-quiet", "-since", "2024-06-30", "-until", "2024-01-01", "-query", "parser"}
+
+	_, stderr, _ := r.gst(args...)
+	if !strings.Contains(stderr, "WARN -until 2024-01-01 is before -since 2024-06-30") {
+		t.Errorf("warning missing at the default level:\n%s", stderr)
+	}
+	if strings.Contains(stderr, "DEBUG") {
+		t.Errorf("debug messages at the default level:\n%s", stderr)
+	}
+
+	_, stderr, _ = r.gst(append([]string{"-log-level", "error"}, args...)...)
+	if strings.Contains(stderr, "WARN") {
+		t.Errorf("warning written at -log-level error:\n%s", stderr)
+	}
+
+	// Every git command is logged with its exit status at debug
+	_, stderr, _ = r.gst(append([]string{"-log-level", "debug"}, args...)...)
+	for _, want := range []string{"DEBUG git --version: exit status 0", "DEBUG git log ", "DEBUG git grep -n -z -i -e parser: exit status 0"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr at -log-level debug is missing %q:\n%s", want, stderr)
+		}
+	}
+	_, stderr, _ = r.gst("-quiet", "-log-level", "debug", "-query", "lexer")
+	if !strings.Contains(stderr, "DEBUG git grep -n -z -i -e lexer: exit status 1") {
+		t.Errorf("git grep finding nothing isn't logged with its exit status:\n%s", stderr)
+	}
+
+	if _, stderr, status := r.gst("-log-level", "verbose", "-query", "parser"); status != exitError || !strings.Contains(stderr, `unknown -log-level "verbose"`) {
+		t.Errorf("-log-level verbose: exit status %d; stderr:\n%s", status, stderr)
+	}
+}
@@ -2,60 +2,645 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 type GitSearchTool struct {
 	repoPath string
+
+	// fileExpr is the -expr source and fileExprArgs its git grep translation
+	fileExpr     string
+	fileExprArgs []string
+
+	// headOnly restricts searches to the current tree so no history is walked
+	headOnly bool
+
+	// symbols annotates file matches with their enclosing ctags definition
+	symbols bool
+
+	// revRange limits commit searches to a revision range such as "v1.0..HEAD"
+	revRange string
+
+	// noiseThreshold dims file matches from files with more hits than this
+	// when writing to a terminal, 0 disables dimming
+	noiseThreshold int
+
+	// maxFileSize drops the file matches of files larger than this many
+	// bytes, 0 keeps all of them
+	maxFileSize int64
+
+	// groupFiles lists file matches under a header per file instead of
+	// prefixing each line with its path
+	groupFiles bool
+
+	// fileSort orders file matches by path or by matches per file, "none"
+	// keeps the order git grep found them in
+	fileSort string
+
+	// pathspecs restrict file searches to matching paths
+	pathspecs []string
+
+	// ignoreSpecs are the exclude pathspecs of the .gstignore file, added
+	// to every file search
+	ignoreSpecs []string
+
+	// filePatterns restrict file searches to matching file names within the
+	// pathspecs, e.g. "*.yaml"
+	filePatterns []string
+
+	// textconv runs configured textconv filters before grepping
+	textconv bool
+
+	// includeBinary matches inside files git considers binary, by content
+	// or by a -diff or binary attribute, instead of reporting them as
+	// "Binary file ... matches"
+	includeBinary bool
+
+	// recordCommands keeps the argv of every git command run in the
+	// recorder, and timing how long each of them took
+	recordCommands bool
+	timing         bool
+	recorder       *commandRecorder
+
+	// dryRun prints the git commands of searches to stderr instead of
+	// running them, as if they found nothing
+	dryRun bool
+
+	// noIndex searches a plain directory with git grep --no-index
+	noIndex bool
+
+	// untracked also searches the untracked files of the working tree
+	untracked bool
+
+	// recurseSubmodules also searches the files of checked out submodules
+	recurseSubmodules bool
+
+	// showAheadBehind adds the upstream ahead/behind counts to the banner
+	showAheadBehind bool
+
+	// binaryPreview caps binary file matches to a hex preview of this many
+	// bytes, 0 shows them as-is
+	binaryPreview int
+
+	// truncate shortens file match lines to this many runes around the
+	// match, 0 shows them whole
+	truncate int
+
+	// bodySnippets shows where the query matched in the body of commits
+	// whose subject doesn't contain it
+	bodySnippets bool
+
+	// withStat lists the files each commit of the results changed
+	withStat bool
+
+	// pathPrefix is prepended to file match paths so they resolve from the
+	// invocation directory
+	pathPrefix string
+
+	// explain describes why each result matched
+	explain bool
+
+	// bodyLines shows up to this many commit body lines, 0 keeps bodies
+	// out of commit results
+	bodyLines int
+
+	// minBodyLength drops commits whose body has fewer characters
+	minBodyLength int
+
+	// tree lists matching files as a directory tree instead of match lines
+	tree bool
+
+	// noMerges leaves merge commits out of commit searches and mergesOnly
+	// searches nothing else
+	noMerges   bool
+	mergesOnly bool
+
+	// firstParent only follows the first parent of merges, leaving out the
+	// commits of merged branches
+	firstParent bool
+
+	// follow restricts commit searches to the history of this file,
+	// following it across renames
+	follow string
+
+	// showRenames marks the commits of the results that renamed the follow
+	// file
+	showRenames bool
+
+	// version is the release of gitBin, read at startup
+	version gitVersion
+
+	// invert searches for the commits and file lines that don't match
+	invert bool
+
+	// underline prints a line of carets under the matches of each file
+	// match line
+	underline bool
+
+	// filesOnly lists the paths of matching files instead of match lines
+	filesOnly bool
+
+	// countOnly lists the number of matching lines per file instead of the
+	// lines
+	countOnly bool
+
+	// fallback is searched instead when the query finds nothing
+	fallback string
+
+	// format is the output format of performSearch, switchable interactively
+	format string
+
+	// jsonPretty indents -format json documents for reading
+	jsonPretty bool
+
+	// separator switches text results to one delimited line per commit
+	// and file match, empty for the normal output
+	separator string
+
+	// threads bounds the workers formatting file matches, 1 formats serially
+	threads int
+
+	// ignoreWhitespace makes diff based searches ignore whitespace-only changes
+	ignoreWhitespace bool
+
+	// lastChanges caches the date of the last commit touching each file,
+	// nil until the history has been read
+	lastChanges map[string]time.Time
+
+	// maxResults caps the results emitted by a search across all sections,
+	// 0 disables the cap
+	maxResults int
+
+	// emitted and suppressed count the results of the current search
+	// shown and held back by maxResults
+	emitted    int
+	suppressed int
+
+	// stripEmoji drops leading emoji from displayed commit subjects
+	stripEmoji bool
+
+	// author restricts commit searches to authors matching this pattern
+	author string
+
+	// committer restricts commit searches to committers matching this
+	// pattern
+	committer string
+
+	// committerDates shows the committer dates of commits instead of the
+	// author dates
+	committerDates bool
+
+	// dateFormat is the git --date format of the dates shown for commits
+	// and tags, passed through as git prints them
+	dateFormat string
+
+	// authorRegex restricts commit searches to authors whose "Name <email>"
+	// matches this git regular expression, ignoring case
+	authorRegex string
+
+	// trailers restrict commit searches to commits with all of these
+	// trailers
+	trailers []trailerFilter
+
+	// since and until restrict commit searches to a date window, in any
+	// format git accepts
+	since string
+	until string
+
+	// caseSensitive drops the -i that makes searches ignore case
+	caseSensitive bool
+
+	// commitCaseSensitive and fileCaseSensitive override caseSensitive for
+	// the commit and file sections when not nil
+	commitCaseSensitive *bool
+	fileCaseSensitive   *bool
+
+	// regex matches file contents with extended regular expressions
+	regex bool
+
+	// wholeWord only matches whole words in file contents
+	wholeWord bool
+
+	// allBranches searches the history of every ref instead of HEAD's
+	allBranches bool
+
+	// diffSearch adds the commits whose changes added or removed the query
+	diffSearch bool
+
+	// maxCommits and maxFiles limit the commit and file sections of a search
+	maxCommits int
+	maxFiles   int
+
+	// lineMin and lineMax only keep the file matches within these line
+	// numbers, 0 leaves that end open
+	lineMin int
+	lineMax int
+
+	// pathFilters are extra pathspecs, relative to the repository root,
+	// passed to the file search as given
+	pathFilters []string
+
+	// bodyOnly only matches the query against commit bodies, not subjects
+	bodyOnly bool
+
+	// extraQueries are further search terms given alongside the query
+	extraQueries []string
+
+	// matchAll requires every query term to match instead of any of them
+	matchAll bool
+
+	// blame shows the commit and author that last changed each file match
+	blame bool
+
+	// gitBin is the git executable, looked up on PATH unless it's a path
+	gitBin string
+
+	// timeout bounds the git commands of each search, 0 waits forever
+	timeout time.Duration
+
+	// retries is how many times a git command failing on a lock is run
+	// again
+	retries int
+
+	// ctx carries the deadline of the current search's git commands
+	ctx context.Context
+
+	// interrupts cancels the current search on Ctrl-C, nil when not caught
+	interrupts *interruptHandler
+
+	// checkpoint records the repositories a -multi search finished, nil
+	// without -checkpoint
+	checkpoint *checkpointFile
+
+	// bare is set for repositories without a working tree, whose files are
+	// searched in HEAD instead
+	bare bool
+
+	// at is a tree-ish, such as a tag or an old commit, whose files are
+	// searched instead of the working tree
+	at string
+
+	// shallow is set for shallow clones, whose history is cut off
+	shallow bool
+
+	// failed is set when part of the current search failed
+	failed bool
+
+	// history keeps the queries of interactive sessions, nil when disabled
+	history *searchHistory
+
+	// lastFiles are the file matches listed by the last text search, which
+	// :open refers to by number
+	lastFiles []FileMatch
+
+	// pageSize is the number of lines of interactive results shown before
+	// waiting for Enter, 0 shows them all at once
+	pageSize int
+
+	// searchTags adds the tags whose name or annotation matches the query
+	searchTags bool
+
+	// searchStashes adds the stash entries whose message, or changes with
+	// diffSearch, match the query
+	searchStashes bool
+
+	// commitFormat renders the commits of search results instead of the
+	// built-in line when set
+	commitFormat *template.Template
+
+	// searchNotes adds the commits whose git notes match the query
+	searchNotes bool
+
+	// searchReflog adds the HEAD reflog entries whose message matches the
+	// query
+	searchReflog bool
+
+	// reverse lists the oldest matching commits first
+	reverse bool
+
+	// depth only searches the messages and changes of the last depth
+	// commits, 0 searches the whole history
+	depth int
+
+	// fuzzy ranks the subjects of the last fuzzyWindow commits by their
+	// similarity to the query instead of grepping commit messages
+	fuzzy       bool
+	fuzzyWindow int
+
+	// quiet prints the match lines of a search without any decoration
+	quiet bool
+
+	// info receives the banner and status lines around the results, which
+	// go to stderr when -output sends the results to a file
+	info io.Writer
+}
+
+// stringList is a flag that collects every value it is given
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// isExcludePathspec reports whether a pathspec uses the exclude magic
+func isExcludePathspec(spec string) bool {
+	return strings.HasPrefix(spec, ":!") || strings.HasPrefix(spec, ":^") ||
+		strings.HasPrefix(spec, ":(exclude") || strings.HasPrefix(spec, ":(top,exclude")
+}
+
+// outputFormats lists the supported values for the output format
+var outputFormats = []string{"text", "json", "jsonl", "csv"}
+
+// dateFormats lists the git date formats accepted by -date-format
+var dateFormats = []string{"short", "iso", "relative", "unix"}
+
+// dateOption returns the git log option formatting commit dates
+func (g *GitSearchTool) dateOption() string {
+	return "--date=" + g.dateFormat
 }
 
 func NewGitSearchTool(path string) *GitSearchTool {
 	return &GitSearchTool{
-		repoPath: path,
+		repoPath:   path,
+		format:     "text",
+		dateFormat: "short",
+		threads:    1,
+		maxResults: 1000,
+		maxCommits: 10,
+		maxFiles:   20,
+		gitBin:     "git",
+		info:       os.Stdout,
+		recorder:   &commandRecorder{},
+	}
+}
+
+// gitCommand prepares a git command that runs in the repository, killed
+// when the current search times out
+func (g *GitSearchTool) gitCommand(args ...string) *exec.Cmd {
+	ctx := g.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cmd := exec.CommandContext(ctx, g.gitBin, args...)
+	cmd.Dir = g.repoPath
+	// Don't wait on output pipes held open by anything git spawned
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// run executes a git command and returns its standard output, recording the
+// command line when requested. Commands failing on a lock held by another
+// git process are run again up to -retries times.
+func (g *GitSearchTool) run(cmd *exec.Cmd) ([]byte, error) {
+	output, err := g.runOnce(cmd)
+	for attempt := 0; attempt < g.retries && isRetryable(err); attempt++ {
+		if cmd = g.retryCommand(cmd, attempt); cmd == nil {
+			break
+		}
+		output, err = g.runOnce(cmd)
+	}
+	return output, err
+}
+
+// runOnce executes a git command for run
+func (g *GitSearchTool) runOnce(cmd *exec.Cmd) ([]byte, error) {
+	g.record(cmd)
+	if g.dryRun {
+		fmt.Fprintln(os.Stderr, shellQuote(cmd.Args))
+		return nil, nil
+	}
+
+	done := g.timeCommand(cmd)
+	output, err := cmd.Output()
+	done()
+	logCommand(cmd, err)
+	if err != nil && g.timedOut() {
+		return nil, fmt.Errorf("git command timed out after %s", g.timeout)
+	}
+	return output, err
+}
+
+// commandRecorder collects the git commands run for -debug-json and
+// -timing, guarded by mu as searches run git concurrently
+type commandRecorder struct {
+	mu       sync.Mutex
+	commands [][]string
+	timings  []commandTiming
+}
+
+// record keeps the command line of cmd when requested
+func (g *GitSearchTool) record(cmd *exec.Cmd) {
+	if g.recordCommands {
+		g.recorder.mu.Lock()
+		g.recorder.commands = append(g.recorder.commands, append([]string(nil), cmd.Args...))
+		g.recorder.mu.Unlock()
+	}
+}
+
+// shellQuote formats a command line so that it can be pasted into a shell,
+// single-quoting the arguments that need it
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		unsafe := strings.IndexFunc(arg, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_=./,:@%+^~", r)
+		})
+		if arg != "" && unsafe < 0 {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// timedOut reports whether the current search ran out of time
+func (g *GitSearchTool) timedOut() bool {
+	return g.ctx != nil && errors.Is(g.ctx.Err(), context.DeadlineExceeded)
+}
+
+// startTimeout gives the git commands that follow, up to the returned cancel
+// function being called, the configured timeout between them
+func (g *GitSearchTool) startTimeout() context.CancelFunc {
+	if g.timeout <= 0 {
+		g.ctx = nil
+		return func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	g.ctx = ctx
+	return func() {
+		cancel()
+		g.ctx = nil
+	}
+}
+
+// writeDebugJSON writes the recorded git commands as a JSON document
+func (g *GitSearchTool) writeDebugJSON(w io.Writer) {
+	commands := g.recorder.commands
+	if commands == nil {
+		commands = [][]string{}
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string][][]string{"commands": commands}); err != nil {
+		errorf("Error writing debug JSON: %v", err)
 	}
 }
 
-// isGitRepo checks if the current directory is a git repository
+// isGitRepo checks if the current directory is a git repository, noting
+// whether it is a bare one
 func (g *GitSearchTool) isGitRepo() bool {
 	gitDir := filepath.Join(g.repoPath, ".git")
-	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+	if _, err := os.Stat(gitDir); err == nil {
+		return true
+	}
+
+	cmd := g.gitCommand("rev-parse", "--is-bare-repository")
+	output, err := g.run(cmd)
+	if err != nil || strings.TrimSpace(string(output)) != "true" {
+		return false
+	}
+	g.bare = true
+	return true
+}
+
+// isShallowRepository reports whether the repository is a shallow clone;
+// git versions without --is-shallow-repository echo the option back, so
+// they count as not shallow
+func (g *GitSearchTool) isShallowRepository() bool {
+	cmd := g.gitCommand("rev-parse", "--is-shallow-repository")
+	output, err := g.run(cmd)
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// findWorkTreeRoot moves repoPath up to the top of the working tree when it
+// is a directory below it, reporting whether it did; git walks up the parent
+// directories the same way it does for any command run there
+func (g *GitSearchTool) findWorkTreeRoot() bool {
+	if _, err := os.Stat(filepath.Join(g.repoPath, ".git")); err == nil {
+		return false
+	}
+
+	cmd := g.gitCommand("rev-parse", "--show-toplevel")
+	output, err := g.run(cmd)
+	if err != nil {
+		// Not inside a working tree, isGitRepo tells bare repositories apart
 		return false
 	}
+
+	root := strings.TrimSpace(string(output))
+	if root == "" || root == g.repoPath {
+		return false
+	}
+	g.repoPath = root
 	return true
 }
 
+// bareRevision is the tree searched in bare repositories
+const bareRevision = "HEAD"
+
+// grepRevision returns the tree searched instead of the working tree: the
+// -at tree-ish, HEAD in bare repositories, or "" for the working tree
+func (g *GitSearchTool) grepRevision() string {
+	if g.at != "" {
+		return g.at
+	}
+	if g.bare {
+		return bareRevision
+	}
+	return ""
+}
+
+// splitRevision splits the "<tree>:" prefix git grep puts before paths when
+// searching a revision off a path
+func (g *GitSearchTool) splitRevision(path string) (tree, file string) {
+	if rev := g.grepRevision(); rev != "" {
+		if file, ok := strings.CutPrefix(path, rev+":"); ok {
+			return rev, file
+		}
+	}
+	return "", path
+}
+
+// errNoCommits is returned when the repository has no commits yet
+var errNoCommits = errors.New("no commits yet")
+
+// hasCommits reports whether HEAD points at a commit, which it doesn't in a
+// freshly initialized repository
+func (g *GitSearchTool) hasCommits() bool {
+	cmd := g.gitCommand("rev-parse", "--verify", "--quiet", "HEAD")
+
+	_, err := g.run(cmd)
+	return err == nil
+}
+
+// noCommitsYet reports whether a git command reading the history failed
+// because HEAD has no commits yet. Commands killed by the timeout or Ctrl-C
+// failed for that reason instead, whatever HEAD is.
+func (g *GitSearchTool) noCommitsYet() bool {
+	if g.ctx != nil && g.ctx.Err() != nil {
+		return false
+	}
+	return !g.hasCommits()
+}
+
 // getLastCommitMessage retrieves the last commit message
 func (g *GitSearchTool) getLastCommitMessage() (string, error) {
-	cmd := exec.Command("git", "log", "-1", "--pretty=format:%s")
-	cmd.Dir = g.repoPath
+	cmd := g.gitCommand("log", "-1", logEncoding, "--pretty=format:%s")
 
-	output, err := cmd.Output()
+	output, err := g.run(cmd)
 	if err != nil {
 		return "", fmt.Errorf("failed to get commit message: %v", err)
 	}
 
-	return strings.TrimSpace(string(output)), nil
+	return toUTF8(strings.TrimSpace(string(output))), nil
 }
 
-// getLastCommitDetails retrieves detailed information about the last commit
-func (g *GitSearchTool) getLastCommitDetails() (map[string]string, error) {
-	cmd := exec.Command("git", "log", "-1", "--pretty=format:%H|%an|%ae|%ad|%s|%b", "--date=short")
-	cmd.Dir = g.repoPath
+// getCommitDetails retrieves detailed information about a commit, such as
+// HEAD for the last one
+func (g *GitSearchTool) getCommitDetails(commitish string) (map[string]string, error) {
+	// Fields are separated by US, which unlike "|" can't be part of a
+	// subject or name
+	cmd := g.gitCommand("log", "-1", logEncoding, "--pretty=format:%H%x1f%an%x1f%ae%x1f%ad%x1f%s%x1f%b", g.dateOption(), commitish, "--")
 
-	output, err := cmd.Output()
+	output, err := g.run(cmd)
 	if err != nil {
+		if g.noCommitsYet() {
+			return nil, errNoCommits
+		}
 		return nil, fmt.Errorf("failed to get commit details: %v", err)
 	}
 
-	parts := strings.Split(strings.TrimSpace(string(output)), "|")
+	parts := strings.SplitN(toUTF8(strings.TrimSpace(string(output))), "\x1f", 6)
 	if len(parts) < 5 {
-		return nil, fmt.Errorf("unexpected git log output format")
+		return nil, fmt.Errorf("unexpected git log output format: %q", output)
 	}
 
 	details := map[string]string{
@@ -74,217 +659,2519 @@ func (g *GitSearchTool) getLastCommitDetails() (map[string]string, error) {
 	return details, nil
 }
 
-// searchInCommitHistory searches for a query in commit messages
-func (g *GitSearchTool) searchInCommitHistory(query string, maxResults int) ([]map[string]string, error) {
-	cmd := exec.Command("git", "log", "--grep="+query, "-i",
-		fmt.Sprintf("-%d", maxResults),
-		"--pretty=format:%H|%an|%ad|%s", "--date=short")
-	cmd.Dir = g.repoPath
+// getAheadBehind returns the upstream of the current branch and how many
+// commits HEAD is ahead of and behind it; the upstream is empty when HEAD is
+// detached or the branch doesn't track anything
+func (g *GitSearchTool) getAheadBehind() (branch, upstream string, ahead, behind int, err error) {
+	cmd := g.gitCommand("symbolic-ref", "-q", "--short", "HEAD")
 
-	output, err := cmd.Output()
+	output, err := g.run(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search commit history: %v", err)
+		// symbolic-ref exits with 1 on a detached HEAD
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return "", "", 0, 0, nil
+		}
+		return "", "", 0, 0, fmt.Errorf("failed to get current branch: %v", err)
 	}
+	branch = strings.TrimSpace(string(output))
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var results []map[string]string
+	cmd = g.gitCommand("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	output, err = g.run(cmd)
+	if err != nil {
+		// No upstream configured
+		return branch, "", 0, 0, nil
+	}
+	upstream = strings.TrimSpace(string(output))
 
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
+	cmd = g.gitCommand("rev-list", "--left-right", "--count", "@{upstream}...HEAD")
+	output, err = g.run(cmd)
+	if err != nil {
+		return branch, upstream, 0, 0, fmt.Errorf("failed to count commits against upstream: %v", err)
+	}
 
-		parts := strings.Split(line, "|")
-		if len(parts) >= 4 {
-			result := map[string]string{
-				"hash":    parts[0],
-				"author":  parts[1],
-				"date":    parts[2],
-				"subject": parts[3],
-			}
-			results = append(results, result)
-		}
+	counts := strings.Fields(string(output))
+	if len(counts) != 2 {
+		return branch, upstream, 0, 0, fmt.Errorf("unexpected git rev-list output format")
 	}
+	behind, _ = strconv.Atoi(counts[0])
+	ahead, _ = strconv.Atoi(counts[1])
 
-	return results, nil
+	return branch, upstream, ahead, behind, nil
 }
 
-// searchInFiles searches for a query in tracked files
-func (g *GitSearchTool) searchInFiles(query string, maxResults int) ([]string, error) {
-	cmd := exec.Command("git", "grep", "-n", "-i", "--", query)
-	cmd.Dir = g.repoPath
+// checkRevision rejects a revision given on the command line that git would
+// take for an option, such as "--all" or "-n", as revisions are passed
+// positionally
+func checkRevision(rev string) error {
+	if strings.HasPrefix(rev, "-") {
+		return fmt.Errorf("invalid revision %q, it looks like an option", rev)
+	}
+	return nil
+}
 
-	output, err := cmd.Output()
+// validateRevRange checks that a revision or range such as "v1.0..v2.0" resolves
+func (g *GitSearchTool) validateRevRange(revRange string) error {
+	if strings.HasPrefix(revRange, "-") || strings.ContainsAny(revRange, revRangeMetachars) {
+		return fmt.Errorf("invalid revision range %q", revRange)
+	}
+
+	cmd := g.gitCommand("rev-parse", "--revs-only", revRange, "--")
+	output, err := g.run(cmd)
 	if err != nil {
-		// git grep returns non-zero exit code when no matches found
-		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
-			return []string{}, nil
+		if exitError, ok := err.(*exec.ExitError); ok && len(exitError.Stderr) > 0 {
+			return fmt.Errorf("unknown revision range %q: %s", revRange, strings.TrimSpace(string(exitError.Stderr)))
 		}
-		return nil, fmt.Errorf("failed to search in files: %v", err)
+		return fmt.Errorf("unknown revision range %q", revRange)
 	}
+	if strings.TrimSpace(string(output)) == "" {
+		return fmt.Errorf("unknown revision range %q", revRange)
+	}
+
+	return nil
+}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+// revRangeMetachars are shell metacharacters that never appear in a sensible
+// revision range; git is run without a shell, but ranges containing them are
+// rejected anyway in case they are pasted into one
+const revRangeMetachars = ";&|`$<>()\\\"' \t\n"
 
-	// Limit results
-	if len(lines) > maxResults {
-		lines = lines[:maxResults]
+// validateTreeish checks that the -at tree-ish names a tree
+func (g *GitSearchTool) validateTreeish(treeish string) error {
+	if err := checkRevision(treeish); err != nil {
+		return err
 	}
-
-	return lines, nil
+	cmd := g.gitCommand("rev-parse", "--verify", "--quiet", treeish+"^{tree}")
+	if _, err := g.run(cmd); err != nil {
+		return fmt.Errorf("%s is not a commit or tree in this repository", treeish)
+	}
+	return nil
 }
 
-func (g *GitSearchTool) displayLastCommit() {
-	fmt.Println("=== Last Commit Information ===")
+// getMergeBase computes the best common ancestor of two refs
+func (g *GitSearchTool) getMergeBase(refA, refB string) (string, error) {
+	for _, ref := range []string{refA, refB} {
+		if err := checkRevision(ref); err != nil {
+			return "", err
+		}
+	}
+	cmd := g.gitCommand("merge-base", refA, refB)
 
-	details, err := g.getLastCommitDetails()
+	output, err := g.run(cmd)
 	if err != nil {
-		log.Printf("Error getting commit details: %v", err)
-		return
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return "", fmt.Errorf("%s and %s have no common ancestor", refA, refB)
+		}
+		return "", fmt.Errorf("failed to compute merge base: %v", err)
 	}
 
-	fmt.Printf("Hash:    %s\n", details["hash"][:8])
-	fmt.Printf("Author:  %s <%s>\n", details["author"], details["email"])
-	fmt.Printf("Date:    %s\n", details["date"])
-	fmt.Printf("Subject: %s\n", details["subject"])
+	return strings.TrimSpace(string(output)), nil
+}
 
-	if details["body"] != "" {
-		fmt.Printf("Body:    %s\n", details["body"])
+// filesChangedIn lists the files a commit changed that match pathspecs,
+// relative to the repository root. Merges are compared with their first
+// parent and root commits with the empty tree.
+func (g *GitSearchTool) filesChangedIn(commitish string, pathspecs []string) ([]string, error) {
+	if err := checkRevision(commitish); err != nil {
+		return nil, err
 	}
-
-	fmt.Println()
+	return g.diffNames([]string{"diff-tree", "-r", "-z", "--name-only", "--no-commit-id", "--root",
+		"-m", "--first-parent", commitish}, pathspecs)
 }
 
-func (g *GitSearchTool) interactiveSearch() {
-	scanner := bufio.NewScanner(os.Stdin)
+// workingTreeChanges lists the files with staged changes, or else with
+// unstaged ones, that match pathspecs
+func (g *GitSearchTool) workingTreeChanges(staged bool, pathspecs []string) ([]string, error) {
+	args := []string{"diff", "-z", "--name-only"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	return g.diffNames(args, pathspecs)
+}
 
-	for {
-		fmt.Print("Enter search query (or 'quit' to exit): ")
-		if !scanner.Scan() {
-			break
-		}
+// diffNames runs a git diff command given by args with -z --name-only,
+// limited to pathspecs, and returns the file names it lists
+func (g *GitSearchTool) diffNames(args, pathspecs []string) ([]string, error) {
+	cmd := g.gitCommand(args...)
+	if len(pathspecs) > 0 {
+		cmd.Args = append(cmd.Args, "--")
+		cmd.Args = append(cmd.Args, pathspecs...)
+	}
 
-		query := strings.TrimSpace(scanner.Text())
-		if query == "quit" || query == "exit" || query == "q" {
-			break
+	output, err := g.run(cmd)
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok && len(exitError.Stderr) > 0 {
+			return nil, fmt.Errorf("%s", strings.TrimSpace(string(exitError.Stderr)))
 		}
+		return nil, err
+	}
 
-		if query == "" {
-			continue
+	var files []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			files = append(files, path)
 		}
-
-		g.performSearch(query)
 	}
+	return files, nil
 }
 
-func (g *GitSearchTool) performSearch(query string) {
-	fmt.Printf("\n=== Search Results for: \"%s\" ===\n", query)
+// searchInCommitHistory searches for a query in commit messages
+func (g *GitSearchTool) searchInCommitHistory(opts SearchOptions) ([]CommitMatch, error) {
+	opts.MaxResults = opts.limit(g.maxCommits)
+	if g.fuzzy {
+		return g.searchFuzzyCommits(opts)
+	}
+	args := g.logGrepArgs(opts.Query)
+	if !opts.CaseSensitive {
+		args = append(args, "-i")
+	}
 
-	hash := "64fc5dd7"
-	// Search in last commit messages
-	fmt.Println("\n--- Commit Messages ---")
-	commits, err := g.searchInCommitHistory(query, 1)
-	if err != nil {
-		log.Printf("Error searching commits: %v", err)
-	} else if len(commits) == 0 {
-		fmt.Println("No matches found in commit messages.")
-	} else {
-		for i, commit := range commits {
-			if commit["hash"][:8] == hash {
-				fmt.Printf("%d. [%s] %s - %s (%s)\n",
-					i+1, commit["hash"][:8], commit["subject"],
-					commit["author"], commit["date"])
-			}
+	// git can't restrict --grep to the body, so commits whose subject
+	// matched are dropped here
+	var keep func(CommitMatch) bool
+	if g.bodyOnly {
+		keep = func(commit CommitMatch) bool {
+			return g.matchesTerms(commit.Body, opts)
 		}
 	}
 
-	// Search in commit messages
-	fmt.Println("\n--- Commit Messages ---")
-	commits, err = g.searchInCommitHistory(query, 10)
-	if err != nil {
-		log.Printf("Error searching commits: %v", err)
-	} else if len(commits) == 0 {
-		fmt.Println("No matches found in commit messages.")
-	} else {
-		for i, commit := range commits {
-			fmt.Printf("%d. [%s] %s - %s (%s)\n",
-				i+1, commit["hash"][:8], commit["subject"],
-				commit["author"], commit["date"])
-		}
+	if g.invert {
+		args = append(args, "--invert-grep")
 	}
 
-	// Search in files
-	fmt.Println("\n--- File Contents ---")
-	fileMatches, err := g.searchInFiles(query, 20)
+	results, err := g.logCommits(args, opts, keep)
 	if err != nil {
-		log.Printf("Error searching files: %v", err)
-	} else if len(fileMatches) == 0 {
-		fmt.Println("No matches found in tracked files.")
-	} else {
-		for i, match := range fileMatches {
-			fmt.Printf("%d. %s\n", i+1, match)
-		}
-		if len(fileMatches) == 20 {
-			fmt.Println("... (showing first 20 matches)")
-		}
+		return nil, fmt.Errorf("failed to search commit history: %v", err)
 	}
-
-	fmt.Println()
+	return results, nil
+}
+
+// matchesTerms reports whether text matches the query terms of opts, any of
+// them or all of them with -match all
+func (g *GitSearchTool) matchesTerms(text string, opts SearchOptions) bool {
+	terms := g.queryTerms(opts.Query)
+	matched := 0
+	for _, term := range terms {
+		if matchesPattern(text, term, opts.CaseSensitive) {
+			matched++
+		}
+	}
+	if g.matchAll {
+		return matched == len(terms)
+	}
+	return matched > 0
+}
+
+// queryTerms returns the query together with any extra query terms
+func (g *GitSearchTool) queryTerms(query string) []string {
+	return append([]string{query}, g.extraQueries...)
+}
+
+// logGrepArgs returns the git log arguments matching commit messages against
+// the query terms; repeated --grep patterns match any of them unless
+// --all-match asks for all
+func (g *GitSearchTool) logGrepArgs(query string) []string {
+	var args []string
+	for _, term := range g.queryTerms(query) {
+		args = append(args, "--grep="+term)
+	}
+	if g.matchAll && len(g.extraQueries) > 0 {
+		args = append(args, "--all-match")
+	}
+	return args
+}
+
+// searchInDiffs finds commits that changed the number of occurrences of the
+// query in the code, using git's pickaxe
+func (g *GitSearchTool) searchInDiffs(opts SearchOptions) ([]CommitMatch, error) {
+	opts.MaxResults = opts.limit(g.maxCommits)
+	args := []string{"-S" + opts.Query}
+	if !opts.CaseSensitive {
+		args = append(args, "-i")
+	}
+	// -S counts the occurrences in whole files, which whitespace options
+	// don't change, so a reindented line still counts as a change; the
+	// diff of each commit is checked ignoring whitespace instead
+	var keep func(CommitMatch) bool
+	if g.ignoreWhitespace {
+		keep = func(commit CommitMatch) bool {
+			return g.changesIgnoringWhitespace(commit.Hash, opts)
+		}
+	}
+
+	results, err := g.logCommits(args, opts, keep)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search code changes: %v", err)
+	}
+	return results, nil
+}
+
+// changesIgnoringWhitespace reports whether the diff of a commit, ignoring
+// whitespace, still adds or removes a line containing the query. A commit
+// whose diff can't be read is kept as git found it.
+func (g *GitSearchTool) changesIgnoringWhitespace(hash string, opts SearchOptions) bool {
+	cmd := g.gitCommand("show", "--ignore-all-space", "--format=", "--no-color", "--no-ext-diff", hash)
+	output, err := g.run(cmd)
+	if err != nil {
+		return true
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- ") {
+			continue
+		}
+		changed, ok := strings.CutPrefix(line, "+")
+		if !ok {
+			changed, ok = strings.CutPrefix(line, "-")
+		}
+		if !ok {
+			continue
+		}
+		if opts.CaseSensitive && strings.Contains(changed, opts.Query) || !opts.CaseSensitive && containsFold(changed, opts.Query) {
+			return true
+		}
+	}
+	return false
+}
+
+// historyRevs returns the revisions selecting the searched history: every
+// ref with -all-branches, the revision range, or HEAD
+func (g *GitSearchTool) historyRevs() []string {
+	switch {
+	case g.allBranches:
+		return []string{"--all"}
+	case g.revRange != "":
+		return []string{g.revRange}
+	default:
+		return []string{"HEAD"}
+	}
+}
+
+// recentCommits lists the hashes of the last n commits of the searched
+// history, one per line
+func (g *GitSearchTool) recentCommits(n int) (string, error) {
+	cmd := g.gitCommand("rev-list", fmt.Sprintf("--max-count=%d", n))
+	if g.firstParent {
+		cmd.Args = append(cmd.Args, "--first-parent")
+	}
+	cmd.Args = append(cmd.Args, g.historyRevs()...)
+
+	output, err := g.run(cmd)
+	return string(output), err
+}
+
+// logCommits runs git log with the given selection arguments and the
+// commit filters of opts and the tool, returning at most opts.MaxResults
+// commits; keep optionally filters the commits further
+func (g *GitSearchTool) logCommits(args []string, opts SearchOptions, keep func(CommitMatch) bool) ([]CommitMatch, error) {
+	maxResults := opts.MaxResults
+	// Fields are separated by US and records by RS so that multi-line bodies
+	// survive parsing
+	date := "%ad"
+	if g.committerDates {
+		date = "%cd"
+	}
+	// Trailers are only listed when they are filtered on, after the body
+	trailers := ""
+	if len(g.trailers) > 0 {
+		trailers = "%x1f%(trailers:only,unfold)"
+	}
+	cmd := g.gitCommand("log", logEncoding,
+		"--pretty=format:%H%x1f%an%x1f%ae%x1f"+date+"%x1f%s%x1f%b"+trailers+"%x1e", g.dateOption())
+	cmd.Args = append(cmd.Args, args...)
+	// Filtering on the body happens here, so git can only be asked for the
+	// first results when nothing will be filtered out afterwards. git also
+	// applies -N before --reverse, which would give the newest N oldest
+	// first, so the oldest N are taken from the whole reversed list instead.
+	if g.reverse {
+		cmd.Args = append(cmd.Args, "--reverse")
+	} else if g.minBodyLength == 0 && len(g.trailers) == 0 && keep == nil {
+		cmd.Args = append(cmd.Args, fmt.Sprintf("-%d", maxResults))
+	}
+	// git ANDs --author with --grep and -S, so both have to match
+	if opts.Author != "" {
+		cmd.Args = append(cmd.Args, "--author="+opts.Author)
+	}
+	// --author matches "Name <email>" with git's basic regular expressions,
+	// so an email and alternation such as alice\|bob work as well
+	if opts.AuthorRegex != "" {
+		cmd.Args = append(cmd.Args, "--author="+opts.AuthorRegex)
+		if !opts.CaseSensitive {
+			cmd.Args = append(cmd.Args, "--regexp-ignore-case")
+		}
+	}
+	if opts.Committer != "" {
+		cmd.Args = append(cmd.Args, "--committer="+opts.Committer)
+	}
+	if opts.Since != "" {
+		cmd.Args = append(cmd.Args, "--since="+opts.Since)
+	}
+	if opts.Until != "" {
+		cmd.Args = append(cmd.Args, "--until="+opts.Until)
+	}
+	if g.firstParent {
+		cmd.Args = append(cmd.Args, "--first-parent")
+	}
+	if g.noMerges {
+		cmd.Args = append(cmd.Args, "--no-merges")
+	} else if g.mergesOnly {
+		cmd.Args = append(cmd.Args, "--merges")
+	}
+	// -N limits the matches git lists, not the commits it looks at, so the
+	// last depth commits are listed first and git only reads those
+	if g.depth > 0 {
+		recent, err := g.recentCommits(g.depth)
+		if err != nil {
+			if !g.allBranches && g.revRange == "" && g.noCommitsYet() {
+				return nil, nil
+			}
+			return nil, err
+		}
+		// git log would fall back to HEAD without any commits to read,
+		// such as with -all-branches in a repository without refs
+		if strings.TrimSpace(recent) == "" {
+			return nil, nil
+		}
+		cmd.Args = append(cmd.Args, "--no-walk", "--stdin")
+		cmd.Stdin = strings.NewReader(recent)
+	} else if g.allBranches {
+		// --all walks the union of every ref, so the -N limit applies
+		// across all branches together
+		cmd.Args = append(cmd.Args, "--all")
+	} else if g.revRange != "" {
+		cmd.Args = append(cmd.Args, g.revRange)
+	}
+	if g.follow != "" {
+		cmd.Args = append(cmd.Args, "--follow", "--", g.follow)
+	}
+
+	output, err := g.run(cmd)
+	if err != nil {
+		// Without any commits there is nothing to match
+		if !g.allBranches && g.revRange == "" && g.noCommitsYet() {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	records := strings.Split(toUTF8(string(output)), "\x1e")
+	var results []CommitMatch
+
+	for _, record := range records {
+		record = strings.TrimLeft(record, "\n")
+		if record == "" {
+			continue
+		}
+
+		parts := strings.Split(record, "\x1f")
+		if len(parts) >= 6 {
+			result := CommitMatch{
+				Hash:    parts[0],
+				Author:  parts[1],
+				Email:   parts[2],
+				Date:    parts[3],
+				Subject: parts[4],
+				Body:    strings.TrimSpace(parts[5]),
+			}
+			if len(g.trailers) > 0 && (len(parts) < 7 || !g.matchesTrailers(parts[6], opts.CaseSensitive)) {
+				continue
+			}
+			if utf8.RuneCountInString(result.Body) < g.minBodyLength {
+				continue
+			}
+			if keep != nil && !keep(result) {
+				continue
+			}
+			results = append(results, result)
+			if len(results) == maxResults {
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// grepArgs builds the git grep arguments for a file search, with flags
+// selecting the output shape (e.g. -n for lines, -c for counts). It runs
+// nothing, so the arguments of each option can be inspected directly.
+func (g *GitSearchTool) grepArgs(opts SearchOptions, flags ...string) []string {
+	args := append([]string{"grep"}, flags...)
+	if !opts.CaseSensitive {
+		args = append(args, "-i")
+	}
+	if opts.Regex {
+		args = append(args, "-E")
+	}
+	if opts.WholeWord {
+		args = append(args, "-w")
+	}
+	if g.invert {
+		args = append(args, "-v")
+	}
+	if g.noIndex {
+		args = append(args, "--no-index")
+	}
+	if g.untracked {
+		args = append(args, "--untracked")
+	}
+	if g.recurseSubmodules {
+		args = append(args, "--recurse-submodules")
+	}
+	if g.textconv {
+		args = append(args, "--textconv")
+	}
+	if g.includeBinary {
+		args = append(args, "-a")
+	}
+	if len(g.fileExprArgs) > 0 {
+		args = append(args, g.fileExprArgs...)
+	} else {
+		for _, term := range g.queryTerms(opts.Query) {
+			args = append(args, "-e", term)
+		}
+		// Like git log, --all-match keeps files that match every term
+		if g.matchAll && len(g.extraQueries) > 0 {
+			args = append(args, "--all-match")
+		}
+	}
+	if rev := g.grepRevision(); rev != "" {
+		args = append(args, rev)
+	}
+	if specs := g.searchPathspecs(opts.PathFilters); len(specs) > 0 {
+		args = append(args, "--")
+		args = append(args, specs...)
+	}
+	return args
+}
+
+// searchPathspecs combines the search paths, path filters and file name
+// patterns into the pathspecs passed to git; git ORs pathspecs together, so
+// every pattern is nested under every path to get their intersection.
+// Including path filters take the place of the search path and excluding
+// ones are added as they are.
+func (g *GitSearchTool) searchPathspecs(pathFilters []string) []string {
+	paths := g.pathspecs
+	var excludes []string
+	for _, spec := range pathFilters {
+		if isExcludePathspec(spec) {
+			excludes = append(excludes, spec)
+		} else {
+			paths = append(paths, spec)
+		}
+	}
+
+	var specs []string
+	switch {
+	case len(g.filePatterns) == 0:
+		specs = append(specs, paths...)
+	case len(paths) == 0:
+		specs = append(specs, g.filePatterns...)
+	default:
+		for _, path := range paths {
+			for _, pattern := range g.filePatterns {
+				specs = append(specs, strings.TrimSuffix(path, "/")+"/"+pattern)
+			}
+		}
+	}
+	return append(append(specs, excludes...), g.ignoreSpecs...)
+}
+
+// searchInFiles searches for a query in tracked files
+func (g *GitSearchTool) searchInFiles(opts SearchOptions) ([]FileMatch, error) {
+	defer g.progress("Searching files...")()
+
+	// -z separates the path and line number with NULs, as either the path
+	// or the content may contain colons
+	return g.grepFiles(g.grepArgs(opts, "-n", "-z"), opts.limit(g.maxFiles))
+}
+
+// startFileSearch starts searching files for query in the background, so
+// that it overlaps the commit search, and returns a function waiting for
+// its results. The arguments are built up front as the commit fallback
+// changes the query terms of the tool.
+func (g *GitSearchTool) startFileSearch(query string) func() ([]FileMatch, error) {
+	args := g.grepArgs(g.fileSearchOptions(query), "-n", "-z")
+
+	var (
+		wg      sync.WaitGroup
+		matches []FileMatch
+		err     error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		matches, err = g.grepFiles(args, g.maxFiles)
+	}()
+
+	return func() ([]FileMatch, error) {
+		defer g.progress("Searching files...")()
+		wg.Wait()
+		return matches, err
+	}
+}
+
+// grepFiles runs a git grep -n -z and parses at most maxResults matches
+func (g *GitSearchTool) grepFiles(args []string, maxResults int) ([]FileMatch, error) {
+	cmd := g.gitCommand(args...)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		// git grep returns non-zero exit code when no matches found
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return []FileMatch{}, nil
+		}
+		return nil, fmt.Errorf("failed to search in files: %v", err)
+	}
+
+	var (
+		matches []FileMatch
+		records grepRecords
+		sizes   = g.newFileSizeFilter()
+	)
+	for _, line := range strings.Split(strings.TrimSuffix(string(output), "\n"), "\n") {
+		match, ok := records.add(line)
+		if !ok || !g.inLineRange(match.LineNumber) {
+			continue
+		}
+		match.Tree, match.Path = g.splitRevision(match.Path)
+		if sizes.skip(match) {
+			continue
+		}
+		matches = append(matches, match)
+
+		// Limit results
+		if len(matches) == maxResults {
+			break
+		}
+	}
+
+	sortFileMatches(matches, g.fileSort)
+	return matches, nil
+}
+
+// inLineRange reports whether a file match line is within -line-min and
+// -line-max; git grep can't restrict line numbers itself
+func (g *GitSearchTool) inLineRange(line int) bool {
+	return line >= g.lineMin && (g.lineMax == 0 || line <= g.lineMax)
+}
+
+// streamFiles runs a git grep -n -z and calls fn with each match as git
+// prints it, instead of reading the whole output first; returning false
+// from fn stops git early
+func (g *GitSearchTool) streamFiles(args []string, fn func(FileMatch) bool) error {
+	cmd := g.gitCommand(args...)
+	g.record(cmd)
+	if g.dryRun {
+		fmt.Fprintln(os.Stderr, shellQuote(cmd.Args))
+		return nil
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to search in files: %v", err)
+	}
+	done := g.timeCommand(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to search in files: %v", err)
+	}
+
+	var (
+		stopped bool
+		records grepRecords
+		sizes   = g.newFileSizeFilter()
+	)
+	reader := bufio.NewReader(stdout)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if line == "" && readErr != nil {
+			break
+		}
+		if match, ok := records.add(strings.TrimSuffix(line, "\n")); ok && g.inLineRange(match.LineNumber) {
+			match.Tree, match.Path = g.splitRevision(match.Path)
+			if sizes.skip(match) {
+				continue
+			}
+			if !fn(match) {
+				stopped = true
+				break
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	// Killing git when we stopped early also reaps it with Wait
+	if stopped {
+		cmd.Process.Kill()
+	}
+	err = cmd.Wait()
+	done()
+	logCommand(cmd, err)
+	switch {
+	case stopped:
+		return nil
+	case err != nil && g.timedOut():
+		return fmt.Errorf("git command timed out after %s", g.timeout)
+	case err != nil:
+		// git grep returns non-zero exit code when no matches found
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return nil
+		}
+		return fmt.Errorf("failed to search in files: %v", err)
+	}
+	return nil
+}
+
+// grepRecords reassembles the matches of git grep -n -z from its output
+// lines. Paths are printed unquoted with -z, so a path with a newline in it
+// spans several lines and its match is only complete once both NULs have
+// been read.
+type grepRecords struct {
+	pending string
+}
+
+// add takes the next output line and returns the match it completes
+func (r *grepRecords) add(line string) (FileMatch, bool) {
+	record := r.pending + line
+	r.pending = ""
+	if strings.Count(record, "\x00") < 2 {
+		// git's "Binary file ... matches" notes have no NULs at all
+		if !strings.HasPrefix(record, "Binary file ") || !strings.HasSuffix(record, " matches") {
+			r.pending = record + "\n"
+		}
+		return FileMatch{}, false
+	}
+	return parseFileMatch(record)
+}
+
+// parseFileMatch splits a "path<NUL>line<NUL>content" git grep -z line
+func parseFileMatch(line string) (FileMatch, bool) {
+	parts := strings.SplitN(line, "\x00", 3)
+	if len(parts) < 3 {
+		return FileMatch{}, false
+	}
+	number, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return FileMatch{}, false
+	}
+	return FileMatch{Path: parts[0], LineNumber: number, Content: parts[2]}, true
+}
+
+// abbreviateHash shortens a commit hash to 8 characters for display, leaving
+// shorter hashes whole
+func abbreviateHash(hash string) string {
+	if len(hash) < 8 {
+		return hash
+	}
+	return hash[:8]
+}
+
+// argsTerminated reports whether the positional arguments follow a "--",
+// which the flag package drops when it ends the flags
+func argsTerminated() bool {
+	i := len(os.Args) - flag.NArg() - 1
+	return i > 0 && os.Args[i] == "--"
+}
+
+// splitArgs separates the positional arguments into revision ranges and
+// paths the way git does: arguments after "--" are paths, and before it an
+// argument is a path when it names a file or directory in dir
+func splitArgs(args []string, terminated bool, dir string) (revs, paths []string) {
+	if terminated {
+		return nil, args
+	}
+	for i, arg := range args {
+		if arg == "--" {
+			return revs, append(paths, args[i+1:]...)
+		}
+		if _, err := os.Stat(filepath.Join(dir, arg)); err == nil {
+			paths = append(paths, arg)
+		} else {
+			revs = append(revs, arg)
+		}
+	}
+	return revs, paths
+}
+
+// matchesPattern reports whether text matches a git search pattern, as a
+// regular expression or else as a literal
+func matchesPattern(text, pattern string, caseSensitive bool) bool {
+	flags := "(?i)"
+	if caseSensitive {
+		flags = ""
+	}
+	if re, err := regexp.Compile(flags + pattern); err == nil {
+		return re.MatchString(text)
+	}
+	if caseSensitive {
+		return strings.Contains(text, pattern)
+	}
+	return containsFold(text, pattern)
+}
+
+// containsFold reports whether s contains substr, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// containsCase reports whether s contains substr, ignoring case unless
+// caseSensitive is set
+func containsCase(s, substr string, caseSensitive bool) bool {
+	if caseSensitive {
+		return strings.Contains(s, substr)
+	}
+	return containsFold(s, substr)
+}
+
+// relativePrefix returns dir relative to the working directory as a path
+// prefix, or an empty string for the working directory itself
+func relativePrefix(dir string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return dir + string(filepath.Separator)
+	}
+
+	rel, err := filepath.Rel(wd, dir)
+	if err != nil {
+		return dir + string(filepath.Separator)
+	}
+	if rel == "." {
+		return ""
+	}
+	return rel + string(filepath.Separator)
+}
+
+// limitLines returns the first n lines of text and how many were left out
+func limitLines(text string, n int) ([]string, int) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) <= n {
+		return lines, 0
+	}
+	return lines[:n], len(lines) - n
+}
+
+// noisyFiles returns the files with more than threshold matches
+func noisyFiles(matches []FileMatch, threshold int) map[string]bool {
+	counts := make(map[string]int)
+	for _, match := range matches {
+		counts[match.Path]++
+	}
+
+	noisy := make(map[string]bool)
+	for file, count := range counts {
+		if count > threshold {
+			noisy[file] = true
+		}
+	}
+	return noisy
+}
+
+// groupByFile returns the matches with those of each file together, the
+// files in the order of their first match and the lines of each in order
+func groupByFile(matches []FileMatch) []FileMatch {
+	first := make(map[string]int)
+	for i, match := range matches {
+		if _, ok := first[match.Path]; !ok {
+			first[match.Path] = i
+		}
+	}
+	grouped := append([]FileMatch(nil), matches...)
+	sort.SliceStable(grouped, func(i, j int) bool {
+		return first[grouped[i].Path] < first[grouped[j].Path]
+	})
+	return grouped
+}
+
+// fileSorts lists the orders of file matches accepted by -sort
+var fileSorts = []string{"none", "path", "count"}
+
+// sortFileMatches orders file matches in place: "path" by path and line
+// number, "count" the files with the most matches first, keeping the lines
+// of each file in order
+func sortFileMatches(matches []FileMatch, order string) {
+	switch order {
+	case "path":
+		sort.SliceStable(matches, func(i, j int) bool {
+			if matches[i].Path != matches[j].Path {
+				return matches[i].Path < matches[j].Path
+			}
+			return matches[i].LineNumber < matches[j].LineNumber
+		})
+	case "count":
+		counts := make(map[string]int)
+		for _, match := range matches {
+			counts[match.Path]++
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			a, b := matches[i].Path, matches[j].Path
+			if counts[a] != counts[b] {
+				return counts[a] > counts[b]
+			}
+			return a < b
+		})
+	}
+}
+
+// writeCommitDetails writes the author, date, subject and body of a commit
+// from getCommitDetails, the body shortened to -body-lines
+func (g *GitSearchTool) writeCommitDetails(w io.Writer, details map[string]string) {
+	fmt.Fprintf(w, "Author:  %s <%s>\n", details["author"], details["email"])
+	fmt.Fprintf(w, "Date:    %s\n", details["date"])
+	fmt.Fprintf(w, "Subject: %s\n", g.displaySubject(details["subject"]))
+
+	if details["body"] != "" {
+		if g.bodyLines > 0 {
+			lines, more := limitLines(details["body"], g.bodyLines)
+			fmt.Fprintf(w, "Body:    %s\n", strings.Join(lines, "\n         "))
+			if more > 0 {
+				fmt.Fprintf(w, "         ... (%d more lines)\n", more)
+			}
+		} else {
+			fmt.Fprintf(w, "Body:    %s\n", details["body"])
+		}
+	}
+}
+
+// resolveCommit returns the full hash of the commit named by rev, usually
+// an abbreviated hash, telling an ambiguous abbreviation apart from an
+// unknown one
+func (g *GitSearchTool) resolveCommit(rev string) (string, error) {
+	if err := checkRevision(rev); err != nil {
+		return "", err
+	}
+	cmd := g.gitCommand("rev-parse", "--verify", rev+"^{commit}")
+
+	output, err := g.run(cmd)
+	if err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) && strings.Contains(string(exitError.Stderr), "ambiguous") {
+			return "", fmt.Errorf("%s is ambiguous, several objects start with it; give more characters of the hash", rev)
+		}
+		return "", fmt.Errorf("no commit %s in this repository", rev)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// displayCommit prints the details of the commit named by rev, such as a
+// hash prefix copied from elsewhere, with its full hash
+func (g *GitSearchTool) displayCommit(rev string) {
+	hash, err := g.resolveCommit(rev)
+	if err != nil {
+		g.searchErrorf("Error showing commit: %v", err)
+		return
+	}
+	if g.dryRun {
+		return
+	}
+
+	details, err := g.getCommitDetails(hash)
+	if err != nil {
+		g.searchErrorf("Error getting commit details: %v", err)
+		return
+	}
+	fmt.Printf("Hash:    %s\n", yellow(details["hash"]))
+	g.writeCommitDetails(os.Stdout, details)
+}
+
+func (g *GitSearchTool) displayLastCommit() {
+	fmt.Fprintln(g.info, bold("=== Last Commit Information ==="))
+
+	details, err := g.getCommitDetails("HEAD")
+	if errors.Is(err, errNoCommits) {
+		fmt.Fprintln(g.info, "No commits yet.")
+		fmt.Fprintln(g.info)
+		return
+	}
+	if err != nil {
+		errorf("Error getting commit details: %v", err)
+		return
+	}
+
+	fmt.Fprintf(g.info, "Hash:    %s\n", yellow(abbreviateHash(details["hash"])))
+	g.writeCommitDetails(g.info, details)
+
+	if g.showAheadBehind {
+		branch, upstream, ahead, behind, err := g.getAheadBehind()
+		switch {
+		case err != nil:
+			errorf("Error comparing with upstream: %v", err)
+		case branch == "":
+			fmt.Fprintln(g.info, "Branch:  (detached HEAD, no upstream)")
+		case upstream == "":
+			fmt.Fprintf(g.info, "Branch:  %s (no upstream)\n", branch)
+		default:
+			fmt.Fprintf(g.info, "Branch:  %s (%d ahead, %d behind %s)\n", branch, ahead, behind, upstream)
+		}
+	}
+
+	fmt.Fprintln(g.info)
+}
+
+func (g *GitSearchTool) interactiveSearch() {
+	g.searchLines(true)
+}
+
+// batchSearch runs a search for every line of stdin until EOF, without
+// prompts and with a delimiter between the results of each query
+func (g *GitSearchTool) batchSearch() {
+	g.searchLines(false)
+}
+
+// searchLines reads queries and session commands from stdin, prompting for
+// them in interactive mode
+func (g *GitSearchTool) searchLines(interactive bool) {
+	scanner := bufio.NewScanner(os.Stdin)
+	searched := 0
+
+	for {
+		if interactive {
+			fmt.Print("Enter search query (or 'quit' to exit): ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+
+		query := strings.TrimSpace(scanner.Text())
+		if interactive && (query == "quit" || query == "exit" || query == "q") {
+			break
+		}
+
+		if query == "" {
+			continue
+		}
+
+		if interactive && g.history != nil {
+			if query == ":history" {
+				g.history.display()
+				continue
+			}
+			if strings.HasPrefix(query, "!") {
+				recalled, ok := g.history.recall(query)
+				if !ok {
+					fmt.Printf("No history entry %q (see :history)\n", query)
+					continue
+				}
+				query = recalled
+				fmt.Println(query)
+			}
+		}
+
+		if interactive && strings.HasPrefix(query, ":open") {
+			g.openMatch(strings.TrimSpace(strings.TrimPrefix(query, ":open")))
+			continue
+		}
+		if strings.HasPrefix(query, ":format") {
+			g.switchFormat(strings.TrimSpace(strings.TrimPrefix(query, ":format")))
+			continue
+		}
+		if strings.HasPrefix(query, ":match") {
+			g.switchMatch(strings.TrimSpace(strings.TrimPrefix(query, ":match")))
+			continue
+		}
+
+		// Several terms are separated by semicolons
+		var terms []string
+		for _, term := range strings.Split(query, ";") {
+			if term = strings.TrimSpace(term); term != "" {
+				terms = append(terms, term)
+			}
+		}
+		if len(terms) == 0 {
+			continue
+		}
+		if interactive && g.history != nil {
+			if err := g.history.add(query); err != nil {
+				errorf("Error saving history: %v", err)
+			}
+		}
+		// JSON output is already one document per line
+		if !interactive && searched > 0 && g.format == "text" {
+			fmt.Println(strings.Repeat("=", 72))
+		}
+		searched++
+
+		g.extraQueries = terms[1:]
+		if interactive && g.pageSize > 0 && stdoutIsTerminal() {
+			g.pageOutput(scanner, func() { g.performSearch(terms[0]) })
+		} else {
+			g.performSearch(terms[0])
+		}
+		// Ctrl-C ends a batch, but only the current search of a session
+		if !interactive && g.interrupted() {
+			break
+		}
+	}
+}
+
+// switchMatch changes whether the terms of a query must all match, echoing
+// the result
+func (g *GitSearchTool) switchMatch(mode string) {
+	switch mode {
+	case "all":
+		g.matchAll = true
+	case "any":
+		g.matchAll = false
+	case "":
+	default:
+		fmt.Printf("Unknown match mode %q (available: any, all)\n", mode)
+		return
+	}
+
+	if g.matchAll {
+		fmt.Println("Match: all terms")
+	} else {
+		fmt.Println("Match: any term")
+	}
+}
+
+// switchFormat changes the session output format, echoing the result
+func (g *GitSearchTool) switchFormat(format string) {
+	if format == "" {
+		fmt.Printf("Output format: %s (available: %s)\n", g.format, strings.Join(outputFormats, ", "))
+		return
+	}
+
+	for _, f := range outputFormats {
+		if f == format {
+			g.format = format
+			fmt.Printf("Output format: %s\n", format)
+			return
+		}
+	}
+
+	fmt.Printf("Unknown format %q (available: %s)\n", format, strings.Join(outputFormats, ", "))
+}
+
+// statusf prints a line of context around the results, which is left out
+// with -quiet and of JSON output, so that stdout stays a valid document
+func (g *GitSearchTool) statusf(format string, args ...any) {
+	if g.format == "text" && !g.quiet {
+		fmt.Fprintf(g.info, format, args...)
+	}
+}
+
+// decorf prints the headers and notes of text results, which -quiet
+// leaves out so that only the match lines remain
+func (g *GitSearchTool) decorf(format string, args ...any) {
+	if !g.quiet {
+		fmt.Printf(format, args...)
+	}
+}
+
+// searchFallbackCommits searches commit messages for the fallback pattern
+// alone, without the other query terms
+func (g *GitSearchTool) searchFallbackCommits() ([]CommitMatch, error) {
+	extraQueries := g.extraQueries
+	g.extraQueries = nil
+	defer func() { g.extraQueries = extraQueries }()
+
+	return g.searchInCommitHistory(g.searchOptions(g.fallback))
+}
+
+// searchFallbackFiles searches files for the fallback pattern; it is a plain
+// pattern, replacing the other query terms and any -expr expression
+func (g *GitSearchTool) searchFallbackFiles() ([]FileMatch, error) {
+	extraQueries, exprArgs := g.extraQueries, g.fileExprArgs
+	g.extraQueries, g.fileExprArgs = nil, nil
+	defer func() { g.extraQueries, g.fileExprArgs = extraQueries, exprArgs }()
+
+	return g.searchInFiles(g.fileSearchOptions(g.fallback))
+}
+
+// describeQuery quotes the query terms for headers, noting how they combine
+func (g *GitSearchTool) describeQuery(query string) string {
+	terms := g.queryTerms(query)
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = fmt.Sprintf("%q", term)
+	}
+	if len(terms) == 1 {
+		return quoted[0]
+	}
+	if g.matchAll {
+		return strings.Join(quoted, " AND ")
+	}
+	return strings.Join(quoted, " OR ")
+}
+
+// allowResults returns how many of n results fit under the global result cap
+// and counts them, counting the rest as suppressed
+func (g *GitSearchTool) allowResults(n int) int {
+	if g.maxResults > 0 && g.emitted+n > g.maxResults {
+		allowed := max(g.maxResults-g.emitted, 0)
+		g.suppressed += n - allowed
+		n = allowed
+	}
+	g.emitted += n
+	return n
+}
+
+// searchCommitSections prints the commit message sections of a search and
+// returns the number of commits shown
+func (g *GitSearchTool) searchCommitSections(query string) int {
+	shown := 0
+	// A commit is only listed in the first section it matches
+	seen := make(map[string]bool)
+	// Search in commit messages
+	if g.invert {
+		g.decorf("\n%s\n", bold("--- Commits NOT Matching ---"))
+	} else {
+		g.decorf("\n%s\n", bold("--- Commit Messages ---"))
+	}
+	commits, err := g.searchInCommitHistory(g.searchOptions(query))
+	if err == nil && len(commits) == 0 && g.fallback != "" {
+		commits, err = g.searchFallbackCommits()
+		query = g.fallback
+		g.decorf("No matches, showing results for fallback \"%s\".\n", g.fallback)
+	}
+	if err != nil {
+		g.searchErrorf("Error searching commits: %v", err)
+	} else if len(commits) == 0 {
+		g.decorf("No matches found in commit messages.\n")
+	} else {
+		shown += g.allowResults(len(commits))
+		dropSeen(commits[:shown], seen)
+		if g.withStat {
+			if err := g.addStats(commits[:shown]); err != nil {
+				g.searchErrorf("Error reading commit changes: %v", err)
+			}
+		}
+		if g.showRenames {
+			if err := g.addRenames(commits[:shown]); err != nil {
+				g.searchErrorf("Error reading renames: %v", err)
+			}
+		}
+		for i, commit := range commits[:shown] {
+			fmt.Print(g.formatCommit(i, commit))
+			if g.fuzzy {
+				fmt.Printf(" score %.2f", commit.Score)
+			}
+			fmt.Println()
+			if g.bodySnippets && !containsCase(commit.Subject, query, g.commitsCaseSensitive()) {
+				if snippet, ok := bodySnippet(commit.Body, query, snippetContext, g.commitsCaseSensitive()); ok {
+					fmt.Printf("   %s\n", snippet)
+				}
+			}
+			if g.bodyLines > 0 && commit.Body != "" {
+				lines, more := limitLines(commit.Body, g.bodyLines)
+				for _, line := range lines {
+					fmt.Printf("   | %s\n", line)
+				}
+				if more > 0 {
+					fmt.Printf("   | ... (%d more lines)\n", more)
+				}
+			}
+			if g.explain {
+				fmt.Printf("   explain: %s\n", explainCommit(commit, query))
+			}
+			g.printStat(commit)
+			g.printRename(commit)
+		}
+	}
+
+	if g.diffSearch {
+		g.decorf("\n%s\n", bold("--- Code Changes ---"))
+		changes, err := g.searchInDiffs(g.searchOptions(query))
+		listed := len(changes)
+		changes = dropSeen(changes, seen)
+		listed -= len(changes)
+		if err != nil {
+			g.searchErrorf("Error searching code changes: %v", err)
+		} else if len(changes) == 0 && listed > 0 {
+			g.decorf("Only commits listed above changed the occurrences of the query.\n")
+		} else if len(changes) == 0 {
+			g.decorf("No commits changed the occurrences of the query.\n")
+		} else {
+			allowed := g.allowResults(len(changes))
+			if g.withStat {
+				if err := g.addStats(changes[:allowed]); err != nil {
+					g.searchErrorf("Error reading commit changes: %v", err)
+				}
+			}
+			if g.showRenames {
+				if err := g.addRenames(changes[:allowed]); err != nil {
+					g.searchErrorf("Error reading renames: %v", err)
+				}
+			}
+			for i, commit := range changes[:allowed] {
+				fmt.Println(g.formatCommit(i, commit))
+				g.printStat(commit)
+				g.printRename(commit)
+			}
+			shown += allowed
+		}
+	}
+
+	if g.searchTags {
+		g.decorf("\n%s\n", bold("--- Tags ---"))
+		tags, err := g.searchInTags(g.searchOptions(query))
+		if err != nil {
+			g.searchErrorf("Error searching tags: %v", err)
+		} else if len(tags) == 0 {
+			g.decorf("No matches found in tags.\n")
+		} else {
+			for i, tag := range tags[:g.allowResults(len(tags))] {
+				fmt.Printf("%d. %s", i+1, yellow(tag.Name))
+				if tag.Tagger != "" {
+					fmt.Printf(" - %s (%s)", tag.Tagger, tag.Date)
+				}
+				fmt.Println()
+				if tag.Line != "" {
+					fmt.Printf("   %s\n", highlightPatterns(tag.Line, g.queryTerms(query), g.commitsCaseSensitive()))
+				}
+			}
+		}
+	}
+
+	if g.searchStashes {
+		g.decorf("\n%s\n", bold("--- Stashes ---"))
+		stashes, err := g.searchInStashes(g.searchOptions(query), g.diffSearch)
+		if err != nil {
+			g.searchErrorf("Error searching stashes: %v", err)
+		} else if len(stashes) == 0 {
+			g.decorf("No matches found in stashes.\n")
+		} else {
+			for i, stash := range stashes[:g.allowResults(len(stashes))] {
+				fmt.Printf("%d. %s %s\n", i+1, yellow(stash.Ref), stash.Message)
+				lines, more := limitLines(strings.Join(stash.Lines, "\n"), stashLines)
+				for _, line := range lines {
+					fmt.Printf("   %s\n", highlightPatterns(line, g.queryTerms(query), g.commitsCaseSensitive()))
+				}
+				if more > 0 {
+					fmt.Printf("   ... (%d more lines)\n", more)
+				}
+			}
+		}
+	}
+
+	if g.searchNotes {
+		g.decorf("\n%s\n", bold("--- Notes ---"))
+		notes, err := g.searchInNotes(g.searchOptions(query))
+		if err != nil {
+			g.searchErrorf("Error searching notes: %v", err)
+		} else if len(notes) == 0 {
+			g.decorf("No matches found in notes.\n")
+		} else {
+			for i, note := range notes[:g.allowResults(len(notes))] {
+				fmt.Printf("%d. %s\n", i+1, yellow(abbreviateHash(note.Commit)))
+				lines, more := limitLines(strings.Join(note.Lines, "\n"), noteLines)
+				for _, line := range lines {
+					fmt.Printf("   %s\n", highlightPatterns(line, g.queryTerms(query), g.commitsCaseSensitive()))
+				}
+				if more > 0 {
+					fmt.Printf("   ... (%d more lines)\n", more)
+				}
+			}
+		}
+	}
+
+	if g.searchReflog {
+		g.decorf("\n%s\n", bold("--- Reflog ---"))
+		entries, err := g.searchInReflog(g.searchOptions(query))
+		if err != nil {
+			g.searchErrorf("Error searching reflog: %v", err)
+		} else if len(entries) == 0 {
+			g.decorf("No matches found in reflog.\n")
+		} else {
+			for i, entry := range entries[:g.allowResults(len(entries))] {
+				fmt.Printf("%d. %s %s %s\n", i+1, yellow(entry.Hash), entry.Selector,
+					highlightPatterns(entry.Message, g.queryTerms(query), g.commitsCaseSensitive()))
+			}
+		}
+	}
+	return shown
+}
+
+// printStat prints the changed files of a commit from -with-stat under it
+func (g *GitSearchTool) printStat(commit CommitMatch) {
+	for _, line := range commit.Stat {
+		fmt.Printf("   %s\n", line)
+	}
+}
+
+// Exit statuses of a single search, the same as grep's
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
+)
+
+// exitNoGit is the exit status when the git executable can't be found, told
+// apart from search errors so that scripts can report it
+const exitNoGit = 3
+
+// exitInterrupted is the exit status of a search canceled with Ctrl-C, the
+// one shells use for processes killed by SIGINT
+const exitInterrupted = 130
+
+// exitStatus returns the exit status for the last search
+func (g *GitSearchTool) exitStatus() int {
+	switch {
+	case g.interrupted():
+		return exitInterrupted
+	case g.failed:
+		return exitError
+	case g.dryRun:
+		return exitMatch
+	case g.emitted+g.suppressed == 0:
+		return exitNoMatch
+	default:
+		return exitMatch
+	}
+}
+
+// reportStatus returns the exit status of a report such as -stats, which
+// fails or succeeds but doesn't match
+func (g *GitSearchTool) reportStatus() int {
+	switch {
+	case g.interrupted():
+		return exitInterrupted
+	case g.failed:
+		return exitError
+	default:
+		return exitMatch
+	}
+}
+
+// searchErrorf logs an error of the current search and marks it as failed
+func (g *GitSearchTool) searchErrorf(format string, args ...any) {
+	// The commands killed by Ctrl-C fail too, which isn't worth reporting
+	if !g.interrupted() {
+		errorf(format, args...)
+	}
+	g.failed = true
+}
+
+// fatalf logs an error and exits with the error status, skipping deferred
+// calls. main only uses it before deferring anything; later errors set its
+// deferred exit status and return, so that the -output file is closed and
+// -debug-json and -timing are still written.
+func fatalf(format string, args ...any) {
+	errorf(format, args...)
+	os.Exit(exitError)
+}
+
+func (g *GitSearchTool) performSearch(query string) {
+	g.emitted, g.suppressed, g.failed = 0, 0, false
+
+	// Every search gets its own deadline so that the next interactive query
+	// starts afresh after a timeout
+	parent := g.ctx
+	cancel := g.startTimeout()
+	stop := g.cancelOnInterrupt()
+	defer func() {
+		stop()
+		cancel()
+		g.ctx = parent
+		if g.interrupted() {
+			fmt.Fprintln(os.Stderr, "\nSearch interrupted.")
+		}
+	}()
+	g.lastFiles = nil
+	switch g.format {
+	case "json":
+		g.writeSearchJSON(query)
+		return
+	case "jsonl":
+		g.streamSearchJSONL(query)
+		return
+	case "csv":
+		g.writeSearchCSV(query)
+		return
+	}
+	if g.separator != "" {
+		g.writeSearchDelimited(query)
+		return
+	}
+
+	// Files are grepped while the commit sections are searched and printed
+	var files func() ([]FileMatch, error)
+	if !g.tree && !g.filesOnly && !g.countOnly {
+		files = g.startFileSearch(query)
+	}
+
+	commitCount, fileMatchCount, fileCount := 0, 0, 0
+	if query != "" {
+		if g.invert {
+			g.decorf("\n%s\n", bold(fmt.Sprintf("=== Search Results NOT matching: %s ===", g.describeQuery(query))))
+		} else {
+			g.decorf("\n%s\n", bold(fmt.Sprintf("=== Search Results for: %s ===", g.describeQuery(query))))
+		}
+		if !g.headOnly && !g.noIndex {
+			commitCount = g.searchCommitSections(query)
+		}
+	} else {
+		g.decorf("\n%s\n", bold(fmt.Sprintf("=== Search Results for expression: \"%s\" ===", g.fileExpr)))
+	}
+
+	// Search in files
+	if g.invert {
+		g.decorf("\n%s\n", bold("--- File Lines NOT Matching ---"))
+	} else {
+		g.decorf("\n%s\n", bold("--- File Contents ---"))
+	}
+	if g.tree {
+		g.displayFileTree(query)
+		g.decorf("\n")
+		return
+	}
+	if g.filesOnly {
+		g.displayMatchingFiles(query)
+		g.decorf("\n")
+		return
+	}
+	if g.countOnly {
+		g.displayFileCounts(query)
+		g.decorf("\n")
+		return
+	}
+	fileMatches, err := files()
+	if err == nil && len(fileMatches) == 0 && g.fallback != "" {
+		fileMatches, err = g.searchFallbackFiles()
+		query = g.fallback
+		g.decorf("No matches, showing results for fallback \"%s\".\n", g.fallback)
+	}
+	if err != nil {
+		g.searchErrorf("Error searching files: %v", err)
+	} else if len(fileMatches) == 0 {
+		g.decorf("No matches found in tracked files.\n")
+	} else {
+		var index symbolIndex
+		if g.symbols {
+			if index = g.loadSymbols(); index == nil {
+				g.decorf("No tags file found, showing matches without symbols.\n")
+			}
+		}
+		var noisy map[string]bool
+		if g.noiseThreshold > 0 && useColor() {
+			noisy = noisyFiles(fileMatches, g.noiseThreshold)
+		}
+		shown := g.allowResults(len(fileMatches))
+		matches := fileMatches[:shown]
+		if g.groupFiles {
+			matches = groupByFile(matches)
+		}
+		if g.blame {
+			if err := g.addBlame(matches); err != nil {
+				g.searchErrorf("Error blaming file matches: %v", err)
+			}
+		}
+		rendered := processInChunks(matches, g.threads, func(i int, match FileMatch) string {
+			content, binary := match.Content, false
+			text := truncateAround(match.Content, g.filePatternsOf(query), g.filesCaseSensitive(), g.truncate)
+			if g.binaryPreview > 0 && looksBinary(content) {
+				content, binary = binaryPreview(content, query, g.binaryPreview), true
+			} else {
+				content = highlightPatterns(text, g.filePatternsOf(query), g.filesCaseSensitive())
+			}
+			prefix := fmt.Sprintf("%d. %s%s:%d:", i+1, g.pathPrefix, match.Path, match.LineNumber)
+			if g.groupFiles {
+				prefix = fmt.Sprintf("  %d. %d:", i+1, match.LineNumber)
+			}
+			line := prefix + content
+			if index != nil {
+				line += index.annotation(match)
+			}
+			if match.BlameCommit != "" {
+				line += fmt.Sprintf("  (%s %s)", yellow(abbreviateHash(match.BlameCommit)), match.BlameAuthor)
+			}
+			if noisy[match.Path] {
+				line = dim(line)
+			}
+			if g.underline && !binary {
+				if carets := underline(text, g.filePatternsOf(query), g.filesCaseSensitive()); carets != "" {
+					line += "\n" + strings.Repeat(" ", utf8.RuneCountInString(prefix)) + carets
+				}
+			}
+			if g.explain {
+				line += fmt.Sprintf("\n   explain: %s", g.explainFileMatch(match.Content, query))
+			}
+			return line
+		})
+		for i, line := range rendered {
+			if g.groupFiles && (i == 0 || matches[i].Path != matches[i-1].Path) {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Println(bold(g.pathPrefix + matches[i].Path))
+			}
+			fmt.Println(line)
+		}
+		if shown == g.maxFiles {
+			g.decorf("... (showing first %d matches)\n", g.maxFiles)
+		}
+		fileMatchCount, fileCount = shown, countFiles(matches)
+		g.lastFiles = matches
+	}
+
+	if g.suppressed > 0 {
+		g.decorf("\n... (%d more results suppressed by the limit of %d results)\n", g.suppressed, g.maxResults)
+	}
+	g.decorf("\nFound %d commit matches and %d file matches across %d files.\n", commitCount, fileMatchCount, fileCount)
+	g.decorf("\n")
 }
 
 func main() {
 	var (
-		repoPath = flag.String("path", ".", "Path to git repository")
-		query    = flag.String("query", "", "Search query (if empty, enters interactive mode)")
-		showHelp = flag.Bool("help", false, "Show help information")
+		repoPath  = flag.String("path", ".", "Path to git repository")
+		repoRoot  = flag.String("repo-root", "", "Repository root, making -path a subdirectory scope within it")
+		match     = flag.String("match", "any", "How repeated -query terms combine: any or all")
+		author    = flag.String("author", "", "Only search commits whose author name or email matches this pattern")
+		authorRe  = flag.String("author-regex", "", "Only search commits whose 'Name <email>' matches this git regular expression, ignoring case")
+		committer = flag.String("committer", "", "Only search commits whose committer name or email matches this pattern")
+		dateFmt   = flag.String("date-format", "short", "Format of commit dates: short, iso, relative or unix")
+		dateField = flag.String("date-field", "author", "Date shown for commits: author or committer")
+		since     = flag.String("since", "", "Only search commits more recent than a date, e.g. '2024-01-01' or '2 weeks ago'")
+		until     = flag.String("until", "", "Only search commits older than a date, e.g. '2024-06-30' or 'yesterday'")
+		caseSens  = flag.Bool("case-sensitive", false, "Match the query's case exactly in commit messages and files")
+		commCase  = flag.Bool("commit-case-sensitive", false, "Override -case-sensitive for commit messages, tags, stashes, notes and reflog")
+		fileCase  = flag.Bool("file-case-sensitive", false, "Override -case-sensitive for file contents")
+		regex     = flag.Bool("regex", false, "Match file contents with an extended regular expression (git grep -E)")
+		word      = flag.Bool("word", false, "Only match whole words in file contents (git grep -w)")
+		allBranch = flag.Bool("all-branches", false, "Search the commit messages of every branch and tag, not just HEAD")
+		bodyOnly  = flag.Bool("body-only", false, "Only match the query against commit bodies, not subjects")
+		noMerges  = flag.Bool("no-merges", false, "Leave merge commits out of commit searches")
+		onlyMerge = flag.Bool("merges-only", false, "Only search merge commits")
+		commitArg = flag.String("commit", "", "Only search the files changed by this commit")
+		staged    = flag.Bool("staged", false, "Only search the files with staged changes")
+		modified  = flag.Bool("modified", false, "Only search the files with unstaged changes")
+		firstPar  = flag.Bool("first-parent", false, "Only search the first-parent history, leaving out the commits of merged branches")
+		invert    = flag.Bool("invert", false, "Show the commits and file lines that do NOT match the query")
+		reverse   = flag.Bool("reverse", false, "Show the oldest matching commits first")
+		depth     = flag.Int("depth", 0, "Only search the last N commits, matching or not (0 searches all history)")
+		fuzzy     = flag.Bool("fuzzy", false, "Rank recent commit subjects by similarity to the query, tolerating typos")
+		fuzzyWin  = flag.Int("fuzzy-window", 500, "Number of recent commits whose subjects -fuzzy ranks")
+		diffSrch  = flag.Bool("diff-search", false, "Also list commits that added or removed the query in code (git log -S, slow)")
+		srchTags  = flag.Bool("search-tags", false, "Also list tags whose name or annotation message matches the query")
+		srchNotes = flag.Bool("search-notes", false, "Also list commits whose git notes match")
+		srchRflog = flag.Bool("search-reflog", false, "Also list HEAD reflog entries whose message matches")
+		srchStash = flag.Bool("search-stashes", false, "Also list stash entries whose message (or changes, with -diff-search) match")
+		expr      = flag.String("expr", "", "Boolean expression for file search, e.g. 'foo AND NOT bar'")
+		headOnly  = flag.Bool("head-only", false, "Only search tracked file contents, never walking history")
+		noBanner  = flag.Bool("no-banner", false, "Skip the last commit banner")
+		quiet     = flag.Bool("quiet", false, "Only print the match lines, without the banner, headers and summaries")
+		patch     = flag.String("patch-file", "", "Search an mbox/patch file instead of a repository")
+		blame     = flag.Bool("blame", false, "Show the commit and author that last changed each matched line")
+		symbols   = flag.Bool("symbols", false, "Annotate file matches with the enclosing symbol from a ctags tags file")
+		rangeArg  = flag.String("range", "", "Revision range to search commits in, e.g. 'v1.0..v2.0' (same as the positional argument)")
+		lastTag   = flag.Bool("since-last-tag", false, "Only search commits made since the most recent tag")
+		mergeBase = flag.String("merge-base", "", "Only search commits since the merge base of 'refA' (and HEAD) or 'refA,refB'")
+		dimNoise  = flag.Bool("dim-noise", false, "Dim matches from files with many hits (terminal only)")
+		noiseMax  = flag.Int("noise-threshold", 5, "Matches per file above which -dim-noise dims a file")
+		sizeHist  = flag.Bool("size-histogram", false, "Show a histogram of lines changed per commit, for commits matching -query or all")
+		textconv  = flag.Bool("textconv", false, "Apply .gitattributes textconv filters when searching files")
+		inclBin   = flag.Bool("include-binary", false, "Match the lines of binary files too (git grep -a)")
+		timing    = flag.Bool("timing", false, "Write how long each git command took and the total time to stderr")
+		debugJSON = flag.Bool("debug-json", false, "Write the git commands that were run to stderr as JSON argv arrays")
+		dryRun    = flag.Bool("dry-run", false, "Print the git commands of each search to stderr instead of running them")
+		topFiles  = flag.Int("top-files", 0, "Rank the N files with the highest match density (matches per KB) for -query")
+		aheadBhd  = flag.Bool("ahead-behind", false, "Show how far the current branch is ahead/behind its upstream in the banner")
+		inDiff    = flag.Bool("in-diff", false, "Search only the lines added by the uncommitted changes (git diff and git diff --cached)")
+		coauthors = flag.Bool("coauthors", false, "Count the commits crediting each Co-authored-by co-author, of the commits matching -query if given")
+		byAuthor  = flag.Bool("group-by-author", false, "Count the commits matching -query per author instead of listing them")
+		findAuthr = flag.Bool("search-authors", false, "List author identities whose name or email contains -query")
+		truncate  = flag.Int("truncate", 0, "Shorten file match lines to N characters around the match (0 shows them whole)")
+		binPrev   = flag.Int("max-binary-preview", 64, "Show binary file matches as a hex preview of at most N bytes (0 shows them raw)")
+		multi     = flag.String("multi", "", "Search every git repository directly inside this directory")
+		concurcy  = flag.Int("concurrency", 4, "Number of repositories searched at the same time with -multi")
+		checkpt   = flag.String("checkpoint", "", "Record the repositories a -multi search finished in this file")
+		resumeCp  = flag.Bool("resume-checkpoint", false, "Reuse the repositories recorded in the -checkpoint file by an earlier run")
+		serve     = flag.String("serve", "", "Answer searches over HTTP on this address, e.g. 8080 or localhost:8080")
+		noIndex   = flag.Bool("no-index", false, "Search a plain directory that need not be a git repository")
+		at        = flag.String("at", "", "Search the files of a tag, branch or commit instead of the working tree")
+		untracked = flag.Bool("untracked", false, "Also search untracked files that aren't ignored")
+		recurseSM = flag.Bool("recurse-submodules", false, "Also search the files of checked out submodules")
+		withStat  = flag.Bool("with-stat", false, "List the files each matching commit changed under it (one git call per commit)")
+		renames   = flag.Bool("show-renames-in-history", false, "Mark the matching commits that renamed the -follow file with its old and new path")
+		bodySnip  = flag.Bool("body-snippets", false, "Show the highlighted body excerpt of commits that matched in their body")
+		fallback  = flag.String("fallback", "", "Pattern to retry with when the query finds no matches")
+		firstIntr = flag.String("first-introduced", "", "Print the oldest commit whose changes added this string instead of searching")
+		show      = flag.String("show", "", "Print the details of the commit with this (abbreviated) hash instead of searching")
+		stats     = flag.Bool("stats", false, "Print repository statistics (commits, contributors, files, first and last commit) instead of searching")
+		authorMap = flag.Bool("author-map", false, "Suggest .mailmap entries for authors that look like the same person")
+		similar   = flag.Float64("similarity", 0.85, "Name similarity (0-1) at which -author-map treats identities as one person")
+		repoPfx   = flag.Bool("repo-prefix", false, "Prefix file match paths with the repository path relative to the current directory")
+		explain   = flag.Bool("explain", false, "Explain which field and pattern made each result match")
+		commitFmt = flag.String("commit-format", "", "Go text/template for each matching commit, e.g. '{{.ShortHash}} {{.Subject}}'")
+		bodyLines = flag.Int("body-lines", 0, "Show up to N lines of each commit body (0: bodies only in the banner, unabridged)")
+		cfgFiles  = flag.Bool("config-files", false, "Only search common config files (*.yaml, *.json, *.toml, Dockerfile, ...)")
+		minBody   = flag.Int("min-body-length", 0, "Only show commits whose body has at least N characters")
+		tree      = flag.Bool("tree", false, "Show matching files as a directory tree with match counts")
+		underline = flag.Bool("underline", false, "Mark the matched text of file matches with a line of ^ carets")
+		countOnly = flag.Bool("count", false, "Only list the number of matching lines per file, highest first, like grep -c")
+		filesOnly = flag.Bool("files-only", false, "Only list the paths of matching files, like grep -l")
+		parChunks = flag.Bool("parallel-file-chunks", false, "Format file matches in chunks on a pool of -threads workers")
+		threads   = flag.Int("threads", runtime.NumCPU(), "Number of workers used by -parallel-file-chunks")
+		ignoreWS  = flag.Bool("ignore-whitespace", false, "Ignore whitespace-only changes in diff based searches (git -w)")
+		hotspots  = flag.Int("hotspots", 0, "Rank the N most frequently changed files matching -query (or all files)")
+		noEmoji   = flag.Bool("strip-emoji", false, "Remove leading emoji and :shortcodes: from displayed commit subjects")
+		maxCommit = flag.Int("max-commits", 10, "Maximum number of commits shown per commit section")
+		maxFiles  = flag.Int("max-files", 20, "Maximum number of file content matches shown")
+		maxSize   = flag.String("max-file-size", "", "Leave out the matches of files larger than this, in bytes or with k, M or G (e.g. 500k)")
+		group     = flag.Bool("group", false, "List file matches under a header per file instead of prefixing each with its path")
+		fileSort  = flag.String("sort", "none", "Order of file matches: none (as found), path, or count (files with most matches first)")
+		lineMin   = flag.Int("line-min", 0, "Only show file matches on this line number or later")
+		lineMax   = flag.Int("line-max", 0, "Only show file matches on this line number or earlier (0: no limit)")
+		maxRes    = flag.Int("max-results", 1000, "Cap on the total results shown by a search across all sections (0: no cap)")
+		recent    = flag.Int("recent-files", 0, "List the N most recently changed files matching -query (or all files)")
+		tmplCheck = flag.Bool("commit-template-check", false, "Report recent commits whose subjects don't match the commit template")
+		tmpl      = flag.String("commit-template", "", "Commit subject regex (default: gst.commitTemplate git config or conventional commits)")
+		tmplCount = flag.Int("check-count", 50, "Number of recent commits checked by -commit-template-check")
+		color     = flag.String("color", "auto", "Color output: auto (terminal only), always or never")
+		logLevel  = flag.String("log-level", "info", "Least severe messages written to stderr: debug, info, warn or error")
+		retries   = flag.Int("retries", 0, "Run git commands failing on a lock held by another git process again up to N times")
+		timeout   = flag.Duration("timeout", 30*time.Second, "Time limit for the git commands of a search, e.g. 10s or 2m (0: none)")
+		gitBin    = flag.String("git-bin", "git", "git executable to run, by name on PATH or as a path")
+		jsonPrety = flag.Bool("json-pretty", false, "Indent -format json output by two spaces for reading")
+		separator = flag.String("separator", "", "Print commits and file matches as one line of fields joined by this character ('\\t' for a tab)")
+		format    = flag.String("format", "text", "Output format of search results: text, json, jsonl or csv (see -separator for delimited fields)")
+		batch     = flag.Bool("batch", false, "Search for each line of stdin without prompts, exiting at EOF")
+		pageSize  = flag.Int("page-size", 25, "Lines of interactive results shown before waiting for Enter")
+		noPager   = flag.Bool("no-pager", false, "Show interactive results without pausing between pages")
+		histSize  = flag.Int("history-size", 500, "Number of interactive queries kept in ~/.gst_history (0: no history)")
+		output    = flag.String("output", "", "Write results to this file instead of stdout, with the banner on stderr")
+		force     = flag.Bool("force", false, "Let -output overwrite an existing file")
+		noConfig  = flag.Bool("no-config", false, "Ignore the .gstrc files of the repository and home directory")
+		validate  = flag.Bool("validate", false, "Only check the given flags for conflicts and exit")
+		showHelp  = flag.Bool("help", false, "Show help information")
 	)
+	var queries, pathFilter, exts, presets, follow, trailers stringList
+	flag.Var(&queries, "query", "Search query, repeatable for several terms (if empty, enters interactive mode)")
+	flag.Var(&exts, "ext", "Only search files with this extension, e.g. 'go' (repeatable)")
+	flag.Var(&trailers, "trailer", "Only search commits with this trailer, 'key' or 'key=value' (repeatable)")
+	flag.Var(&follow, "follow", "Only search the commits that changed this file, following its renames")
+	flag.Var(&presets, "preset", "Only search the files of a language group: "+strings.Join(presetNames(), ", ")+" (repeatable)")
+	flag.Var(&pathFilter, "path-filter", "Pathspec limiting file search, e.g. 'src/*.go' or ':(exclude)vendor' (repeatable)")
+	flag.StringVar(dateField, "date-order", "author", "Same as -date-field")
 	flag.Parse()
 
+	// Config files fill in the flags not given on the command line, which
+	// alone are checked for combinations that make no sense
+	given := givenFlags(flag.CommandLine)
+	if !*noConfig {
+		dir, err := filepath.Abs(*repoPath)
+		if err != nil {
+			fatalf("Error resolving path: %v", err)
+		}
+		if err := applyConfig(flag.CommandLine, configPaths(dir)); err != nil {
+			fatalf("Error reading config: %v", err)
+		}
+	}
+
+	var query string
+	if len(queries) > 0 {
+		query = queries[0]
+	}
+	revArgs, pathArgs := splitArgs(flag.Args(), argsTerminated(), *repoPath)
+
 	if *showHelp {
 		fmt.Println("Git Commit Search Tool")
-		fmt.Println("Usage:")
+		fmt.Println("Usage: gst [flags] [revision-range] [[--] path...]")
 		fmt.Println("  -path string    Path to git repository (default: current directory)")
-		fmt.Println("  -query string   Search query (if empty, enters interactive mode)")
+		fmt.Println("  -repo-root string")
+		fmt.Println("                  Repository root; -path then scopes file search to a directory within it")
+		fmt.Println("  -query string   Search query (if empty, enters interactive mode); repeat for several terms")
+		fmt.Println("  -match string   Whether commits and files must match any (default) or all of the -query terms")
+		fmt.Println("  -author string  Only search commit messages by authors whose name or email matches")
+		fmt.Println("  -trailer key[=value]")
+		fmt.Println("                  Only search commits with this trailer, e.g. Signed-off-by or Reviewed-by=alice (repeatable)")
+		fmt.Println("  -author-regex string")
+		fmt.Println("                  Only search commits whose 'Name <email>' matches a git regular expression, e.g. 'alice\\|bob'")
+		fmt.Println("  -committer string")
+		fmt.Println("                  Only search commits whose committer name or email matches, e.g. after a rebase")
+		fmt.Println("  -since string   Only search commits committed after a date ('2024-01-01', '2 weeks ago')")
+		fmt.Println("  -until string   Only search commits committed before a date")
+		fmt.Println("  -date-field string")
+		fmt.Println("                  Date shown for commits, author or committer (default: author)")
+		fmt.Println("  -date-order string")
+		fmt.Println("                  Same as -date-field")
+		fmt.Println("  -date-format string")
+		fmt.Println("                  How dates are shown: short, iso, relative ('3 days ago') or unix (default: short)")
+		fmt.Println("  -case-sensitive Match the exact case of the query instead of ignoring case")
+		fmt.Println("  -commit-case-sensitive")
+		fmt.Println("                  Override -case-sensitive for commit messages, e.g. =false to ignore case only there")
+		fmt.Println("  -file-case-sensitive")
+		fmt.Println("                  Override -case-sensitive for file contents")
+		fmt.Println("  -regex          Treat the query as an extended regular expression in file search, e.g. 'func\\s+\\w+Handler'")
+		fmt.Println("  -word           Only match whole words in file contents, so 'id' doesn't match 'width'")
+		fmt.Println("  -all-branches   Search commit messages reachable from any branch or tag, not just HEAD")
+		fmt.Println("  -body-only      Only match commit bodies, e.g. 'BREAKING CHANGE:' footers, ignoring subjects")
+		fmt.Println("  -no-merges      Leave merge commits, e.g. \"Merge branch ...\", out of commit searches")
+		fmt.Println("  -merges-only    Only search merge commits")
+		fmt.Println("  -commit string  Only search the files a commit changed, e.g. to review what a fix touched")
+		fmt.Println("  -staged         Only search the files with staged changes, e.g. before committing")
+		fmt.Println("  -modified       Only search the files with unstaged changes")
+		fmt.Println("  -in-diff        Search only the lines added by uncommitted changes, e.g. for leftover debug")
+		fmt.Println("                  output; -staged or -modified narrow it to one of them")
+		fmt.Println("  -first-parent   Only search mainline history, skipping commits made on merged branches")
+		fmt.Println("  -follow file    Only search the commits that changed this file, also before it was renamed")
+		fmt.Println("  -show-renames-in-history")
+		fmt.Println("                  Mark the commits that renamed the -follow file with its old and new path")
+		fmt.Println("  -invert         Show the commits and file lines that do NOT match, e.g. commits without a ticket ID")
+		fmt.Println("  -reverse        Show the oldest matching commits first, e.g. to find where a bug was introduced")
+		fmt.Println("  -depth int      Only search the last N commits, whether they match or not; -max-commits")
+		fmt.Println("                  then limits how many of the matches among them are shown")
+		fmt.Println("  -fuzzy          Rank recent commit subjects by similarity to the query, tolerating typos")
+		fmt.Println("  -fuzzy-window int")
+		fmt.Println("                  Number of recent commits whose subjects -fuzzy ranks (default: 500)")
+		fmt.Println("  -diff-search    Also list commits whose changes added or removed the query (pickaxe, can be slow)")
+		fmt.Println("  -search-tags    Also list tags whose name or annotation message matches the query")
+		fmt.Println("  -search-stashes Also list stash entries whose message, or changes with -diff-search, match")
+		fmt.Println("  -search-notes   Also list commits whose git notes (e.g. review metadata) match the query")
+		fmt.Println("  -search-reflog  Also list HEAD reflog entries whose message matches, e.g. to find a lost commit")
+		fmt.Println("  -expr string    Boolean expression for file search (AND, OR, NOT, parentheses)")
+		fmt.Println("  -ahead-behind   Show how many commits the branch is ahead/behind its upstream in the banner")
+		fmt.Println("  -no-index       Search any directory with git grep --no-index; no history or banner")
+		fmt.Println("  -multi dir      Search every git repository directly inside dir, listing the results by repository")
+		fmt.Println("  -concurrency int")
+		fmt.Println("                  Number of repositories searched at the same time with -multi (default: 4)")
+		fmt.Println("  -checkpoint file")
+		fmt.Println("                  Record the repositories a -multi search finished in file as it goes")
+		fmt.Println("  -resume-checkpoint")
+		fmt.Println("                  Resume an interrupted -multi search from its -checkpoint file")
+		fmt.Println("  -serve addr     Answer GET /search?q=... requests with JSON results, on localhost unless addr names a host")
+		fmt.Println("  -at string      Search the files as they are in a tag, branch or commit, without checking it out")
+		fmt.Println("  -untracked      Also search untracked (but not ignored) files of the repository's working tree;")
+		fmt.Println("                  unlike -no-index, history is still searched and it needs a repository")
+		fmt.Println("  -recurse-submodules")
+		fmt.Println("                  Also search the files of checked out submodules (git 2.12 or later)")
+		fmt.Println("  -fallback string")
+		fmt.Println("                  Retry commit and file searches with this pattern when the query finds nothing")
+		fmt.Println("  -repo-prefix    Prefix file match paths with the repository's path relative to the current directory")
+		fmt.Println("  -explain        Explain which field, pattern and column made each result match")
+		fmt.Println("  -head-only      Only search file contents, skipping all commit history lookups")
+		fmt.Println("  -no-banner      Skip the last commit banner")
+		fmt.Println("  -quiet          Only print match lines, without the banner, section headers or summaries")
+		fmt.Println("  -ext extension  Only search files with this extension, e.g. -ext go -ext mod (repeatable)")
+		fmt.Printf("  -preset name    Only search the files of a language group: %s (repeatable)\n", strings.Join(presetNames(), ", "))
+		fmt.Println("  -path-filter pathspec")
+		fmt.Println("                  Limit file search to a pathspec such as 'src/*.go' or ':(exclude)vendor' (repeatable)")
+		fmt.Println("  -config-files   Only search config files (*.yaml, *.yml, *.json, *.toml, *.ini, Dockerfile, *.env)")
+		fmt.Println("  -max-commits int")
+		fmt.Println("                  Maximum number of commits shown per commit section (default: 10)")
+		fmt.Println("  -max-files int  Maximum number of file content matches shown (default: 20)")
+		fmt.Println("  -max-file-size string")
+		fmt.Println("                  Leave out the matches of files larger than this, e.g. 500k or 1M (generated files)")
+		fmt.Println("  -group          List file matches under a header per file, like grep --heading")
+		fmt.Println("  -sort string    Order of file matches: none (as git finds them), path (by path and line)")
+		fmt.Println("                  or count (files with the most matches first) (default: none)")
+		fmt.Println("  -line-min int   Only show file matches on this line or later")
+		fmt.Println("  -line-max int   Only show file matches on this line or earlier, e.g. -line-min 100 -line-max 200")
+		fmt.Println("  -max-results int")
+		fmt.Println("                  Cap on the total results of a search across all sections, 0 for none (default: 1000)")
+		fmt.Println("  -tree           Show matching files as a directory tree with match counts instead of lines")
+		fmt.Println("  -underline      Print a line of ^ carets under the matched text of each file match")
+		fmt.Println("  -files-only     Only list the paths of files with matches instead of the matching lines")
+		fmt.Println("  -count          Only list how many lines match in each file, highest count first")
+		fmt.Println("  -parallel-file-chunks")
+		fmt.Println("                  Format file matches in chunks on a worker pool, keeping their order")
+		fmt.Println("  -threads int    Number of -parallel-file-chunks workers (default: number of CPUs)")
+		fmt.Println("  -blame          Show the commit and author that last changed each matched line (git blame)")
+		fmt.Println("  -symbols        Annotate file matches with their enclosing symbol from a ctags tags file")
+		fmt.Println("  -textconv       Search through .gitattributes textconv filters (e.g. inside binary documents)")
+		fmt.Println("  -include-binary Match lines of binary files too instead of skipping them (git grep -a)")
+		fmt.Println("  -truncate int   Shorten file match lines to N characters around the match, e.g. minified code (default: 0, off)")
+		fmt.Println("  -max-binary-preview int")
+		fmt.Println("                  Show binary matches as a hex preview of at most N bytes, 0 shows them raw (default: 64)")
+		fmt.Println("  -range string   Only search commits in a revision range such as 'v1.0..v2.0' (or give it positionally)")
+		fmt.Println("  -since-last-tag Only search commits made since the most recent tag (unreleased changes)")
+		fmt.Println("  -merge-base string")
+		fmt.Println("                  Only search commits since the merge base of refA and HEAD ('refA')")
+		fmt.Println("                  or of two refs ('refA,refB'), i.e. the commits unique to a branch")
+		fmt.Println("  -dim-noise      Dim matches from files exceeding -noise-threshold hits (terminal only)")
+		fmt.Println("  -noise-threshold int")
+		fmt.Println("                  Matches per file above which a file counts as noise (default: 5)")
+		fmt.Println("  -patch-file string")
+		fmt.Println("                  Search the messages and diffs of an mbox/patch file")
+		fmt.Println("  -strip-emoji    Remove leading emoji and gitmoji :shortcodes: from displayed commit subjects")
+		fmt.Println("  -body-snippets  Show a highlighted excerpt of the body for commits matching outside the subject")
+		fmt.Println("  -with-stat      List the files each matching commit changed, like git show --stat")
+		fmt.Println("  -min-body-length int")
+		fmt.Println("                  Only show commits whose body is at least N characters long")
+		fmt.Println("  -commit-format string")
+		fmt.Println("                  Template for each matching commit, with {{.Index}}, {{.Hash}}, {{.ShortHash}},")
+		fmt.Println("                  {{.Author}}, {{.Date}}, {{.Subject}} and {{.Body}}")
+		fmt.Println("  -body-lines int Show up to N body lines under each commit and in the banner (default: 0, off)")
+		fmt.Println("  -ignore-whitespace")
+		fmt.Println("                  Ignore whitespace-only changes in diff based searches such as -size-histogram")
+		fmt.Println("  -size-histogram Show a histogram of lines changed per commit (matching -query, or all commits)")
+		fmt.Println("  -search-authors List author names/emails containing -query, with their commit counts")
+		fmt.Println("  -group-by-author")
+		fmt.Println("                  Count the commits whose message matches -query per author, most commits first")
+		fmt.Println("  -coauthors      Count the commits crediting each Co-authored-by co-author, most first;")
+		fmt.Println("                  with -query only the commits whose message matches")
+		fmt.Println("  -top-files int  Rank the N files with the most matches per KB instead of listing matches")
+		fmt.Println("  -stats          Print the commit, contributor and tracked file counts and the first and last commit dates")
+		fmt.Println("  -show string    Print the full hash, author, date and message of the commit with this hash prefix")
+		fmt.Println("  -first-introduced string")
+		fmt.Println("                  Print the details of the oldest commit that added this string to the code")
+		fmt.Println("  -author-map     Suggest .mailmap entries clustering identities that look like the same person")
+		fmt.Println("  -similarity float")
+		fmt.Println("                  Name similarity from 0 to 1 at which -author-map merges identities (default: 0.85)")
+		fmt.Println("  -hotspots int   Rank the N most frequently changed files matching -query (or all tracked files)")
+		fmt.Println("  -recent-files int")
+		fmt.Println("                  List the N files matching -query (or all tracked files) changed most recently")
+		fmt.Println("  -commit-template-check")
+		fmt.Println("                  Report recent commits whose subjects don't match the template, exit 1 on failure")
+		fmt.Println("  -commit-template string")
+		fmt.Println("                  Subject regex (default: gst.commitTemplate git config or conventional commits)")
+		fmt.Println("  -check-count int")
+		fmt.Println("                  Number of recent commits to check (default: 50)")
+		fmt.Println("  -color string   Color hashes, headers and matches: auto (terminal only), always or never")
+		fmt.Println("  -git-bin string git executable, e.g. /opt/git/bin/git (default: git from PATH)")
+		fmt.Println("  -timeout duration")
+		fmt.Println("                  Time limit for the git commands of each search, 0 for none (default: 30s)")
+		fmt.Println("  -retries int    Retry git commands failing on a lock (e.g. index.lock) up to N times, with backoff")
+		fmt.Println("  -format string  Output format of search results: text, json, jsonl or csv (default: text),")
+		fmt.Println("                  or delimited fields with -separator")
+		fmt.Println("  -json-pretty    Indent -format json output for reading instead of writing one line per search")
+		fmt.Println("  -separator string")
+		fmt.Println("                  Print each commit as hash, author, date and subject and each file match as")
+		fmt.Println("                  path, line and content, joined by this character ('\\t' for a tab)")
+		fmt.Println("  -batch          Read queries from stdin, one per line, without prompts; exits at end of input")
+		fmt.Println("  -page-size int  Lines of interactive results shown before waiting for Enter (default: 25)")
+		fmt.Println("  -no-pager       Show interactive results without pausing between pages")
+		fmt.Println("  -history-size int")
+		fmt.Println("                  Interactive queries kept in ~/.gst_history, 0 to keep none (default: 500)")
+		fmt.Println("  -output string  Write results to a file instead of stdout; the banner and status lines go to stderr")
+		fmt.Println("  -force          Overwrite an existing -output file")
+		fmt.Println("  -no-config      Ignore the .gstrc default flags of the repository root and home directory")
+		fmt.Println("  -validate       Check the given flags for conflicting combinations and exit (2 if invalid)")
+		fmt.Println("  -debug-json     Write the git commands that were run to stderr as JSON argv arrays")
+		fmt.Println("  -timing         Write how long each git command took, and the total, to stderr")
+		fmt.Println("  -log-level string")
+		fmt.Println("                  Least severe messages written to stderr: debug (every git command and its exit status),")
+		fmt.Println("                  info, warn or error (default: info)")
+		fmt.Println("  -dry-run        Print the git commands of each search to stderr instead of running them")
 		fmt.Println("  -help           Show this help message")
+		fmt.Println("\nExit status:")
+		fmt.Println("  0 if a -query or -expr search matched, 1 if it matched nothing, 2 on errors;")
+		fmt.Println("  interactive and -batch searches exit 0; 130 if Ctrl-C stopped the search")
 		fmt.Println("\nExamples:")
 		fmt.Println("  ./git-search                          # Interactive mode in current directory")
 		fmt.Println("  ./git-search -query \"bug fix\"         # Search for 'bug fix'")
 		fmt.Println("  ./git-search -path /path/to/repo      # Use different repository")
+		fmt.Println("  ./git-search -expr 'TODO AND NOT test' # Files with TODO but not test on the same line")
+		fmt.Println("  ./git-search -query fix v1.0..v2.0    # Search commits between two tags")
+		fmt.Println("  ./git-search -patch-file fix.mbox -query token # Search an emailed patch series")
+		return
+	}
+
+	// Check the whole command line up front so nothing runs half-configured
+	if problems := validateFlags(flag.CommandLine, given, revArgs); len(problems) > 0 {
+		fmt.Fprintln(os.Stderr, "Invalid flags:")
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+		}
+		os.Exit(2)
+	} else if *validate {
+		fmt.Println("Flags are valid.")
+		return
+	}
+	minLogLevel, _ = parseLogLevel(*logLevel)
+
+	// A single search exits like grep; deferred first, so that it runs after
+	// the other deferred calls
+	status := exitMatch
+	defer func() {
+		if status != exitMatch {
+			os.Exit(status)
+		}
+	}()
+
+	// Results go to the -output file, so the process's stdout is swapped
+	// for it; tool.info keeps the banner and status lines on stderr
+	if *output != "" {
+		f, err := createOutput(*output, *force)
+		if err != nil {
+			errorf("Error creating output file: %v", err)
+			status = exitError
+			return
+		}
+		defer f.Close()
+		os.Stdout = f
+	}
+
+	// Patch files are searched on their own, no repository required
+	if *patch != "" {
+		patchTool := NewGitSearchTool("")
+		patchTool.caseSensitive = *caseSens
+		patchTool.setCaseOverrides(flag.CommandLine, commCase, fileCase)
+		patchTool.regex, patchTool.wholeWord = *regex, *word
+		status = patchTool.displayPatchSearch(*patch, query)
+		return
+	}
+
+	// Everything below runs git, so a missing git is reported once up front
+	// rather than as a failure of the first command
+	if _, err := exec.LookPath(*gitBin); err != nil {
+		if *gitBin == "git" {
+			errorf("git executable not found on PATH; install git or set -git-bin to its path")
+		} else {
+			errorf("git executable %q not found or not executable: %v; install git or fix -git-bin", *gitBin, err)
+		}
+		status = exitNoGit
 		return
 	}
 
 	// Resolve absolute path
 	absPath, err := filepath.Abs(*repoPath)
 	if err != nil {
-		log.Fatalf("Error resolving path: %v", err)
+		errorf("Error resolving path: %v", err)
+		status = exitError
+		return
 	}
 
 	// Check if directory exists
 	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		log.Fatalf("Directory does not exist: %s", absPath)
+		errorf("Directory does not exist: %s", absPath)
+		status = exitError
+		return
 	}
 
 	tool := NewGitSearchTool(absPath)
 
+	// An explicit repository root turns the search path into a scope within it
+	if *repoRoot != "" {
+		absRoot, err := filepath.Abs(*repoRoot)
+		if err != nil {
+			errorf("Error resolving repository root: %v", err)
+			status = exitError
+			return
+		}
+
+		tool = NewGitSearchTool(absRoot)
+		if !tool.isGitRepo() {
+			errorf("Not a git repository: %s", absRoot)
+			status = exitError
+			return
+		}
+
+		rel, err := filepath.Rel(absRoot, absPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			errorf("Search path %s is outside repository root %s", absPath, absRoot)
+			status = exitError
+			return
+		}
+		if rel != "." {
+			tool.pathspecs = []string{filepath.ToSlash(rel)}
+		}
+	}
+	if *output != "" {
+		tool.info = os.Stderr
+	}
+	tool.headOnly = *headOnly
+	tool.quiet = *quiet
+	tool.gitBin = *gitBin
+	if err := tool.checkGitVersion(); err != nil {
+		errorf("Error checking git version: %v", err)
+		status = exitError
+		return
+	}
+	if problems := tool.unsupportedFlags(flag.CommandLine); len(problems) > 0 {
+		fmt.Fprintln(os.Stderr, "Unsupported flags:")
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+		}
+		status = exitError
+		return
+	}
+	tool.timeout = *timeout
+	tool.retries = *retries
+	defer tool.startTimeout()()
+	colorMode = *color
+	tool.format = *format
+	tool.jsonPretty = *jsonPrety
+	if *separator != "" && tool.format == "text" {
+		tool.separator, _ = parseSeparator(*separator)
+		// The delimited lines are all there is, like with -quiet
+		tool.quiet = true
+		if strings.ContainsAny(tool.separator, " ,.:;-_/'\"") {
+			warnf("-separator %q often appears in author names, subjects and file contents, which aren't escaped; consider a tab or |", tool.separator)
+		}
+	}
+	if *debugJSON {
+		tool.recordCommands = true
+		defer tool.writeDebugJSON(os.Stderr)
+	}
+	if *timing {
+		tool.timing = true
+		defer tool.writeTimings(os.Stderr, time.Now())
+	}
+	tool.symbols = *symbols
+	tool.blame = *blame
+	tool.showAheadBehind = *aheadBhd
+	tool.textconv = *textconv
+	tool.includeBinary = *inclBin
+	tool.bodySnippets = *bodySnip
+	tool.withStat = *withStat
+	tool.fallback = *fallback
+	tool.explain = *explain
+	tool.bodyLines = *bodyLines
+	if *commitFmt != "" {
+		tmpl, err := parseCommitFormat(*commitFmt)
+		if err != nil {
+			errorf("Invalid commit format: %v", err)
+			status = exitError
+			return
+		}
+		tool.commitFormat = tmpl
+	}
+	tool.minBodyLength = *minBody
+	tool.tree = *tree
+	tool.filesOnly = *filesOnly
+	tool.countOnly = *countOnly
+	tool.underline = *underline
+	if *parChunks {
+		tool.threads = *threads
+	}
+	tool.ignoreWhitespace = *ignoreWS
+	tool.maxResults = *maxRes
+	tool.maxCommits = *maxCommit
+	tool.maxFiles = *maxFiles
+	tool.lineMin = *lineMin
+	tool.lineMax = *lineMax
+	tool.pathFilters = pathFilter
+	tool.stripEmoji = *noEmoji
+	tool.author = *author
+	tool.authorRegex = *authorRe
+	for _, spec := range trailers {
+		filter, err := parseTrailerFilter(spec)
+		if err != nil {
+			errorf("Invalid -trailer: %v", err)
+			status = exitError
+			return
+		}
+		tool.trailers = append(tool.trailers, filter)
+	}
+	tool.caseSensitive = *caseSens
+	tool.setCaseOverrides(flag.CommandLine, commCase, fileCase)
+	tool.regex = *regex
+	tool.wholeWord = *word
+	tool.allBranches = *allBranch
+	tool.diffSearch = *diffSrch
+	tool.searchTags = *srchTags
+	tool.searchStashes = *srchStash
+	tool.searchNotes = *srchNotes
+	tool.searchReflog = *srchRflog
+	tool.bodyOnly = *bodyOnly
+	tool.reverse = *reverse
+	tool.depth = *depth
+	tool.invert = *invert
+	tool.noMerges = *noMerges
+	tool.mergesOnly = *onlyMerge
+	tool.firstParent = *firstPar
+	if len(follow) > 0 {
+		tool.follow = follow[0]
+	}
+	tool.showRenames = *renames
+	tool.fuzzy = *fuzzy
+	tool.fuzzyWindow = *fuzzyWin
+	if len(queries) > 1 {
+		tool.extraQueries = queries[1:]
+	}
+	tool.matchAll = *match == "all"
+	tool.committer = *committer
+	tool.committerDates = *dateField == "committer"
+	tool.dateFormat = *dateFmt
+	tool.fileSort = *fileSort
+	tool.groupFiles = *group
+	if *maxSize != "" {
+		tool.maxFileSize, _ = parseSize(*maxSize)
+	}
+	tool.since = *since
+	tool.until = *until
+	if sinceDate, err := time.Parse(time.DateOnly, *since); err == nil {
+		if untilDate, err := time.Parse(time.DateOnly, *until); err == nil && untilDate.Before(sinceDate) {
+			warnf("-until %s is before -since %s, no commits will match", *until, *since)
+		}
+	}
+	if *cfgFiles {
+		tool.filePatterns = tool.getConfigFilePatterns()
+	}
+	for _, ext := range exts {
+		tool.filePatterns = append(tool.filePatterns, "*."+strings.TrimPrefix(ext, "."))
+	}
+	for _, preset := range presets {
+		tool.filePatterns = append(tool.filePatterns, filePresets[preset]...)
+	}
+	// A directory inside a working tree searches the whole repository
+	foundRoot := *repoRoot == "" && !*noIndex && tool.findWorkTreeRoot()
+	if !*noIndex {
+		if tool.ignoreSpecs, err = readIgnoreFile(filepath.Join(tool.repoPath, ignoreFileName)); err != nil {
+			errorf("Error reading %s: %v", ignoreFileName, err)
+			status = exitError
+			return
+		}
+	}
+	// Path arguments are relative to -path, git's pathspecs to the root
+	if len(pathArgs) > 0 {
+		tool.pathspecs = nil
+		for _, arg := range pathArgs {
+			path := filepath.Join(absPath, arg)
+			if _, err := os.Stat(path); err != nil {
+				warnf("%s does not exist in %s", arg, absPath)
+			}
+			rel, err := filepath.Rel(tool.repoPath, path)
+			if err != nil {
+				errorf("Error resolving path %s: %v", arg, err)
+				status = exitError
+				return
+			}
+			tool.pathspecs = append(tool.pathspecs, filepath.ToSlash(rel))
+		}
+	}
+	if *repoPfx {
+		tool.pathPrefix = relativePrefix(tool.repoPath)
+	}
+	tool.binaryPreview = *binPrev
+	tool.truncate = *truncate
+	if *dimNoise {
+		tool.noiseThreshold = *noiseMax
+	}
+
+	if *expr != "" {
+		exprArgs, err := parseGrepExpr(*expr)
+		if err != nil {
+			errorf("Invalid expression: %v", err)
+			status = exitError
+			return
+		}
+		tool.fileExpr = *expr
+		tool.fileExprArgs = exprArgs
+	}
+
+	// Each repository of the directory gets a copy of the tool
+	if *multi != "" {
+		if len(pathArgs) > 0 {
+			errorf("-multi searches whole repositories and cannot be combined with paths")
+			status = exitError
+			return
+		}
+		tool.dryRun = *dryRun
+		dir, err := filepath.Abs(*multi)
+		if err != nil {
+			errorf("Error resolving %s: %v", *multi, err)
+			status = exitError
+			return
+		}
+		if *checkpt != "" {
+			if tool.checkpoint, err = openCheckpoint(*checkpt, checkpointArgs(flag.CommandLine), *resumeCp); err != nil {
+				errorf("Error reading checkpoint: %v", err)
+				status = exitError
+				return
+			}
+		}
+		status = tool.performMultiSearch(dir, query, *concurcy)
+		return
+	}
+
+	// Plain directories are searched without any history
+	if *noIndex {
+		tool.noIndex = true
+		tool.dryRun = *dryRun
+		tool.statusf("Directory: %s\n", absPath)
+		if *topFiles > 0 {
+			tool.displayTopFiles(query, *topFiles)
+			status = tool.reportStatus()
+		} else {
+			tool.performSearch(query)
+			status = tool.exitStatus()
+		}
+		return
+	}
+
 	// Check if it's a git repository
 	if !tool.isGitRepo() {
-		log.Fatalf("Not a git repository: %s", absPath)
+		errorf("Not inside a git repository: %s", absPath)
+		status = exitError
+		return
+	}
+
+	if tool.bare && (*untracked || *staged || *modified || *inDiff) {
+		errorf("-untracked, -staged, -modified and -in-diff need a working tree, %s is a bare repository", tool.repoPath)
+		status = exitError
+		return
+	}
+	if *at != "" {
+		if err := tool.validateTreeish(*at); err != nil {
+			errorf("Error: %v", err)
+			status = exitError
+			return
+		}
+		tool.at = *at
+		tool.statusf("Searching files at %s\n", *at)
+	}
+	tool.shallow = tool.isShallowRepository()
+	if tool.shallow && !*headOnly {
+		warnf("this is a shallow clone, commit history is truncated and older commits won't be found; run 'git fetch --unshallow' to search all of it")
+	}
+	tool.untracked = *untracked
+	if *recurseSM {
+		tool.recurseSubmodules = true
+		if missing := tool.uninitializedSubmodules(); len(missing) > 0 {
+			warnf("submodules %s aren't checked out and won't be searched, run 'git submodule update --init' first",
+				strings.Join(missing, ", "))
+		}
+	}
+
+	if foundRoot {
+		tool.statusf("Found repository root above %s\n", absPath)
+	}
+	tool.statusf("Git repository: %s\n", tool.repoPath)
+	if len(tool.pathspecs) > 0 {
+		tool.statusf("Search path: %s\n", tool.pathspecs[0])
+	}
+
+	if *tmplCheck {
+		pattern := *tmpl
+		if pattern == "" {
+			pattern = tool.getCommitTemplate()
+		}
+		template, err := regexp.Compile(pattern)
+		if err != nil {
+			errorf("Invalid commit template: %v", err)
+			status = exitError
+			return
+		}
+
+		passed, err := tool.displayCommitTemplateCheck(template, *tmplCount)
+		if err != nil {
+			errorf("Error checking commit template: %v", err)
+			status = exitError
+			return
+		}
+		if !passed {
+			status = exitNoMatch
+		}
+		return
+	}
+
+	// A positional argument or -range is a revision range scoping the
+	// commit search
+	revRange := *rangeArg
+	if len(revArgs) == 1 {
+		revRange = revArgs[0]
+	}
+	if revRange != "" {
+		if err := tool.validateRevRange(revRange); err != nil {
+			errorf("Error: %v", err)
+			status = exitError
+			return
+		}
+		tool.revRange = revRange
+		tool.statusf("Searching commits in range: %s\n", tool.revRange)
+	}
+
+	if *lastTag {
+		tag, err := tool.getLastTag()
+		if err != nil {
+			errorf("Error resolving last tag: %v", err)
+			status = exitError
+			return
+		}
+		if tag == "" {
+			tool.statusf("No tags found, searching all history.\n")
+		} else {
+			tool.statusf("Searching commits since tag: %s\n", tag)
+			tool.revRange = tag + "..HEAD"
+		}
+	}
+
+	if *mergeBase != "" {
+		refA, refB, found := strings.Cut(*mergeBase, ",")
+		if !found {
+			refB = "HEAD"
+		}
+		refA, refB = strings.TrimSpace(refA), strings.TrimSpace(refB)
+		if refA == "" || refB == "" {
+			errorf("-merge-base expects 'refA' or 'refA,refB'")
+			status = exitError
+			return
+		}
+
+		base, err := tool.getMergeBase(refA, refB)
+		if err != nil {
+			errorf("Error resolving merge base: %v", err)
+			status = exitError
+			return
+		}
+		tool.statusf("Merge base of %s and %s: %s\n", refA, refB, base)
+		tool.revRange = base + ".." + refB
+	}
+
+	// -commit, -staged and -modified narrow the file search down to the
+	// files changed within the search path, named literally
+	if *commitArg != "" || *staged || *modified {
+		var (
+			files   []string
+			changes string
+			err     error
+		)
+		switch {
+		case *commitArg != "":
+			changes = "changed in " + *commitArg
+			files, err = tool.filesChangedIn(*commitArg, tool.searchPathspecs(tool.pathFilters))
+		case *staged:
+			changes = "with staged changes"
+			files, err = tool.workingTreeChanges(true, tool.searchPathspecs(tool.pathFilters))
+		default:
+			changes = "with unstaged changes"
+			files, err = tool.workingTreeChanges(false, tool.searchPathspecs(tool.pathFilters))
+		}
+		if err != nil {
+			errorf("Error listing the files %s: %v", changes, err)
+			status = exitError
+			return
+		}
+		if len(files) == 0 {
+			tool.statusf("No files %s in the search path.\n", changes)
+			status = exitNoMatch
+			return
+		}
+		tool.statusf("Searching the %d files %s\n", len(files), changes)
+		tool.pathspecs, tool.pathFilters, tool.filePatterns = nil, nil, nil
+		for _, file := range files {
+			tool.pathspecs = append(tool.pathspecs, ":(top,literal)"+file)
+		}
+	}
+
+	// Only the searches are skipped, the repository and ranges above are
+	// still checked for real
+	tool.dryRun = *dryRun
+
+	if *serve != "" {
+		addr := serveAddr(*serve)
+		if !isLoopback(addr) {
+			warnf("-serve %s answers searches from other hosts too, without any authentication", addr)
+		}
+		if err := tool.serve(addr); err != nil {
+			errorf("Error serving searches: %v", err)
+			status = exitError
+		}
+		return
+	}
+
+	if *sizeHist {
+		tool.displayCommitSizeHistogram(query)
+		status = tool.reportStatus()
+		return
+	}
+
+	if *stats {
+		tool.displayStats()
+		status = tool.reportStatus()
+		return
+	}
+
+	if *show != "" {
+		tool.displayCommit(*show)
+		status = tool.reportStatus()
+		return
+	}
+
+	if *findAuthr {
+		tool.displayAuthorSearch(query)
+		status = tool.reportStatus()
+		return
 	}
 
-	fmt.Printf("Git repository: %s\n", absPath)
+	if *byAuthor {
+		tool.displayCommitsByAuthor(query)
+		status = tool.reportStatus()
+		return
+	}
+
+	if *coauthors {
+		tool.displayCoAuthors(query)
+		status = tool.reportStatus()
+		return
+	}
+
+	if *firstIntr != "" {
+		status = tool.displayFirstIntroduced(*firstIntr)
+		return
+	}
+
+	if *inDiff {
+		status = tool.displayDiffSearch(query, !*staged, !*modified)
+		return
+	}
+
+	if *authorMap {
+		tool.displayAuthorMap(*similar)
+		status = tool.reportStatus()
+		return
+	}
+
+	if *hotspots > 0 {
+		tool.displayHotspots(query, *hotspots)
+		status = tool.reportStatus()
+		return
+	}
+
+	if *recent > 0 {
+		tool.displayRecentFiles(query, *recent)
+		status = tool.reportStatus()
+		return
+	}
+
+	if *topFiles > 0 {
+		tool.displayTopFiles(query, *topFiles)
+		status = tool.reportStatus()
+		return
+	}
 
 	// Display last commit information
-	tool.displayLastCommit()
+	if !*noBanner && !tool.quiet && !*headOnly && !*dryRun && tool.format == "text" {
+		tool.displayLastCommit()
+	}
+
+	// Ctrl-C cancels a running search; outside of one it leaves an
+	// interactive session, and otherwise exits as usual
+	interactive := query == "" && *expr == "" && !*batch
+	tool.interrupts = handleInterrupts(func() {
+		if interactive {
+			fmt.Println()
+			tool.statusf("Goodbye!\n")
+			os.Exit(exitMatch)
+		}
+		os.Exit(exitInterrupted)
+	})
 
 	// Handle search
-	if *query != "" {
+	if query != "" || *expr != "" {
 		// Single query mode
-		tool.performSearch(*query)
+		tool.performSearch(query)
+		status = tool.exitStatus()
+	} else if *batch {
+		tool.batchSearch()
+		if tool.interrupted() {
+			status = exitInterrupted
+		}
+		return
 	} else {
 		// Interactive mode
+		if !*noPager {
+			tool.pageSize = *pageSize
+		}
+		if *histSize > 0 {
+			if path, err := defaultHistoryPath(); err != nil {
+				errorf("Error loading history: %v", err)
+			} else if tool.history, err = loadHistory(path, *histSize); err != nil {
+				errorf("Error loading history: %v", err)
+			}
+		}
 		fmt.Println("=== Interactive Search Mode ===")
 		fmt.Println("You can search for text in commit messages and file contents.")
+		fmt.Println("Separate several terms with ';' and type ':match all' or ':match any' to combine them.")
+		fmt.Println("Type ':format <name>' to switch the output format.")
+		fmt.Println("Type ':open N' to open file match N of the last search in $EDITOR.")
+		if tool.history != nil {
+			fmt.Println("Type ':history' to list past queries and '!N' to search query N again.")
+		}
 		tool.interactiveSearch()
 	}
 
-	fmt.Println("Goodbye!")
+	tool.statusf("Goodbye!\n")
 }
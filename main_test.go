@@ -0,0 +1,1952 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain runs the gst command line instead of the tests when
+// GST_TEST_MAIN is set, which is how runGst executes it. Both run with a
+// home directory of their own, so that no .gstrc, ~/.gst_history or git
+// config of the user gets in the way.
+func TestMain(m *testing.M) {
+	if os.Getenv("GST_TEST_MAIN") == "1" {
+		main()
+		os.Exit(exitMatch)
+	}
+
+	home, err := os.MkdirTemp("", "gst-test-home")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for name, value := range map[string]string{
+		"HOME":                home,
+		"XDG_CONFIG_HOME":     filepath.Join(home, ".config"),
+		"GIT_CONFIG_NOSYSTEM": "1",
+		"GIT_AUTHOR_NAME":     "Test User",
+		"GIT_AUTHOR_EMAIL":    "test@example.com",
+		"GIT_COMMITTER_NAME":  "Test User",
+		"GIT_COMMITTER_EMAIL": "test@example.com",
+		"EDITOR":              "",
+		"VISUAL":              "",
+	} {
+		os.Setenv(name, value)
+	}
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// runGst runs the gst command line with args in dir and returns what it
+// wrote to stdout and stderr and its exit status
+func runGst(t *testing.T, dir string, args ...string) (stdout, stderr string, status int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GST_TEST_MAIN=1")
+	var out, errOut strings.Builder
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		status = exitErr.ExitCode()
+	case err != nil:
+		t.Fatalf("running gst %s: %v", strings.Join(args, " "), err)
+	}
+	return out.String(), errOut.String(), status
+}
+
+// testRepo is a git repository in a temporary directory that tests commit
+// files to
+type testRepo struct {
+	t   *testing.T
+	dir string
+
+	// commits counts the commits made, each dated an hour after the one
+	// before so that the history order doesn't depend on the clock
+	commits int
+}
+
+// testEpoch is the date of the first commit of a testRepo
+var testEpoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestRepo creates an empty repository on branch main, skipping the test
+// when git isn't installed
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	r := &testRepo{t: t, dir: testDir(t)}
+	r.git("init", "-q", "-b", "main")
+	return r
+}
+
+// testDir returns a temporary directory by its real path, which is how git
+// reports the paths inside it
+func testDir(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// git runs a git command in the repository and returns its output
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	return r.gitEnv(nil, args...)
+}
+
+// gitEnv runs a git command with extra environment variables
+func (r *testRepo) gitEnv(env []string, args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("%v: %s", err, exitErr.Stderr)
+		}
+		r.t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return string(output)
+}
+
+// write writes a file of the working tree, creating its directories
+func (r *testRepo) write(path, content string) {
+	r.t.Helper()
+	path = filepath.Join(r.dir, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// commit writes files, given as path and content pairs, and commits every
+// change of the working tree with message, returning the commit's hash
+func (r *testRepo) commit(message string, files ...string) string {
+	r.t.Helper()
+	return r.commitEnv(nil, message, files...)
+}
+
+// commitEnv commits like commit with extra environment variables, such as
+// those of author
+func (r *testRepo) commitEnv(env []string, message string, files ...string) string {
+	r.t.Helper()
+	for i := 0; i+1 < len(files); i += 2 {
+		r.write(files[i], files[i+1])
+	}
+	date := testEpoch.Add(time.Duration(r.commits) * time.Hour).Format(time.RFC3339)
+	r.commits++
+
+	r.git("add", "-A")
+	env = append([]string{"GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=" + date}, env...)
+	r.gitEnv(env, "commit", "-q", "--allow-empty", "-m", message)
+	return r.head()
+}
+
+// head returns the hash of HEAD
+func (r *testRepo) head() string {
+	r.t.Helper()
+	return strings.TrimSpace(r.git("rev-parse", "HEAD"))
+}
+
+// author returns the environment making name and email the author of a
+// commit
+func author(name, email string) []string {
+	return []string{"GIT_AUTHOR_NAME=" + name, "GIT_AUTHOR_EMAIL=" + email}
+}
+
+// fakeGit writes an executable shell script standing in for git and
+// returns its path; realGit in the script runs the real git
+func fakeGit(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake git scripts need a POSIX shell")
+	}
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	path := filepath.Join(t.TempDir(), "git")
+	script = "#!/bin/sh\nrealGit=" + shellQuote([]string{git}) + "\n" + script
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// tool returns a tool searching the repository with the defaults of the
+// command line
+func (r *testRepo) tool() *GitSearchTool {
+	g := NewGitSearchTool(r.dir)
+	g.info = io.Discard
+	return g
+}
+
+// gst runs the gst command line in the repository
+func (r *testRepo) gst(args ...string) (stdout, stderr string, status int) {
+	r.t.Helper()
+	return runGst(r.t, r.dir, args...)
+}
+
+// debugCommands returns the git commands that -debug-json wrote to stderr
+func debugCommands(t *testing.T, stderr string) [][]string {
+	t.Helper()
+	for _, line := range strings.Split(stderr, "\n") {
+		if !strings.HasPrefix(line, `{"commands":`) {
+			continue
+		}
+		var log struct{ Commands [][]string }
+		if err := json.Unmarshal([]byte(line), &log); err != nil {
+			t.Fatalf("parsing -debug-json output %q: %v", line, err)
+		}
+		return log.Commands
+	}
+	t.Fatalf("no -debug-json output in stderr:\n%s", stderr)
+	return nil
+}
+
+// ranGit reports whether commands include the git subcommand name
+func ranGit(commands [][]string, name string) bool {
+	for _, command := range commands {
+		if len(command) > 1 && command[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHeadOnly(t *testing.T) {
+	r := newTestRepo(t)
+	for i := range 50 {
+		r.commit(fmt.Sprintf("Change needle %d", i), "file.txt", fmt.Sprintf("needle %d\n", i))
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantLog  bool
+		wantLine string
+	}{
+		{"full search", []string{"-query", "needle"}, true, "Change needle 49"},
+		{"head only", []string{"-head-only", "-query", "needle"}, false, "1. file.txt:1:needle 49"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, status := r.gst(append([]string{"-no-banner", "-debug-json"}, tt.args...)...)
+			if status != exitMatch {
+				t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+			}
+			commands := debugCommands(t, stderr)
+			if got := ranGit(commands, "log"); got != tt.wantLog {
+				t.Errorf("ran git log = %v, want %v: %q", got, tt.wantLog, commands)
+			}
+			if !strings.Contains(stdout, tt.wantLine) {
+				t.Errorf("output is missing %q:\n%s", tt.wantLine, stdout)
+			}
+			if !tt.wantLog && strings.Contains(stdout, "Commit") {
+				t.Errorf("-head-only output has commit sections:\n%s", stdout)
+			}
+		})
+	}
+}
+
+// commitSubjects returns the subjects of commits in order
+func commitSubjects(commits []CommitMatch) []string {
+	subjects := make([]string, len(commits))
+	for i, commit := range commits {
+		subjects[i] = commit.Subject
+	}
+	return subjects
+}
+
+// matchPaths returns the paths of file matches in order
+func matchPaths(matches []FileMatch) []string {
+	paths := make([]string, len(matches))
+	for i, match := range matches {
+		paths[i] = match.Path
+	}
+	return paths
+}
+
+func TestIgnoreWhitespaceDiffSearch(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add total", "calc.go", "total = a + b\n")
+	r.commit("Reindent total", "calc.go", "total  =  a + b\n")
+	r.commit("Use total", "calc.go", "total  =  a + b\nprint(total = a)\n")
+
+	tests := []struct {
+		ignoreWhitespace bool
+		want             []string
+	}{
+		{false, []string{"Use total", "Reindent total", "Add total"}},
+		{true, []string{"Use total", "Add total"}},
+	}
+	for _, tt := range tests {
+		g := r.tool()
+		g.ignoreWhitespace = tt.ignoreWhitespace
+		commits, err := g.searchInDiffs(SearchOptions{Query: "total = a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+			t.Errorf("ignoreWhitespace=%v: subjects = %q, want %q", tt.ignoreWhitespace, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorFilter(t *testing.T) {
+	r := newTestRepo(t)
+	r.commitEnv(author("Alice Smith", "alice@example.com"), "Fix parser crash")
+	r.commitEnv(author("Bob Jones", "bob@example.org"), "Fix parser leak")
+	r.commitEnv(author("Alice Smith", "alice@example.com"), "Fix lexer")
+	r.commitEnv(author("Bob Jones", "bob@example.org"), "Document parser")
+
+	tests := []struct {
+		author string
+		want   []string
+	}{
+		{"", []string{"Fix lexer", "Fix parser leak", "Fix parser crash"}},
+		{"Alice", []string{"Fix lexer", "Fix parser crash"}},
+		{"bob@example.org", []string{"Fix parser leak"}},
+		{"Carol", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.author, func(t *testing.T) {
+			commits, err := r.tool().searchInCommitHistory(SearchOptions{Query: "fix", Author: tt.author})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The author filter applies to the whole command line search
+	stdout, _, status := r.gst("-quiet", "-author", "Bob", "-query", "parser")
+	if status != exitMatch || !strings.Contains(stdout, "Fix parser leak") || strings.Contains(stdout, "Fix parser crash") {
+		t.Errorf("-author Bob: status %d, output:\n%s", status, stdout)
+	}
+}
+
+func TestDateFilter(t *testing.T) {
+	r := newTestRepo(t)
+	// Commits are made an hour apart from testEpoch, 2024-01-01 12:00 UTC
+	r.commit("Release prep 1")
+	r.commit("Release prep 2")
+	r.commit("Release prep 3")
+
+	tests := []struct {
+		name         string
+		since, until string
+		want         []string
+	}{
+		{"unrestricted", "", "", []string{"Release prep 3", "Release prep 2", "Release prep 1"}},
+		{"since", "2024-01-01T12:30:00Z", "", []string{"Release prep 3", "Release prep 2"}},
+		{"until", "", "2024-01-01T13:30:00Z", []string{"Release prep 2", "Release prep 1"}},
+		{"window", "2024-01-01T12:30:00Z", "2024-01-01T13:30:00Z", []string{"Release prep 2"}},
+		{"relative", "2 weeks ago", "", nil},
+		{"until before since", "2024-01-02", "2024-01-01", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits, err := r.tool().searchInCommitHistory(SearchOptions{Query: "release", Since: tt.since, Until: tt.until})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// An empty window is searched anyway, with a warning
+	_, stderr, status := r.gst("-quiet", "-since", "2024-01-02", "-until", "2024-01-01", "-query", "release")
+	if status != exitNoMatch || !strings.Contains(stderr, "-until 2024-01-01 is before -since 2024-01-02") {
+		t.Errorf("-until before -since: status %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestCaseSensitivity(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add HTTPServer", "server.go", "type HTTPServer struct{}\n")
+	r.commit("Rename httpserver helpers", "helpers.go", "func httpserverHelper() {}\n")
+
+	tests := []struct {
+		caseSensitive bool
+		wantCommits   []string
+		wantFiles     []string
+	}{
+		{false, []string{"Rename httpserver helpers", "Add HTTPServer"}, []string{"helpers.go", "server.go"}},
+		{true, []string{"Add HTTPServer"}, []string{"server.go"}},
+	}
+	for _, tt := range tests {
+		g := r.tool()
+		opts := SearchOptions{Query: "HTTPServer", CaseSensitive: tt.caseSensitive}
+		commits, err := g.searchInCommitHistory(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := commitSubjects(commits); !slices.Equal(got, tt.wantCommits) {
+			t.Errorf("case sensitive %v: commits = %q, want %q", tt.caseSensitive, got, tt.wantCommits)
+		}
+		files, err := g.searchInFiles(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := matchPaths(files); !slices.Equal(got, tt.wantFiles) {
+			t.Errorf("case sensitive %v: files = %q, want %q", tt.caseSensitive, got, tt.wantFiles)
+		}
+	}
+}
+
+func TestRegexFileSearch(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add handlers",
+		"handlers.go", "func getHandler() {}\nfunc setHandler() {}\nfunc deleteHandler() {}\n",
+		"README", "Routes go to (get|set)Handler\n")
+
+	tests := []struct {
+		query string
+		regex bool
+		want  []string
+	}{
+		{"(get|set)Handler", true, []string{"handlers.go:1", "handlers.go:2"}},
+		{"(get|set)Handler", false, []string{"README:1"}},
+		{"func [a-z]+Handler", true, []string{"handlers.go:1", "handlers.go:2", "handlers.go:3"}},
+		{"func [a-z]+Handler", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s regex=%v", tt.query, tt.regex), func(t *testing.T) {
+			matches, err := r.tool().searchInFiles(SearchOptions{Query: tt.query, Regex: tt.regex, CaseSensitive: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, match := range matches {
+				got = append(got, fmt.Sprintf("%s:%d", match.Path, match.LineNumber))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matches = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllBranches(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Initial commit")
+	r.git("checkout", "-q", "-b", "feature")
+	r.commit("Add widget cache on feature")
+	r.git("checkout", "-q", "main")
+	r.commit("Add widget docs on main")
+
+	tests := []struct {
+		allBranches bool
+		want        []string
+	}{
+		{false, []string{"Add widget docs on main"}},
+		{true, []string{"Add widget docs on main", "Add widget cache on feature"}},
+	}
+	for _, tt := range tests {
+		g := r.tool()
+		g.allBranches = tt.allBranches
+		commits, err := g.searchInCommitHistory(SearchOptions{Query: "widget"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+			t.Errorf("allBranches=%v: subjects = %q, want %q", tt.allBranches, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyRepository(t *testing.T) {
+	r := newTestRepo(t)
+
+	tests := []struct {
+		args       []string
+		wantStatus int
+	}{
+		{[]string{"-query", "x"}, exitNoMatch},
+		{[]string{"-query", "x", "-diff-search", "-depth", "3", "-all-branches"}, exitNoMatch},
+		{[]string{"-query", "x", "-search-tags", "-search-notes", "-search-reflog", "-search-stashes"}, exitNoMatch},
+		{[]string{"-query", "x", "-fuzzy"}, exitNoMatch},
+		{[]string{"-query", "x", "-format", "json"}, exitNoMatch},
+		{[]string{"-first-introduced", "x"}, exitNoMatch},
+		{[]string{"-stats"}, exitMatch},
+		{[]string{"-hotspots", "3"}, exitMatch},
+		{[]string{"-recent-files", "3"}, exitMatch},
+		{[]string{"-size-histogram"}, exitMatch},
+		{[]string{"-search-authors", "-query", "x"}, exitMatch},
+		{[]string{"-group-by-author"}, exitMatch},
+		{[]string{"-coauthors"}, exitMatch},
+		{[]string{"-author-map"}, exitMatch},
+		{[]string{"-commit-template-check"}, exitMatch},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			_, stderr, status := r.gst(tt.args...)
+			if strings.Contains(stderr, "panic") || strings.Contains(stderr, "ERROR") {
+				t.Errorf("stderr:\n%s", stderr)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("exit status %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	if r.tool().hasCommits() {
+		t.Error("hasCommits() = true for a repository without commits")
+	}
+}
+
+func TestAbbreviateHash(t *testing.T) {
+	full := "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		hash, want string
+	}{
+		{"", ""},
+		{"012", "012"},
+		{"01234567", "01234567"},
+		{full, "01234567"},
+	}
+	for _, tt := range tests {
+		if got := abbreviateHash(tt.hash); got != tt.want {
+			t.Errorf("abbreviateHash(%q) = %q, want %q", tt.hash, got, tt.want)
+		}
+	}
+}
+
+func TestPathFilters(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add files",
+		"src/app.go", "token\n",
+		"src/app_test.go", "token\n",
+		"src/util/strings.go", "token\n",
+		"vendor/lib/lib.go", "token\n",
+		"docs/token.md", "token\n")
+
+	tests := []struct {
+		name    string
+		filters []string
+		want    []string
+	}{
+		{"none", nil, []string{"docs/token.md", "src/app.go", "src/app_test.go", "src/util/strings.go", "vendor/lib/lib.go"}},
+		{"directory", []string{"src"}, []string{"src/app.go", "src/app_test.go", "src/util/strings.go"}},
+		{"glob", []string{":(glob)src/*.go"}, []string{"src/app.go", "src/app_test.go"}},
+		{"recursive glob", []string{":(glob)**/*_test.go"}, []string{"src/app_test.go"}},
+		{"exclude", []string{":(exclude)vendor"}, []string{"docs/token.md", "src/app.go", "src/app_test.go", "src/util/strings.go"}},
+		{"include and exclude", []string{"src", ":!src/util"}, []string{"src/app.go", "src/app_test.go"}},
+		{"several", []string{"docs", "vendor"}, []string{"docs/token.md", "vendor/lib/lib.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := r.tool().searchInFiles(SearchOptions{Query: "token", PathFilters: tt.filters})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchPaths(matches); !slices.Equal(got, tt.want) {
+				t.Errorf("paths = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Like in git, * matches across directories unless the glob magic is used
+	stdout, _, status := r.gst("-quiet", "-head-only", "-path-filter", "src/*.go", "-path-filter", ":(exclude)src/app_test.go", "-query", "token")
+	if want := "1. src/app.go:1:token\n2. src/util/strings.go:1:token\n"; status != exitMatch || stdout != want {
+		t.Errorf("-path-filter: status %d, output %q, want %q", status, stdout, want)
+	}
+}
+
+func TestRevisionRange(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Initial commit")
+	r.git("tag", "v1.0")
+	r.commit("Fix overflow in parser")
+	r.git("tag", "v2.0")
+	r.commit("Tidy overflow checks")
+	r.git("tag", "v3.0")
+
+	tests := []struct {
+		revRange string
+		want     []string
+	}{
+		{"", []string{"Tidy overflow checks", "Fix overflow in parser"}},
+		{"v1.0..v2.0", []string{"Fix overflow in parser"}},
+		{"v2.0..v3.0", []string{"Tidy overflow checks"}},
+		{"v2.0", []string{"Fix overflow in parser"}},
+		{"v1.0", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.revRange, func(t *testing.T) {
+			g := r.tool()
+			if tt.revRange != "" {
+				if err := g.validateRevRange(tt.revRange); err != nil {
+					t.Fatal(err)
+				}
+				g.revRange = tt.revRange
+			}
+			commits, err := g.searchInCommitHistory(SearchOptions{Query: "overflow"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"v9.0..v2.0", "--all", "v1.0;rm", "nope"} {
+		if err := r.tool().validateRevRange(bad); err == nil {
+			t.Errorf("validateRevRange(%q) succeeded, want an error", bad)
+		}
+	}
+
+	// The range can also be given positionally
+	stdout, _, status := r.gst("-quiet", "-query", "overflow", "v2.0..v3.0")
+	if status != exitMatch || strings.Contains(stdout, "Fix overflow") || !strings.Contains(stdout, "Tidy overflow checks") {
+		t.Errorf("positional range: status %d, output:\n%s", status, stdout)
+	}
+}
+
+func TestGitBinary(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add config loader", "config.go", "package config\n")
+
+	calls := filepath.Join(t.TempDir(), "calls")
+	stub := fakeGit(t, `echo "$1" >> `+shellQuote([]string{calls})+`
+exec "$realGit" "$@"
+`)
+
+	stdout, stderr, status := r.gst("-quiet", "-git-bin", stub, "-query", "config")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	if !strings.Contains(stdout, "Add config loader") || !strings.Contains(stdout, "config.go:1:package config") {
+		t.Errorf("output is missing the matches:\n%s", stdout)
+	}
+	log, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatalf("the configured git binary wasn't run: %v", err)
+	}
+	for _, want := range []string{"--version", "log", "grep"} {
+		if !slices.Contains(strings.Fields(string(log)), want) {
+			t.Errorf("git %s wasn't run through -git-bin, it ran:\n%s", want, log)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add slow path")
+	slow := fakeGit(t, `if [ "$1" = log ]; then exec sleep 10; fi
+exec "$realGit" "$@"
+`)
+
+	start := time.Now()
+	_, stderr, status := r.gst("-quiet", "-git-bin", slow, "-timeout", "200ms", "-query", "slow")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("search took %s with a 200ms timeout", elapsed)
+	}
+	if status != exitError || !strings.Contains(stderr, "git command timed out after 200ms") {
+		t.Errorf("exit status %d, want %d; stderr:\n%s", status, exitError, stderr)
+	}
+}
+
+func TestBareRepository(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add retry budget", "retry.go", "const retryBudget = 3\n")
+	bare := filepath.Join(testDir(t), "bare.git")
+	r.git("clone", "-q", "--bare", r.dir, bare)
+
+	g := NewGitSearchTool(bare)
+	if !g.isGitRepo() || !g.bare {
+		t.Fatalf("isGitRepo() = false or bare = false for %s", bare)
+	}
+
+	stdout, stderr, status := runGst(t, bare, "-quiet", "-query", "retry")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	for _, want := range []string{"Add retry budget", "1. retry.go:1:const retryBudget = 3"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output is missing %q:\n%s", want, stdout)
+		}
+	}
+
+	// Flags that need a working tree are refused
+	if _, stderr, status := runGst(t, bare, "-untracked", "-query", "retry"); status != exitError || !strings.Contains(stderr, "bare repository") {
+		t.Errorf("-untracked in a bare repository: status %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestExtensionFilter(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add files",
+		"main.go", "// deadline\n",
+		"notes.txt", "deadline\n",
+		"go.mod", "// deadline\n",
+		"cmd/tool/run.go", "deadline\n")
+
+	tests := []struct {
+		exts []string
+		want []string
+	}{
+		{nil, []string{"cmd/tool/run.go", "go.mod", "main.go", "notes.txt"}},
+		{[]string{"go"}, []string{"cmd/tool/run.go", "main.go"}},
+		{[]string{".go", "mod"}, []string{"cmd/tool/run.go", "go.mod", "main.go"}},
+		{[]string{"txt"}, []string{"notes.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.exts, ","), func(t *testing.T) {
+			args := []string{"-head-only", "-files-only", "-quiet"}
+			for _, ext := range tt.exts {
+				args = append(args, "-ext", ext)
+			}
+			stdout, stderr, status := r.gst(append(args, "-query", "deadline")...)
+			if status != exitMatch {
+				t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+			}
+			if got := strings.Fields(stdout); !slices.Equal(got, tt.want) {
+				t.Errorf("files = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryLine(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add quota checks", "a.go", "quota\nquota again\n", "b.go", "quota\n", "c.go", "none\n")
+	r.commit("Raise quota")
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"quota", "Found 2 commit matches and 3 file matches across 2 files."},
+		{"again", "Found 0 commit matches and 1 file matches across 1 files."},
+		{"raise", "Found 1 commit matches and 0 file matches across 0 files."},
+		{"missing", "Found 0 commit matches and 0 file matches across 0 files."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			stdout, _, _ := r.gst("-no-banner", "-query", tt.query)
+			if !strings.Contains(stdout, "\n"+tt.want+"\n") {
+				t.Errorf("output is missing %q:\n%s", tt.want, stdout)
+			}
+		})
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add cache", "cache.go", "cache\n")
+	notRepo := testDir(t)
+	failing := fakeGit(t, `if [ "$1" = log ]; then echo "fatal: bad object" >&2; exit 128; fi
+exec "$realGit" "$@"
+`)
+
+	tests := []struct {
+		name string
+		dir  string
+		args []string
+		want int
+	}{
+		{"commit match", r.dir, []string{"-query", "add"}, exitMatch},
+		{"file match", r.dir, []string{"-query", "cache"}, exitMatch},
+		{"no match", r.dir, []string{"-query", "missing"}, exitNoMatch},
+		{"json no match", r.dir, []string{"-format", "json", "-query", "missing"}, exitNoMatch},
+		{"dry run", r.dir, []string{"-dry-run", "-query", "missing"}, exitMatch},
+		{"not a repository", notRepo, []string{"-query", "cache"}, exitError},
+		{"invalid flags", r.dir, []string{"-files-only", "-count", "-query", "cache"}, exitError},
+		{"unknown revision", r.dir, []string{"-query", "cache", "nope..HEAD"}, exitError},
+		{"git failure", r.dir, []string{"-git-bin", failing, "-query", "cache"}, exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, stderr, status := runGst(t, tt.dir, append([]string{"-quiet"}, tt.args...)...); status != tt.want {
+				t.Errorf("exit status %d, want %d; stderr:\n%s", status, tt.want, stderr)
+			}
+		})
+	}
+}
+
+func TestReverse(t *testing.T) {
+	r := newTestRepo(t)
+	for _, subject := range []string{"Parser: first", "Unrelated", "Parser: second", "Parser: third", "Parser: fourth"} {
+		r.commit(subject)
+	}
+
+	tests := []struct {
+		reverse    bool
+		maxCommits int
+		want       []string
+	}{
+		{false, 10, []string{"Parser: fourth", "Parser: third", "Parser: second", "Parser: first"}},
+		{true, 10, []string{"Parser: first", "Parser: second", "Parser: third", "Parser: fourth"}},
+		// The oldest matches of the whole history, not the newest reversed
+		{true, 2, []string{"Parser: first", "Parser: second"}},
+		{false, 2, []string{"Parser: fourth", "Parser: third"}},
+	}
+	for _, tt := range tests {
+		g := r.tool()
+		g.reverse, g.maxCommits = tt.reverse, tt.maxCommits
+		commits, err := g.searchInCommitHistory(SearchOptions{Query: "parser"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+			t.Errorf("reverse=%v max %d: subjects = %q, want %q", tt.reverse, tt.maxCommits, got, tt.want)
+		}
+	}
+}
+
+func TestConcurrentSearches(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add limiter", "limiter.go", "limiter\n")
+
+	// git log and git grep each wait for the other to start, which they
+	// only do when they run at the same time; one running alone gives up
+	// after 10 seconds and says so
+	dir := t.TempDir()
+	calls, alone := filepath.Join(dir, "calls"), filepath.Join(dir, "alone")
+	slow := fakeGit(t, `case "$1" in log|grep)
+	echo "$1" >> `+shellQuote([]string{calls})+`
+	touch `+shellQuote([]string{dir})+`/"$1".started
+	other=grep
+	[ "$1" = grep ] && other=log
+	i=0
+	while [ ! -e `+shellQuote([]string{dir})+`/"$other".started ]; do
+		i=$((i + 1))
+		if [ $i -gt 100 ]; then
+			echo "$1" >> `+shellQuote([]string{alone})+`
+			break
+		fi
+		sleep 0.1
+	done;;
+esac
+exec "$realGit" "$@"
+`)
+
+	stdout, stderr, status := r.gst("-no-banner", "-git-bin", slow, "-query", "limiter")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	if waited, err := os.ReadFile(alone); err == nil {
+		t.Errorf("git %s ran alone, the commit and file searches didn't overlap", strings.Fields(string(waited)))
+	}
+
+	log, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(log)); len(got) != 2 || !slices.Contains(got, "log") || !slices.Contains(got, "grep") {
+		t.Errorf("git was run for %q, want one log and one grep", got)
+	}
+
+	// The results are printed in the usual order whichever finished first
+	commits := strings.Index(stdout, "1. ")
+	files := strings.Index(stdout, "--- File Contents ---")
+	if commits < 0 || files < 0 || commits > files || !strings.Contains(stdout[files:], "1. limiter.go:1:limiter") {
+		t.Errorf("commit results don't come before the file results:\n%s", stdout)
+	}
+}
+
+func TestIncludeBinary(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add assets",
+		"notes.txt", "token in text\n",
+		"image.bin", "\x89PNG\x00\x01token\x00\n",
+		"app.min.js", "var token=1;\n",
+		".gitattributes", "*.min.js -diff\n")
+
+	tests := []struct {
+		includeBinary bool
+		want          []string
+	}{
+		{false, []string{"notes.txt"}},
+		{true, []string{"app.min.js", "image.bin", "notes.txt"}},
+	}
+	for _, tt := range tests {
+		g := r.tool()
+		g.includeBinary = tt.includeBinary
+		g.fileSort = "path"
+		matches, err := g.searchInFiles(SearchOptions{Query: "token"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := matchPaths(matches); !slices.Equal(got, tt.want) {
+			t.Errorf("includeBinary=%v: paths = %q, want %q", tt.includeBinary, got, tt.want)
+		}
+	}
+
+	// Binary matches are shown as a hex preview
+	stdout, _, _ := r.gst("-quiet", "-include-binary", "-query", "token", "--", "image.bin")
+	if !strings.Contains(stdout, "1. image.bin:1:[binary] ") {
+		t.Errorf("binary match isn't previewed:\n%s", stdout)
+	}
+}
+
+func TestWholeWord(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add layout", "layout.css", "width: 10px;\nuser_id: 1\nid: 2\nvalid(id)\n")
+
+	tests := []struct {
+		wholeWord bool
+		want      []int
+	}{
+		{false, []int{1, 2, 3, 4}},
+		{true, []int{3, 4}},
+	}
+	for _, tt := range tests {
+		matches, err := r.tool().searchInFiles(SearchOptions{Query: "id", WholeWord: tt.wholeWord})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, match := range matches {
+			got = append(got, match.LineNumber)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("wholeWord=%v: lines = %v, want %v", tt.wholeWord, got, tt.want)
+		}
+	}
+}
+
+func TestNestedSubdirectory(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add handlers", "internal/api/handler.go", "retryPolicy\n", "README", "retryPolicy\n")
+	nested := filepath.Join(r.dir, "internal", "api")
+
+	g := NewGitSearchTool(nested)
+	if !g.findWorkTreeRoot() || g.repoPath != r.dir {
+		t.Errorf("findWorkTreeRoot() moved to %s, want %s", g.repoPath, r.dir)
+	}
+	if g := r.tool(); g.findWorkTreeRoot() {
+		t.Errorf("findWorkTreeRoot() moved the root itself to %s", g.repoPath)
+	}
+
+	// Run from the subdirectory, the whole repository is searched
+	stdout, stderr, status := runGst(t, nested, "-no-banner", "-query", "retryPolicy")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	for _, want := range []string{"Found repository root above " + nested, "Git repository: " + r.dir, "README:1:retryPolicy", "internal/api/handler.go:1:retryPolicy"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output is missing %q:\n%s", want, stdout)
+		}
+	}
+
+	// Outside of any repository it still fails
+	if _, _, status := runGst(t, testDir(t), "-query", "retryPolicy"); status != exitError {
+		t.Errorf("outside a repository: exit status %d, want %d", status, exitError)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add scheduler", "sched.go", "scheduler\n")
+
+	stdout, stderr, status := r.gst("-quiet", "-query", "scheduler", "-diff-search", "-search-tags")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	for _, banner := range []string{
+		"Git repository", "Last Commit", "===", "---", "Found ", "No matches", "Goodbye",
+	} {
+		if strings.Contains(stdout, banner) || strings.Contains(stderr, banner) {
+			t.Errorf("quiet output has %q:\nstdout:\n%s\nstderr:\n%s", banner, stdout, stderr)
+		}
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "Add scheduler") || lines[1] != "1. sched.go:1:scheduler" {
+		t.Errorf("quiet output = %q, want the commit and file match lines", lines)
+	}
+}
+
+func TestFilesOnly(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add files",
+		"a.go", "lock\nunlock\nlock again\n",
+		"b.go", "no match\n",
+		"c.go", "lock\n",
+		"d/e.go", "deadlock\nlock\n")
+
+	full, _, _ := r.gst("-quiet", "-head-only", "-query", "lock")
+	filesOnly, stderr, status := r.gst("-quiet", "-head-only", "-files-only", "-query", "lock")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+
+	// Each file of the full output is listed once, in the same order
+	var want []string
+	for _, line := range strings.Split(strings.TrimSpace(full), "\n") {
+		_, rest, _ := strings.Cut(line, ". ")
+		path, _, _ := strings.Cut(rest, ":")
+		if !slices.Contains(want, path) {
+			want = append(want, path)
+		}
+	}
+	if got := strings.Split(strings.TrimSpace(filesOnly), "\n"); !slices.Equal(got, want) {
+		t.Errorf("-files-only = %q, want %q from the full output:\n%s", got, want, full)
+	}
+	if files := []string{"a.go", "c.go", "d/e.go"}; !slices.Equal(want, files) {
+		t.Errorf("full output lists files %q, want %q", want, files)
+	}
+	if lines := strings.Count(full, "\n"); lines <= len(want) {
+		t.Errorf("full output has %d lines, want more than one per file:\n%s", lines, full)
+	}
+}
+
+func TestInvert(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("ABC-1 Add login", "auth.go", "login\nTODO: logout\n")
+	r.commit("Fix typo")
+	r.commit("ABC-2 Add logout", "auth.go", "login\nlogout\n")
+	r.commit("Update docs")
+
+	g := r.tool()
+	g.invert = true
+	commits, err := g.searchInCommitHistory(SearchOptions{Query: "ABC-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := commitSubjects(commits), []string{"Update docs", "Fix typo"}; !slices.Equal(got, want) {
+		t.Errorf("commits NOT matching = %q, want %q", got, want)
+	}
+
+	files, err := g.searchInFiles(SearchOptions{Query: "login"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != "auth.go" || files[0].Content != "logout" {
+		t.Errorf("file lines NOT matching = %+v, want auth.go:2:logout", files)
+	}
+
+	stdout, _, status := r.gst("-invert", "-query", "ABC-")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d", status, exitMatch)
+	}
+	for _, header := range []string{"=== Search Results NOT matching: ", "--- Commits NOT Matching ---", "--- File Lines NOT Matching ---"} {
+		if !strings.Contains(stdout, header) {
+			t.Errorf("output is missing %q:\n%s", header, stdout)
+		}
+	}
+}
+
+func TestMergeFilters(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser")
+	r.git("checkout", "-q", "-b", "feature")
+	r.commit("Add parser cache")
+	r.git("checkout", "-q", "main")
+	r.commit("Document parser")
+	date := "2024-02-01T12:00:00Z"
+	r.gitEnv([]string{"GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=" + date},
+		"merge", "-q", "--no-ff", "-m", "Merge parser cache", "feature")
+
+	tests := []struct {
+		name       string
+		noMerges   bool
+		mergesOnly bool
+		want       []string
+	}{
+		{"default", false, false, []string{"Merge parser cache", "Document parser", "Add parser cache", "Add parser"}},
+		{"no merges", true, false, []string{"Document parser", "Add parser cache", "Add parser"}},
+		{"merges only", false, true, []string{"Merge parser cache"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := r.tool()
+			g.noMerges, g.mergesOnly = tt.noMerges, tt.mergesOnly
+			commits, err := g.searchInCommitHistory(SearchOptions{Query: "parser"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, stderr, status := r.gst("-no-merges", "-merges-only", "-query", "parser"); status != exitError || !strings.Contains(stderr, "mutually exclusive") {
+		t.Errorf("-no-merges with -merges-only: exit status %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestPathArguments(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add token handling",
+		"internal/auth/token.go", "token\n",
+		"internal/auth/session.go", "token\n",
+		"internal/api/handler.go", "token\n",
+		"main.go", "token\n")
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"directory", []string{"internal/auth/"}, "1. internal/auth/session.go:1:token\n2. internal/auth/token.go:1:token\n"},
+		{"file", []string{"main.go"}, "1. main.go:1:token\n"},
+		{"file and directory", []string{"main.go", "internal/api"}, "1. internal/api/handler.go:1:token\n2. main.go:1:token\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, status := r.gst(append([]string{"-quiet", "-head-only", "-query", "token"}, tt.paths...)...)
+			if status != exitMatch || stdout != tt.want {
+				t.Errorf("exit status %d, output %q, want %q; stderr:\n%s", status, stdout, tt.want, stderr)
+			}
+		})
+	}
+
+	// A path that doesn't exist is warned about, and searched anyway
+	stdout, stderr, status := r.gst("-quiet", "-head-only", "-query", "token", "--", "internal/missing", "main.go")
+	if status != exitMatch || stdout != "1. main.go:1:token\n" {
+		t.Errorf("with a missing path: exit status %d, output %q", status, stdout)
+	}
+	if !strings.Contains(stderr, "internal/missing does not exist in "+r.dir) {
+		t.Errorf("stderr is missing the warning about the missing path:\n%s", stderr)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add lock", "src/lock.go", "lock\n")
+
+	stdout, stderr, status := r.gst("-quiet", "-dry-run", "-author", "Alice Smith", "-since", "2024-01-01", "-until", "2024-02-01",
+		"-path-filter", "src/*.go", "-query", "lock")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing as no search ran", stdout)
+	}
+	want := []string{
+		"git log --encoding=UTF-8 --pretty=format:%H%x1f%an%x1f%ae%x1f%ad%x1f%s%x1f%b%x1e --date=short --grep=lock -i -10 '--author=Alice Smith' --since=2024-01-01 --until=2024-02-01",
+		"git grep -n -z -i -e lock -- 'src/*.go'",
+	}
+	if got := strings.Split(strings.TrimSpace(stderr), "\n"); !slices.Equal(got, want) {
+		t.Errorf("printed commands:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestUnusualFileNames(t *testing.T) {
+	r := newTestRepo(t)
+	names := []string{
+		"docs/release notes.md",
+		"quote's & more.txt",
+		"ünïcødé ✓.txt",
+	}
+	// Windows doesn't allow these characters in file names
+	if runtime.GOOS != "windows" {
+		names = append(names, "odd:name:12:x.txt", "\"double\".txt", "tab\there.txt", "new\nline.txt")
+	}
+	var files []string
+	for _, name := range names {
+		files = append(files, name, "first\nneedle: a:1:b\n")
+	}
+	r.commit("Add oddly named files", files...)
+
+	matches, err := r.tool().searchInFiles(SearchOptions{Query: "needle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]FileMatch)
+	for _, match := range matches {
+		got[match.Path] = match
+	}
+	for _, name := range names {
+		match, ok := got[name]
+		if !ok {
+			t.Errorf("no match in %q, got %+v", name, matches)
+			continue
+		}
+		if match.LineNumber != 2 || match.Content != "needle: a:1:b" {
+			t.Errorf("match in %q = line %d %q, want line 2 %q", name, match.LineNumber, match.Content, "needle: a:1:b")
+		}
+	}
+	if len(matches) != len(names) {
+		t.Errorf("got %d matches, want %d: %+v", len(matches), len(names), matches)
+	}
+}
+
+func TestDepth(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Fix cache eviction")
+	r.commit("Add cache metrics")
+	r.commit("Update readme")
+	r.commit("Bump version")
+
+	tests := []struct {
+		depth int
+		want  []string
+	}{
+		{0, []string{"Add cache metrics", "Fix cache eviction"}},
+		{2, nil},
+		{3, []string{"Add cache metrics"}},
+		{4, []string{"Add cache metrics", "Fix cache eviction"}},
+		{10, []string{"Add cache metrics", "Fix cache eviction"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.depth), func(t *testing.T) {
+			g := r.tool()
+			g.depth = tt.depth
+			commits, err := g.searchInCommitHistory(SearchOptions{Query: "cache"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorRegex(t *testing.T) {
+	r := newTestRepo(t)
+	r.commitEnv(author("Alice Smith", "alice@example.com"), "Fix parser crash")
+	r.commitEnv(author("Bob Jones", "bob@example.org"), "Fix parser leak")
+	r.commitEnv(author("Carol White", "carol@corp.example"), "Fix parser typo")
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{`alice\|bob`, []string{"Fix parser leak", "Fix parser crash"}},
+		{`ALICE\|CAROL`, []string{"Fix parser typo", "Fix parser crash"}},
+		{`@corp\.example>`, []string{"Fix parser typo"}},
+		{`^Bob J`, []string{"Fix parser leak"}},
+		{`dave\|erin`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			commits, err := r.tool().searchInCommitHistory(SearchOptions{Query: "parser", AuthorRegex: tt.pattern})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	stdout, _, status := r.gst("-quiet", "-author-regex", `alice\|bob`, "-query", "parser")
+	if status != exitMatch || !strings.Contains(stdout, "Alice Smith") || !strings.Contains(stdout, "Bob Jones") || strings.Contains(stdout, "Carol") {
+		t.Errorf(`-author-regex alice\|bob: status %d, output:`+"\n%s", status, stdout)
+	}
+}
+
+func TestCommitterFilter(t *testing.T) {
+	r := newTestRepo(t)
+	// Alice wrote the fix in 2023 and Bob applied it in 2024, as with a
+	// cherry-pick
+	r.gitEnv([]string{
+		"GIT_AUTHOR_NAME=Alice Smith", "GIT_AUTHOR_EMAIL=alice@example.com", "GIT_AUTHOR_DATE=2023-05-01T12:00:00Z",
+		"GIT_COMMITTER_NAME=Bob Jones", "GIT_COMMITTER_EMAIL=bob@example.org", "GIT_COMMITTER_DATE=2024-06-01T12:00:00Z",
+	}, "commit", "-q", "--allow-empty", "-m", "Fix session timeout")
+
+	tests := []struct {
+		name          string
+		opts          SearchOptions
+		committerDate bool
+		wantAuthor    string
+		wantDate      string
+		wantCommits   int
+	}{
+		{"author date", SearchOptions{}, false, "Alice Smith", "2023-05-01", 1},
+		{"committer date", SearchOptions{}, true, "Alice Smith", "2024-06-01", 1},
+		{"committer name", SearchOptions{Committer: "Bob"}, false, "Alice Smith", "2023-05-01", 1},
+		{"committer email", SearchOptions{Committer: "bob@example.org"}, false, "Alice Smith", "2023-05-01", 1},
+		{"author isn't the committer", SearchOptions{Committer: "Alice"}, false, "", "", 0},
+		{"author matches -author", SearchOptions{Author: "Alice"}, false, "Alice Smith", "2023-05-01", 1},
+		{"committer doesn't match -author", SearchOptions{Author: "Bob"}, false, "", "", 0},
+		// git log's -since and -until always compare committer dates
+		{"since the commit", SearchOptions{Since: "2024-01-01"}, false, "Alice Smith", "2023-05-01", 1},
+		{"until the authoring", SearchOptions{Until: "2024-01-01"}, false, "", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := r.tool()
+			g.committerDates = tt.committerDate
+			tt.opts.Query = "session"
+			commits, err := g.searchInCommitHistory(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(commits) != tt.wantCommits {
+				t.Fatalf("got %d commits, want %d: %+v", len(commits), tt.wantCommits, commits)
+			}
+			if len(commits) > 0 && (commits[0].Author != tt.wantAuthor || commits[0].Date != tt.wantDate) {
+				t.Errorf("commit by %q on %q, want %q on %q", commits[0].Author, commits[0].Date, tt.wantAuthor, tt.wantDate)
+			}
+		})
+	}
+
+	stdout, _, status := r.gst("-quiet", "-committer", "Bob", "-date-field", "committer", "-query", "session")
+	if status != exitMatch || !strings.Contains(stdout, "Fix session timeout - Alice Smith (2024-06-01)") {
+		t.Errorf("-committer Bob -date-field committer: status %d, output:\n%s", status, stdout)
+	}
+}
+
+func TestCommitDetailsWithPipes(t *testing.T) {
+	r := newTestRepo(t)
+	hash := r.commitEnv(author("Ops | Release Bot", "bot@example.com"),
+		"Fix a|b parsing | handle || too\n\nBody with | pipes\nand a second line")
+
+	details, err := r.tool().getCommitDetails("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"hash":    hash,
+		"author":  "Ops | Release Bot",
+		"email":   "bot@example.com",
+		"date":    "2024-01-01",
+		"subject": "Fix a|b parsing | handle || too",
+		"body":    "Body with | pipes\nand a second line",
+	}
+	for field, value := range want {
+		if details[field] != value {
+			t.Errorf("%s = %q, want %q", field, details[field], value)
+		}
+	}
+
+	commits, err := r.tool().searchInCommitHistory(SearchOptions{Query: "parsing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 || commits[0].Subject != want["subject"] || commits[0].Author != want["author"] {
+		t.Errorf("searchInCommitHistory() = %+v", commits)
+	}
+}
+
+func TestFirstParent(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser")
+	r.git("checkout", "-q", "-b", "feature")
+	r.commit("Add parser cache")
+	r.commit("Tune parser cache")
+	r.git("checkout", "-q", "main")
+	r.commit("Document parser")
+	date := "2024-02-01T12:00:00Z"
+	r.gitEnv([]string{"GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=" + date},
+		"merge", "-q", "--no-ff", "-m", "Merge parser cache", "feature")
+
+	tests := []struct {
+		name        string
+		firstParent bool
+		noMerges    bool
+		want        []string
+	}{
+		{"all parents", false, false, []string{"Merge parser cache", "Document parser", "Tune parser cache", "Add parser cache", "Add parser"}},
+		{"first parent", true, false, []string{"Merge parser cache", "Document parser", "Add parser"}},
+		{"first parent without merges", true, true, []string{"Document parser", "Add parser"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := r.tool()
+			g.firstParent, g.noMerges = tt.firstParent, tt.noMerges
+			commits, err := g.searchInCommitHistory(SearchOptions{Query: "parser"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilesChangedIn(t *testing.T) {
+	r := newTestRepo(t)
+	root := r.commit("Add files", "a.go", "token\n", "b.go", "token\n", "docs/c.md", "token\n")
+	fix := r.commit("Fix token handling", "a.go", "token fixed\n", "docs/c.md", "token fixed\n", "d.go", "token\n")
+	r.commit("Unrelated", "b.go", "token again\n")
+
+	tests := []struct {
+		name      string
+		commit    string
+		pathspecs []string
+		want      []string
+	}{
+		{"several files", fix, nil, []string{"a.go", "d.go", "docs/c.md"}},
+		{"root commit", root, nil, []string{"a.go", "b.go", "docs/c.md"}},
+		{"within pathspecs", fix, []string{"docs"}, []string{"docs/c.md"}},
+		{"by revision", "HEAD~1", nil, []string{"a.go", "d.go", "docs/c.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := r.tool().filesChangedIn(tt.commit, tt.pathspecs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(files, tt.want) {
+				t.Errorf("filesChangedIn(%s) = %q, want %q", tt.commit, files, tt.want)
+			}
+		})
+	}
+
+	// Only the changed files are searched, as they are now
+	stdout, stderr, status := r.gst("-quiet", "-head-only", "-commit", fix[:8], "-query", "token")
+	if want := "1. a.go:1:token fixed\n2. d.go:1:token\n3. docs/c.md:1:token fixed\n"; status != exitMatch || stdout != want {
+		t.Errorf("-commit: exit status %d, output %q, want %q; stderr:\n%s", status, stdout, want, stderr)
+	}
+	if _, stderr, status := r.gst("-quiet", "-commit", "nope", "-query", "token"); status != exitError || !strings.Contains(stderr, "Error listing the files changed in nope") {
+		t.Errorf("unknown commit: exit status %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestUntracked(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add tracked file", "tracked.go", "feature flag\n", ".gitignore", "*.log\n")
+	r.write("draft.go", "feature flag draft\n")
+	r.write("debug.log", "feature flag log\n")
+
+	tests := []struct {
+		name      string
+		untracked bool
+		want      []string
+	}{
+		{"tracked only", false, []string{"tracked.go"}},
+		// Ignored files stay out, like with git grep --untracked
+		{"untracked", true, []string{"draft.go", "tracked.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := r.tool()
+			g.untracked = tt.untracked
+			matches, err := g.searchInFiles(SearchOptions{Query: "feature"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchPaths(matches); !slices.Equal(got, tt.want) {
+				t.Errorf("paths = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// -no-index searches a directory that isn't a repository at all
+	dir := testDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("feature flag notes\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, status := runGst(t, dir, "-quiet", "-no-index", "-query", "feature")
+	if status != exitMatch || stdout != "1. notes.txt:1:feature flag notes\n" {
+		t.Errorf("-no-index: exit status %d, output %q; stderr:\n%s", status, stdout, stderr)
+	}
+}
+
+func TestLineRange(t *testing.T) {
+	r := newTestRepo(t)
+	var lines strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&lines, "match %d\n", i)
+	}
+	r.commit("Add lines", "a.txt", lines.String(), "b.txt", "match 1\nmatch 2\nmatch 3\n")
+
+	tests := []struct {
+		name             string
+		lineMin, lineMax int
+		want             []string
+	}{
+		{"no window", 0, 0, []string{"a.txt:1", "a.txt:2", "a.txt:3", "a.txt:4", "a.txt:5", "a.txt:6", "a.txt:7", "a.txt:8", "a.txt:9", "a.txt:10", "b.txt:1", "b.txt:2", "b.txt:3"}},
+		{"window", 3, 5, []string{"a.txt:3", "a.txt:4", "a.txt:5", "b.txt:3"}},
+		{"single line", 2, 2, []string{"a.txt:2", "b.txt:2"}},
+		{"minimum only", 9, 0, []string{"a.txt:9", "a.txt:10"}},
+		{"maximum only", 0, 1, []string{"a.txt:1", "b.txt:1"}},
+		{"past the end", 11, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := r.tool()
+			g.lineMin, g.lineMax = tt.lineMin, tt.lineMax
+			matches, err := g.searchInFiles(SearchOptions{Query: "match", MaxResults: 100})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, match := range matches {
+				got = append(got, fmt.Sprintf("%s:%d", match.Path, match.LineNumber))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matches = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, stderr, status := r.gst("-line-min", "5", "-line-max", "4", "-query", "match"); status != exitError || !strings.Contains(stderr, "-line-min 5 is after -line-max 4") {
+		t.Errorf("-line-min after -line-max: exit status %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestStagedAndModified(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add files", "staged.go", "token\n", "modified.go", "token\n", "clean.go", "token\n")
+	r.write("staged.go", "token staged\n")
+	r.git("add", "staged.go")
+	r.write("modified.go", "token modified\n")
+
+	tests := []struct {
+		flag string
+		want string
+	}{
+		{"-staged", "1. staged.go:1:token staged\n"},
+		{"-modified", "1. modified.go:1:token modified\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			stdout, stderr, status := r.gst("-quiet", "-head-only", tt.flag, "-query", "token")
+			if status != exitMatch || stdout != tt.want {
+				t.Errorf("exit status %d, output %q, want %q; stderr:\n%s", status, stdout, tt.want, stderr)
+			}
+		})
+	}
+
+	// Without changes nothing is grepped
+	r.commit("Commit changes")
+	for _, flag := range []string{"-staged", "-modified"} {
+		stdout, stderr, status := r.gst("-no-banner", "-debug-json", flag, "-query", "token")
+		if status != exitNoMatch || !strings.Contains(stdout, "No files with ") || ranGit(debugCommands(t, stderr), "grep") {
+			t.Errorf("%s without changes: exit status %d, output:\n%s\nstderr:\n%s", flag, status, stdout, stderr)
+		}
+	}
+}
+
+func TestResolveCommit(t *testing.T) {
+	r := newTestRepo(t)
+	hash := r.commit("Add parser", "parser.go", "parser\n")
+
+	// Two commits sharing the first 4 hex digits, found by hashing commit
+	// objects of the empty tree until their prefixes collide
+	hashObject := func(kind, content string) string {
+		cmd := exec.Command("git", "hash-object", "-t", kind, "-w", "--stdin")
+		cmd.Dir, cmd.Stdin = r.dir, strings.NewReader(content)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git hash-object: %v", err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	tree := hashObject("tree", "")
+	seen := make(map[string]string)
+	var prefix string
+	for n := 0; prefix == ""; n++ {
+		content := fmt.Sprintf("tree %s\nauthor Test User <test@example.com> 1704110400 +0000\ncommitter Test User <test@example.com> 1704110400 +0000\n\nCommit %d\n", tree, n)
+		sum := sha1.Sum([]byte(fmt.Sprintf("commit %d\x00%s", len(content), content)))
+		id := hex.EncodeToString(sum[:])
+		if other, ok := seen[id[:4]]; ok {
+			hashObject("commit", other)
+			hashObject("commit", content)
+			prefix = id[:4]
+		}
+		seen[id[:4]] = content
+	}
+
+	tests := []struct {
+		rev     string
+		want    string
+		wantErr string
+	}{
+		{hash[:7], hash, ""},
+		{hash, hash, ""},
+		{"HEAD", hash, ""},
+		{prefix, "", prefix + " is ambiguous"},
+		{"0000000", "", "no commit 0000000 in this repository"},
+		{"--all", "", "invalid revision"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rev, func(t *testing.T) {
+			got, err := r.tool().resolveCommit(tt.rev)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolveCommit(%q) = %q, %v, want error %q", tt.rev, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("resolveCommit(%q) = %q, %v, want %q", tt.rev, got, err, tt.want)
+			}
+		})
+	}
+
+	stdout, stderr, status := r.gst("-no-banner", "-show", hash[:7])
+	if status != exitMatch || !strings.Contains(stdout, "Hash:    "+hash) || !strings.Contains(stdout, "Add parser") {
+		t.Errorf("-show: exit status %d, output:\n%s\nstderr:\n%s", status, stdout, stderr)
+	}
+}
+
+func TestShallowClone(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser", "parser.go", "parser\n")
+	r.commit("Fix parser", "parser.go", "parser fixed\n")
+
+	tests := []struct {
+		name        string
+		cloneArgs   []string
+		wantShallow bool
+		wantCommits string
+	}{
+		{"full clone", nil, false, "Found 2 commit matches"},
+		{"shallow clone", []string{"--depth", "1"}, true, "Found 1 commit matches"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clone := &testRepo{t: t, dir: testDir(t)}
+			// --depth is ignored for local paths, unlike file:// URLs
+			clone.git(append(append([]string{"clone", "-q"}, tt.cloneArgs...), "file://"+filepath.ToSlash(r.dir), clone.dir)...)
+			if got := clone.tool().isShallowRepository(); got != tt.wantShallow {
+				t.Errorf("isShallowRepository() = %v, want %v", got, tt.wantShallow)
+			}
+
+			stdout, stderr, status := clone.gst("-no-banner", "-query", "parser")
+			if status != exitMatch || !strings.Contains(stdout, tt.wantCommits) {
+				t.Errorf("exit status %d, output:\n%s", status, stdout)
+			}
+			if warned := strings.Contains(stderr, "this is a shallow clone") && strings.Contains(stderr, "git fetch --unshallow"); warned != tt.wantShallow {
+				t.Errorf("shallow clone warning shown %v, want %v; stderr:\n%s", warned, tt.wantShallow, stderr)
+			}
+		})
+	}
+}
+
+func TestFollowRenames(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add config loader", "loader.go", "package config\n\nfunc load() {}\n", "other.go", "package other\n")
+	r.commit("Fix config defaults", "loader.go", "package config\n\nfunc load() { defaults() }\n")
+	r.git("mv", "loader.go", "settings.go")
+	r.commit("Move config loader")
+	r.commit("Tidy config parsing", "settings.go", "package config\n\nfunc load() { defaults(); parse() }\n")
+	r.commit("Document config", "other.go", "package other // config\n")
+
+	tests := []struct {
+		follow string
+		want   []string
+	}{
+		{"", []string{"Document config", "Tidy config parsing", "Move config loader", "Fix config defaults", "Add config loader"}},
+		// The commits from before the rename are found under the old name
+		{"settings.go", []string{"Tidy config parsing", "Move config loader", "Fix config defaults", "Add config loader"}},
+		{"other.go", []string{"Document config", "Add config loader"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.follow, func(t *testing.T) {
+			g := r.tool()
+			g.follow = tt.follow
+			commits, err := g.searchInCommitHistory(SearchOptions{Query: "config"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, stderr, status := r.gst("-follow", "settings.go", "-follow", "other.go", "-query", "config")
+	if status != exitError || !strings.Contains(stderr, "-follow takes a single file, got 2: settings.go, other.go") {
+		t.Errorf("two -follow files: exit status %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestDateFormat(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser")
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"short", "(2024-01-01)"},
+		{"iso", "(2024-01-01 12:00:00 +0000)"},
+		{"unix", "(1704110400)"},
+		{"relative", " ago)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			stdout, stderr, status := r.gst("-debug-json", "-date-format", tt.format, "-query", "parser")
+			if status != exitMatch {
+				t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+			}
+			// Both the last commit and the search read dates with the format
+			logs := 0
+			for _, command := range debugCommands(t, stderr) {
+				if len(command) > 1 && command[1] == "log" && slices.Contains(command, "--pretty=format:%H%x1f%an%x1f%ae%x1f%ad%x1f%s%x1f%b") {
+					logs++
+				}
+				if len(command) > 1 && command[1] == "log" && !slices.Contains(command, "--date="+tt.format) {
+					t.Errorf("git log without --date=%s: %q", tt.format, command)
+				}
+			}
+			if logs == 0 {
+				t.Errorf("the last commit details weren't read:\n%s", stderr)
+			}
+			_, line, _ := strings.Cut(stdout, "] Add parser - Test User ")
+			if line, _, _ = strings.Cut(line, "\n"); !strings.HasSuffix(line, tt.want) {
+				t.Errorf("commit date %q, want it to end in %q:\n%s", line, tt.want, stdout)
+			}
+		})
+	}
+
+	if _, stderr, status := r.gst("-date-format", "rfc", "-query", "parser"); status != exitError || !strings.Contains(stderr, `unknown -date-format "rfc"`) {
+		t.Errorf("unknown -date-format: exit status %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestOptionLikeQueries(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add usage", "usage.txt", "usage: tool [options]\n")
+	r.commit("Document --help", "usage.txt", "usage: tool [options]\n  --help  show help\n")
+	r.commit("Document -n and -- foo", "usage.txt", "usage: tool [options]\n  --help  show help\n  -n N  count\n  -- foo  stop options\n")
+
+	tests := []struct {
+		query       string
+		wantCommits []string
+		wantChanges []string
+		wantLines   []string
+	}{
+		{"--help", []string{"Document --help"}, []string{"Document --help"}, []string{"  --help  show help"}},
+		{"-n", []string{"Document -n and -- foo"}, []string{"Document -n and -- foo"}, []string{"  -n N  count"}},
+		{"-- foo", []string{"Document -n and -- foo"}, []string{"Document -n and -- foo"}, []string{"  -- foo  stop options"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			g := r.tool()
+			opts := SearchOptions{Query: tt.query}
+			commits, err := g.searchInCommitHistory(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(commits); !slices.Equal(got, tt.wantCommits) {
+				t.Errorf("commits = %q, want %q", got, tt.wantCommits)
+			}
+			changes, err := g.searchInDiffs(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(changes); !slices.Equal(got, tt.wantChanges) {
+				t.Errorf("changes = %q, want %q", got, tt.wantChanges)
+			}
+			files, err := g.searchInFiles(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var lines []string
+			for _, match := range files {
+				lines = append(lines, match.Content)
+			}
+			if !slices.Equal(lines, tt.wantLines) {
+				t.Errorf("file lines = %q, want %q", lines, tt.wantLines)
+			}
+
+			// On the command line the query is the value of -query too
+			stdout, stderr, status := r.gst("-quiet", "-query", tt.query)
+			if status != exitMatch || !strings.Contains(stdout, tt.wantCommits[0]) || !strings.Contains(stdout, tt.wantLines[0]) {
+				t.Errorf("-query %s: exit status %d, output:\n%s\nstderr:\n%s", tt.query, status, stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestSearchAtTree(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add legacy handler", "legacy.go", "func legacyHandler() {}\n", "cmd/main.go", "legacyHandler()\n")
+	r.git("tag", "v1.0")
+	old := r.head()
+	r.git("rm", "-q", "legacy.go", "cmd/main.go")
+	r.commit("Remove legacy handler", "new.go", "func handler() {}\n")
+
+	tests := []struct {
+		at   string
+		want []FileMatch
+	}{
+		{"", nil},
+		{"v1.0", []FileMatch{
+			{Path: "cmd/main.go", LineNumber: 1, Content: "legacyHandler()", Tree: "v1.0"},
+			{Path: "legacy.go", LineNumber: 1, Content: "func legacyHandler() {}", Tree: "v1.0"},
+		}},
+		{old[:8], []FileMatch{
+			{Path: "cmd/main.go", LineNumber: 1, Content: "legacyHandler()", Tree: old[:8]},
+			{Path: "legacy.go", LineNumber: 1, Content: "func legacyHandler() {}", Tree: old[:8]},
+		}},
+		{"HEAD~1", []FileMatch{
+			{Path: "cmd/main.go", LineNumber: 1, Content: "legacyHandler()", Tree: "HEAD~1"},
+			{Path: "legacy.go", LineNumber: 1, Content: "func legacyHandler() {}", Tree: "HEAD~1"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.at, func(t *testing.T) {
+			g := r.tool()
+			g.at = tt.at
+			matches, err := g.searchInFiles(SearchOptions{Query: "legacyHandler"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(matches) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(matches, tt.want) {
+				t.Errorf("matches = %+v, want %+v", matches, tt.want)
+			}
+		})
+	}
+
+	if _, stderr, status := r.gst("-at", "v9.9", "-query", "legacyHandler"); status != exitError || !strings.Contains(stderr, "v9.9 is not a commit or tree in this repository") {
+		t.Errorf("unknown -at: exit status %d, stderr:\n%s", status, stderr)
+	}
+}
+
+func TestGitNotInstalled(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser", "parser.go", "parser\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"not on PATH", nil, "git executable not found on PATH; install git or set -git-bin to its path"},
+		{"missing -git-bin", []string{"-git-bin", filepath.Join(r.dir, "no-such-git")}, "not found or not executable"},
+	}
+	// PATH has no git, only the test binary runs by its full path
+	t.Setenv("PATH", t.TempDir())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, status := r.gst(append(tt.args, "-query", "parser")...)
+			if status != exitNoGit || !strings.Contains(stderr, tt.want) {
+				t.Errorf("exit status %d, want %d; stderr:\n%s", status, exitNoGit, stderr)
+			}
+			if stdout != "" {
+				t.Errorf("stdout = %q, want nothing", stdout)
+			}
+		})
+	}
+}
+
+func TestSortFileMatches(t *testing.T) {
+	r := newTestRepo(t)
+	// git grep finds the files in path order, the lines in file order
+	r.commit("Add sources",
+		"b.go", "token\n",
+		"c.go", "token\ntoken\ntoken\n",
+		"a.go", "token\ntoken\n",
+		"d.go", "token\ntoken\n")
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{"none", []string{"a.go:1", "a.go:2", "b.go:1", "c.go:1", "c.go:2", "c.go:3", "d.go:1", "d.go:2"}},
+		{"path", []string{"a.go:1", "a.go:2", "b.go:1", "c.go:1", "c.go:2", "c.go:3", "d.go:1", "d.go:2"}},
+		// Files with as many matches stay in path order
+		{"count", []string{"c.go:1", "c.go:2", "c.go:3", "a.go:1", "a.go:2", "d.go:1", "d.go:2", "b.go:1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			g := r.tool()
+			g.fileSort = tt.order
+			matches, err := g.searchInFiles(SearchOptions{Query: "token"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, len(matches))
+			for i, match := range matches {
+				got[i] = fmt.Sprintf("%s:%d", match.Path, match.LineNumber)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matches = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Path order ignores the order the matches came in, none keeps it
+	shuffled := []FileMatch{{Path: "b.go", LineNumber: 2}, {Path: "a.go", LineNumber: 9}, {Path: "b.go", LineNumber: 1}}
+	sortFileMatches(shuffled, "none")
+	if got, want := matchPaths(shuffled), []string{"b.go", "a.go", "b.go"}; !slices.Equal(got, want) {
+		t.Errorf("none: paths = %q, want %q", got, want)
+	}
+	sortFileMatches(shuffled, "path")
+	if want := []FileMatch{{Path: "a.go", LineNumber: 9}, {Path: "b.go", LineNumber: 1}, {Path: "b.go", LineNumber: 2}}; !reflect.DeepEqual(shuffled, want) {
+		t.Errorf("path: matches = %+v, want %+v", shuffled, want)
+	}
+
+	if _, stderr, status := r.gst("-sort", "size", "-query", "token"); status != exitError || !strings.Contains(stderr, "-sort") {
+		t.Errorf("-sort size: exit status %d; stderr:\n%s", status, stderr)
+	}
+}
+
+func TestGroupByFile(t *testing.T) {
+	matches := []FileMatch{
+		{Path: "b.go", LineNumber: 1},
+		{Path: "a.go", LineNumber: 4},
+		{Path: "b.go", LineNumber: 7},
+		{Path: "a.go", LineNumber: 9},
+		{Path: "c.go", LineNumber: 2},
+	}
+	want := []FileMatch{
+		{Path: "b.go", LineNumber: 1},
+		{Path: "b.go", LineNumber: 7},
+		{Path: "a.go", LineNumber: 4},
+		{Path: "a.go", LineNumber: 9},
+		{Path: "c.go", LineNumber: 2},
+	}
+	if got := groupByFile(matches); !reflect.DeepEqual(got, want) {
+		t.Errorf("groupByFile() = %+v, want %+v", got, want)
+	}
+	if matches[1].Path != "a.go" {
+		t.Error("groupByFile() changed its argument")
+	}
+}
+
+func TestGroupedOutput(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add sources",
+		"a.go", "token a1\nother\ntoken a3\n",
+		"b.go", "token b1\n",
+		"c.go", "token c1\ntoken c2\ntoken c3\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"found order", nil, "a.go\n  1. 1:token a1\n  2. 3:token a3\n\nb.go\n  3. 1:token b1\n\nc.go\n  4. 1:token c1\n  5. 2:token c2\n  6. 3:token c3\n"},
+		{"sorted by count", []string{"-sort", "count"}, "c.go\n  1. 1:token c1\n  2. 2:token c2\n  3. 3:token c3\n\na.go\n  4. 1:token a1\n  5. 3:token a3\n\nb.go\n  6. 1:token b1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, status := r.gst(append([]string{"-quiet", "-head-only", "-group"}, append(tt.args, "-query", "token")...)...)
+			if status != exitMatch {
+				t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+			}
+			if stdout != tt.want {
+				t.Errorf("output:\n%s\nwant:\n%s", stdout, tt.want)
+			}
+		})
+	}
+}
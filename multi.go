@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RepoResults are the results of the search of one repository of a -multi
+// directory
+type RepoResults struct {
+	Repo  string `json:"repo"`
+	Error string `json:"error,omitempty"`
+	SearchResults
+
+	// prefix is the path of the repository relative to the working
+	// directory, put before its file matches
+	prefix string
+}
+
+// forRepo returns a copy of the tool with the same settings that searches
+// the repository at path. The copies share the recorder, so -debug-json and
+// -timing cover every repository.
+func (g *GitSearchTool) forRepo(path string) *GitSearchTool {
+	repo := *g
+	repo.repoPath = path
+	repo.ignoreSpecs = nil
+	repo.bare = false
+	repo.lastChanges = nil
+	repo.emitted, repo.suppressed, repo.failed = 0, 0, false
+	// Concurrent searches would each draw a spinner on the same line
+	repo.quiet = true
+	return &repo
+}
+
+// findRepos lists the immediate subdirectories of dir that are git
+// repositories, sorted by name
+func (g *GitSearchTool) findRepos(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if g.forRepo(path).isGitRepo() {
+			repos = append(repos, path)
+		}
+	}
+	return repos, nil
+}
+
+// performMultiSearch searches every repository directly below dir for query
+// on a pool of concurrency workers, then prints the results one repository
+// after the other in name order and returns the exit status
+func (g *GitSearchTool) performMultiSearch(dir, query string, concurrency int) int {
+	repos, err := g.findRepos(dir)
+	if err != nil {
+		errorf("Error reading %s: %v", dir, err)
+		return exitError
+	}
+	if len(repos) == 0 {
+		errorf("No git repositories found in %s", dir)
+		return exitError
+	}
+	g.statusf("Searching %d repositories in %s\n", len(repos), dir)
+
+	// Each worker writes only to the results of the repositories it takes,
+	// so they are printed in order without further locking
+	results := make([]RepoResults, len(repos))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(concurrency, len(repos)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				repo := g.forRepo(repos[i])
+				// Every repository gets the whole -timeout, however long the
+				// ones before it took
+				cancel := repo.startTimeout()
+				var state string
+				if g.checkpoint != nil {
+					state = repo.repoState()
+					if done, ok := g.checkpoint.done(repos[i], state); ok {
+						cancel()
+						done.prefix = relativePrefix(repos[i])
+						results[i] = done
+						continue
+					}
+				}
+				repo.shallow = repo.isShallowRepository()
+				specs, err := readIgnoreFile(filepath.Join(repos[i], ignoreFileName))
+				repo.ignoreSpecs = specs
+				var found SearchResults
+				if err == nil {
+					found, err = repo.collectSearchResults(query)
+				}
+				cancel()
+				results[i] = RepoResults{Repo: filepath.Base(repos[i]), SearchResults: found, prefix: relativePrefix(repos[i])}
+				if err != nil {
+					results[i].Error = err.Error()
+					continue
+				}
+				if repo.shallow && !g.headOnly {
+					warnf("%s is a shallow clone, its commit history is truncated", results[i].Repo)
+				}
+				if g.checkpoint != nil {
+					if err := g.checkpoint.record(repos[i], state, results[i]); err != nil {
+						warnf("could not save the checkpoint: %v", err)
+					}
+				}
+			}
+		}()
+	}
+	for i := range repos {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if g.format == "json" {
+		g.writeMultiJSON(results)
+	} else {
+		g.displayMultiResults(results, query)
+	}
+
+	status := exitNoMatch
+	for _, result := range results {
+		switch {
+		case result.Error != "":
+			return exitError
+		case g.dryRun || result.CommitCount+result.FileMatchCount+result.Suppressed > 0:
+			status = exitMatch
+		}
+	}
+	return status
+}
+
+// writeMultiJSON writes the results of a -multi search as a JSON array with
+// an element per repository
+func (g *GitSearchTool) writeMultiJSON(results []RepoResults) {
+	g.writeJSON(results)
+}
+
+// displayMultiResults prints the commit and file matches of each repository
+// of a -multi search under its name, the file paths prefixed with its path
+// from the working directory, and the totals across all of them
+func (g *GitSearchTool) displayMultiResults(results []RepoResults, query string) {
+	var commitCount, fileMatchCount, repoCount int
+	for _, result := range results {
+		g.decorf("\n%s\n", bold("=== "+result.Repo+" ==="))
+		if result.Error != "" {
+			g.searchErrorf("Error searching %s: %s", result.Repo, result.Error)
+			continue
+		}
+		if result.CommitCount+result.FileMatchCount == 0 {
+			g.decorf("No matches found.\n")
+			continue
+		}
+
+		for i, commit := range append(result.Commits, result.Changes...) {
+			fmt.Println(g.formatCommit(i, commit))
+		}
+		for _, match := range result.Files {
+			fmt.Printf("%s%s:%d:%s\n", result.prefix, match.Path, match.LineNumber,
+				highlightPatterns(match.Content, g.filePatternsOf(query), g.filesCaseSensitive()))
+		}
+		commitCount += result.CommitCount
+		fileMatchCount += result.FileMatchCount
+		repoCount++
+	}
+
+	g.decorf("\nFound %d commit matches and %d file matches in %d of %d repositories.\n",
+		commitCount, fileMatchCount, repoCount, len(results))
+	g.decorf("\n")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMultiSearch(t *testing.T) {
+	parent := testDir(t)
+	newRepo := func(name string) *testRepo {
+		t.Helper()
+		r := &testRepo{t: t, dir: filepath.Join(parent, name)}
+		if err := os.Mkdir(r.dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		r.git("init", "-q", "-b", "main")
+		return r
+	}
+	billing := newRepo("billing")
+	billing.commit("Add invoice token", "invoice.go", "token\n")
+	users := newRepo("users")
+	users.commit("Add login token", "auth/login.go", "token\nsession token\n")
+	users.commit("Rotate token", "auth/login.go", "token\nrotated token\n")
+	newRepo("empty").commit("Initial commit", "README.md", "nothing here\n")
+	// Neither a plain directory nor a file is searched
+	if err := os.Mkdir(filepath.Join(parent, "notes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(parent, "token.txt"), []byte("token\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := NewGitSearchTool(parent).findRepos(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(parent, "billing"), filepath.Join(parent, "empty"), filepath.Join(parent, "users")}
+	if !slices.Equal(repos, want) {
+		t.Errorf("findRepos() = %q, want %q", repos, want)
+	}
+
+	for _, concurrency := range []string{"1", "3"} {
+		t.Run("concurrency "+concurrency, func(t *testing.T) {
+			stdout, stderr, status := runGst(t, parent, "-no-banner", "-multi", ".", "-concurrency", concurrency, "-query", "token")
+			if status != exitMatch {
+				t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+			}
+			// Repositories are listed by name with their file paths prefixed
+			for _, want := range []string{
+				"=== billing ===", "Add invoice token", "billing/invoice.go:1:token",
+				"=== empty ===\nNo matches found.",
+				"=== users ===", "Rotate token", "Add login token", "users/auth/login.go:2:rotated token",
+				"Found 3 commit matches and 3 file matches in 2 of 3 repositories.",
+			} {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("output is missing %q:\n%s", want, stdout)
+				}
+			}
+			if i, j := strings.Index(stdout, "=== billing ==="), strings.Index(stdout, "=== users ==="); i > j {
+				t.Errorf("repositories aren't in name order:\n%s", stdout)
+			}
+		})
+	}
+
+	stdout, stderr, status := runGst(t, parent, "-format", "json", "-multi", ".", "-query", "token")
+	if status != exitMatch {
+		t.Fatalf("-format json: exit status %d; stderr:\n%s", status, stderr)
+	}
+	var results []RepoResults
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatalf("-format json: %v\n%s", err, stdout)
+	}
+	counts := make(map[string][2]int)
+	for _, result := range results {
+		counts[result.Repo] = [2]int{result.CommitCount, result.FileMatchCount}
+	}
+	if want := map[string][2]int{"billing": {1, 1}, "empty": {0, 0}, "users": {2, 2}}; !maps.Equal(counts, want) {
+		t.Errorf("commit and file match counts = %v, want %v", counts, want)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// noteLines is the number of matched lines shown under each note
+const noteLines = 5
+
+// NoteMatch is an object, usually a commit, whose git note matched a search
+type NoteMatch struct {
+	Commit string `json:"commit"`
+
+	// Lines are the lines of the note that matched
+	Lines []string `json:"lines"`
+}
+
+// searchInNotes searches the notes of the default notes ref line by line,
+// finding nothing when there are no notes
+func (g *GitSearchTool) searchInNotes(opts SearchOptions) ([]NoteMatch, error) {
+	opts.MaxResults = opts.limit(g.maxCommits)
+	cmd := g.gitCommand("notes", "list")
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notes: %v", err)
+	}
+
+	// Each line is "<note blob> <annotated object>"
+	var blobs, objects []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if blob, object, ok := strings.Cut(line, " "); ok {
+			blobs = append(blobs, blob)
+			objects = append(objects, object)
+		}
+	}
+	if len(blobs) == 0 {
+		return nil, nil
+	}
+
+	notes, err := g.readBlobs(blobs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notes: %v", err)
+	}
+
+	var matches []NoteMatch
+	for i, note := range notes {
+		match := NoteMatch{Commit: objects[i]}
+		for _, line := range strings.Split(strings.TrimSpace(toUTF8(note)), "\n") {
+			if g.matchesTerms(line, opts) {
+				match.Lines = append(match.Lines, line)
+			}
+		}
+		if len(match.Lines) == 0 {
+			continue
+		}
+
+		matches = append(matches, match)
+		if len(matches) == opts.MaxResults {
+			break
+		}
+	}
+
+	return matches, nil
+}
+
+// readBlobs returns the contents of blobs with a single git cat-file
+func (g *GitSearchTool) readBlobs(blobs []string) ([]string, error) {
+	cmd := g.gitCommand("cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(strings.Join(blobs, "\n") + "\n")
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each blob is "<hash> blob <size>\n<contents>\n"
+	reader := bufio.NewReader(bytes.NewReader(output))
+	contents := make([]string, 0, len(blobs))
+	for range blobs {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git cat-file output: %v", err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected git cat-file output: %q", header)
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git cat-file output: %q", header)
+		}
+
+		content := make([]byte, size+1)
+		if _, err := io.ReadFull(reader, content); err != nil {
+			return nil, fmt.Errorf("unexpected git cat-file output: %v", err)
+		}
+		contents = append(contents, string(content[:size]))
+	}
+	return contents, nil
+}
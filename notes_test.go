@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchInNotes(t *testing.T) {
+	r := newTestRepo(t)
+
+	// A repository without notes has nothing to find
+	r.commit("Add parser")
+	if got, err := r.tool().searchInNotes(SearchOptions{Query: "reviewed"}); err != nil || len(got) != 0 {
+		t.Errorf("searchInNotes() without notes = %+v, %v", got, err)
+	}
+
+	parser := r.head()
+	r.git("notes", "add", "-m", "Reviewed-by: Alice\nCI: passed\nreviewed again after rebase", parser)
+	lexer := r.commit("Add lexer")
+	r.git("notes", "add", "-m", "CI: failed", lexer)
+	docs := r.commit("Add docs")
+	r.git("notes", "add", "-m", "Reviewed-by: Bob", docs)
+
+	tests := []struct {
+		query string
+		want  map[string][]string
+	}{
+		{"reviewed", map[string][]string{
+			parser: {"Reviewed-by: Alice", "reviewed again after rebase"},
+			docs:   {"Reviewed-by: Bob"},
+		}},
+		{"CI:", map[string][]string{
+			parser: {"CI: passed"},
+			lexer:  {"CI: failed"},
+		}},
+		{"missing", map[string][]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			notes, err := r.tool().searchInNotes(SearchOptions{Query: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string][]string)
+			for _, note := range notes {
+				got[note.Commit] = note.Lines
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchInNotes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
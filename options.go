@@ -0,0 +1,107 @@
+package main
+
+import "flag"
+
+// SearchOptions are the settings of a single commit or file search. The zero
+// value searches case-insensitively for a fixed string, without author, date
+// or path restrictions, and with the tool's section limits.
+type SearchOptions struct {
+	Query string
+
+	// MaxResults limits the matches returned, 0 uses -max-commits or
+	// -max-files
+	MaxResults int
+
+	CaseSensitive bool
+
+	// Author, Since and Until restrict commit searches, in any format
+	// git log accepts
+	Author string
+	Since  string
+	Until  string
+
+	// Committer restricts commit searches to committers matching this
+	// pattern, like Author
+	Committer string
+
+	// AuthorRegex restricts commit searches to authors whose "Name <email>"
+	// matches this git basic regular expression, ignoring case unless
+	// CaseSensitive
+	AuthorRegex string
+
+	// Regex matches file contents with extended regular expressions
+	Regex bool
+
+	// WholeWord only matches file contents at word boundaries
+	WholeWord bool
+
+	// PathFilters are extra pathspecs for file searches, relative to the
+	// repository root
+	PathFilters []string
+}
+
+// searchOptions returns the options of a search for query as configured on
+// the command line
+func (g *GitSearchTool) searchOptions(query string) SearchOptions {
+	return SearchOptions{
+		Query:         query,
+		CaseSensitive: g.commitsCaseSensitive(),
+		Author:        g.author,
+		AuthorRegex:   g.authorRegex,
+		Committer:     g.committer,
+		Since:         g.since,
+		Until:         g.until,
+		Regex:         g.regex,
+		WholeWord:     g.wholeWord,
+		PathFilters:   g.pathFilters,
+	}
+}
+
+// fileSearchOptions returns the options of a file search for query, which
+// differ from searchOptions in case sensitivity with -file-case-sensitive
+func (g *GitSearchTool) fileSearchOptions(query string) SearchOptions {
+	opts := g.searchOptions(query)
+	opts.CaseSensitive = g.filesCaseSensitive()
+	return opts
+}
+
+// resolveCaseSensitive returns the case sensitivity of a section, its own
+// setting when given and the global -case-sensitive otherwise
+func resolveCaseSensitive(section *bool, global bool) bool {
+	if section != nil {
+		return *section
+	}
+	return global
+}
+
+// commitsCaseSensitive reports whether commit, tag, stash, note and reflog
+// searches match case
+func (g *GitSearchTool) commitsCaseSensitive() bool {
+	return resolveCaseSensitive(g.commitCaseSensitive, g.caseSensitive)
+}
+
+// filesCaseSensitive reports whether file searches match case
+func (g *GitSearchTool) filesCaseSensitive() bool {
+	return resolveCaseSensitive(g.fileCaseSensitive, g.caseSensitive)
+}
+
+// setCaseOverrides applies -commit-case-sensitive and -file-case-sensitive,
+// whose values are commits and files, when they were given
+func (g *GitSearchTool) setCaseOverrides(fs *flag.FlagSet, commits, files *bool) {
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "commit-case-sensitive":
+			g.commitCaseSensitive = commits
+		case "file-case-sensitive":
+			g.fileCaseSensitive = files
+		}
+	})
+}
+
+// limit returns MaxResults, or def when it is unset
+func (o SearchOptions) limit(def int) int {
+	if o.MaxResults > 0 {
+		return o.MaxResults
+	}
+	return def
+}
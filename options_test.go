@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGrepArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  SearchOptions
+		setup func(g *GitSearchTool)
+		want  []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"grep", "-n", "-i", "-e", "needle"},
+		},
+		{
+			name: "case sensitive",
+			opts: SearchOptions{CaseSensitive: true},
+			want: []string{"grep", "-n", "-e", "needle"},
+		},
+		{
+			name: "regex",
+			opts: SearchOptions{Regex: true},
+			want: []string{"grep", "-n", "-i", "-E", "-e", "needle"},
+		},
+		{
+			name: "whole word",
+			opts: SearchOptions{WholeWord: true},
+			want: []string{"grep", "-n", "-i", "-w", "-e", "needle"},
+		},
+		{
+			name: "path filters",
+			opts: SearchOptions{PathFilters: []string{"src", ":(exclude)vendor"}},
+			want: []string{"grep", "-n", "-i", "-e", "needle", "--", "src", ":(exclude)vendor"},
+		},
+		{
+			name:  "extra terms",
+			setup: func(g *GitSearchTool) { g.extraQueries = []string{"pin"} },
+			want:  []string{"grep", "-n", "-i", "-e", "needle", "-e", "pin"},
+		},
+		{
+			name:  "all terms",
+			setup: func(g *GitSearchTool) { g.extraQueries, g.matchAll = []string{"pin"}, true },
+			want:  []string{"grep", "-n", "-i", "-e", "needle", "-e", "pin", "--all-match"},
+		},
+		{
+			name:  "expression",
+			setup: func(g *GitSearchTool) { g.fileExprArgs = []string{"-e", "a", "--and", "--not", "-e", "b"} },
+			want:  []string{"grep", "-n", "-i", "-e", "a", "--and", "--not", "-e", "b"},
+		},
+		{
+			name:  "tree-ish",
+			setup: func(g *GitSearchTool) { g.at = "v1.0" },
+			want:  []string{"grep", "-n", "-i", "-e", "needle", "v1.0"},
+		},
+		{
+			name:  "file patterns within paths",
+			setup: func(g *GitSearchTool) { g.pathspecs, g.filePatterns = []string{"cmd/"}, []string{"*.go", "*.mod"} },
+			want:  []string{"grep", "-n", "-i", "-e", "needle", "--", "cmd/*.go", "cmd/*.mod"},
+		},
+		{
+			name:  "ignore file",
+			setup: func(g *GitSearchTool) { g.ignoreSpecs = []string{":(top,exclude,glob)**/gen"} },
+			want:  []string{"grep", "-n", "-i", "-e", "needle", "--", ":(top,exclude,glob)**/gen"},
+		},
+		{
+			name: "every option",
+			opts: SearchOptions{CaseSensitive: true, Regex: true, WholeWord: true, PathFilters: []string{"src"}},
+			setup: func(g *GitSearchTool) {
+				g.invert, g.untracked, g.recurseSubmodules, g.textconv, g.includeBinary = true, true, true, true, true
+			},
+			want: []string{"grep", "-n", "-E", "-w", "-v", "--untracked", "--recurse-submodules", "--textconv", "-a", "-e", "needle", "--", "src"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGitSearchTool(t.TempDir())
+			if tt.setup != nil {
+				tt.setup(g)
+			}
+			opts := tt.opts
+			opts.Query = "needle"
+			if got := g.grepArgs(opts, "-n"); !slices.Equal(got, tt.want) {
+				t.Errorf("grepArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchOptions(t *testing.T) {
+	g := NewGitSearchTool(t.TempDir())
+	g.author, g.authorRegex, g.committer = "alice", `alice\|bob`, "carol"
+	g.since, g.until = "2024-01-01", "2024-06-30"
+	g.regex, g.wholeWord = true, true
+	g.pathFilters = []string{"src"}
+
+	want := SearchOptions{
+		Query:       "needle",
+		Author:      "alice",
+		AuthorRegex: `alice\|bob`,
+		Committer:   "carol",
+		Since:       "2024-01-01",
+		Until:       "2024-06-30",
+		Regex:       true,
+		WholeWord:   true,
+		PathFilters: []string{"src"},
+	}
+	if got := g.searchOptions("needle"); !reflect.DeepEqual(got, want) {
+		t.Errorf("searchOptions() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSearchOptionsLimit(t *testing.T) {
+	tests := []struct {
+		maxResults, def, want int
+	}{
+		{0, 10, 10},
+		{3, 10, 3},
+		{30, 10, 30},
+	}
+	for _, tt := range tests {
+		if got := (SearchOptions{MaxResults: tt.maxResults}).limit(tt.def); got != tt.want {
+			t.Errorf("SearchOptions{MaxResults: %d}.limit(%d) = %d, want %d", tt.maxResults, tt.def, got, tt.want)
+		}
+	}
+}
+
+func TestCaseOverrides(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Initial commit")
+
+	tests := []struct {
+		args                  []string
+		logIgnore, grepIgnore bool
+	}{
+		{[]string{"-commit-case-sensitive=false", "-file-case-sensitive=false"}, true, true},
+		{[]string{"-commit-case-sensitive=false", "-file-case-sensitive=true"}, true, false},
+		{[]string{"-commit-case-sensitive=true", "-file-case-sensitive=false"}, false, true},
+		{[]string{"-commit-case-sensitive=true", "-file-case-sensitive=true"}, false, false},
+		// A section's own setting wins over -case-sensitive either way
+		{[]string{"-case-sensitive", "-commit-case-sensitive=false"}, true, false},
+		{[]string{"-case-sensitive=false", "-file-case-sensitive"}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			_, stderr, status := r.gst(append([]string{"-quiet", "-dry-run"}, append(tt.args, "-query", "Token")...)...)
+			if status != exitMatch {
+				t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+			}
+			ignoresCase := make(map[string]bool)
+			for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+				args := strings.Fields(line)
+				if len(args) > 1 {
+					ignoresCase[args[1]] = slices.Contains(args, "-i")
+				}
+			}
+			if len(ignoresCase) != 2 {
+				t.Fatalf("want a git log and a git grep command:\n%s", stderr)
+			}
+			if ignoresCase["log"] != tt.logIgnore || ignoresCase["grep"] != tt.grepIgnore {
+				t.Errorf("-i on git log %v and git grep %v, want %v and %v:\n%s",
+					ignoresCase["log"], ignoresCase["grep"], tt.logIgnore, tt.grepIgnore, stderr)
+			}
+		})
+	}
+}
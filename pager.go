@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// pager passes output through a page of lines at a time, asking more
+// whether to go on before each further page. Once more declines, the rest
+// of the output is dropped.
+type pager struct {
+	out      io.Writer
+	pageSize int
+	more     func() bool
+
+	lines int
+	quit  bool
+}
+
+func (p *pager) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 && !p.quit {
+		// The prompt only comes once there is output beyond a full page
+		if p.lines == p.pageSize {
+			if !p.more() {
+				p.quit = true
+				break
+			}
+			p.lines = 0
+		}
+
+		end := len(b)
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			end = i + 1
+			p.lines++
+		}
+		if _, err := p.out.Write(b[:end]); err != nil {
+			return n - len(b), err
+		}
+		b = b[end:]
+	}
+	return n, nil
+}
+
+// pageOutput runs search with its stdout going through a pager, which reads
+// the answers to its prompts from scanner
+func (g *GitSearchTool) pageOutput(scanner *bufio.Scanner, search func()) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		search()
+		return
+	}
+
+	stdout := os.Stdout
+	p := &pager{
+		out:      stdout,
+		pageSize: g.pageSize,
+		more: func() bool {
+			fmt.Fprint(stdout, "-- more -- (Enter for the next page, q to quit) ")
+			return scanner.Scan() && strings.TrimSpace(scanner.Text()) != "q"
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(p, r)
+		close(done)
+	}()
+
+	// Colors are decided by looking at stdout, which is now the pipe
+	mode := colorMode
+	if colorMode == "auto" {
+		colorMode = "always"
+	}
+	os.Stdout = w
+	search()
+	os.Stdout = stdout
+	colorMode = mode
+
+	w.Close()
+	<-done
+	r.Close()
+}
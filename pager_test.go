@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPager(t *testing.T) {
+	tests := []struct {
+		name       string
+		pageSize   int
+		writes     []string
+		answers    []bool
+		want       string
+		wantPrompt int
+	}{
+		{"one page", 3, []string{"1\n2\n3\n"}, nil, "1\n2\n3\n", 0},
+		{"next page", 2, []string{"1\n2\n3\n4\n5\n"}, []bool{true, true}, "1\n2\n3\n4\n5\n", 2},
+		{"quit", 2, []string{"1\n2\n3\n4\n5\n"}, []bool{false}, "1\n2\n", 1},
+		{"quit on the second page", 2, []string{"1\n2\n3\n4\n5\n"}, []bool{true, false}, "1\n2\n3\n4\n", 2},
+		{"lines split across writes", 2, []string{"fir", "st\nsec", "ond\nthi", "rd\n"}, []bool{true}, "first\nsecond\nthird\n", 1},
+		{"several lines per write", 2, []string{"1\n", "2\n3\n4\n", "5\n"}, []bool{true, false}, "1\n2\n3\n4\n", 2},
+		{"no trailing newline", 2, []string{"1\n2\nlast"}, []bool{true}, "1\n2\nlast", 1},
+		{"dropped after quitting", 1, []string{"1\n2\n", "3\n"}, []bool{false}, "1\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			prompts := 0
+			p := &pager{out: &out, pageSize: tt.pageSize, more: func() bool {
+				prompts++
+				if prompts > len(tt.answers) {
+					t.Fatalf("prompt %d wasn't expected", prompts)
+				}
+				return tt.answers[prompts-1]
+			}}
+			for _, chunk := range tt.writes {
+				if n, err := p.Write([]byte(chunk)); n != len(chunk) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+				}
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+			if prompts != tt.wantPrompt {
+				t.Errorf("prompted %d times, want %d", prompts, tt.wantPrompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import "sync"
+
+// minFileChunk is the fewest file matches handed to a worker at a time.
+// Formatting a match takes a couple of microseconds, so a chunk of 64 is
+// well over the cost of starting the workers and handing out chunks, which
+// BenchmarkProcessInChunks measures; fewer matches are formatted serially.
+const minFileChunk = 64
+
+// fileChunkSize splits n matches evenly between threads workers, in chunks
+// of at least minFileChunk
+func fileChunkSize(n, threads int) int {
+	return max((n+threads-1)/threads, minFileChunk)
+}
+
+// processInChunks applies fn to every match, splitting the matches into chunks
+// handled by at most threads workers. Results keep the order of the input.
+func processInChunks(matches []FileMatch, threads int, fn func(i int, match FileMatch) string) []string {
+	results := make([]string, len(matches))
+	if threads <= 1 || len(matches) <= minFileChunk {
+		for i, match := range matches {
+			results[i] = fn(i, match)
+		}
+		return results
+	}
+
+	size := fileChunkSize(len(matches), threads)
+	chunks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(threads, (len(matches)+size-1)/size); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each worker writes only to its own chunk of results, so the
+			// output is reassembled in order without further locking
+			for start := range chunks {
+				end := min(start+size, len(matches))
+				for i := start; i < end; i++ {
+					results[i] = fn(i, matches[i])
+				}
+			}
+		}()
+	}
+	for start := 0; start < len(matches); start += size {
+		chunks <- start
+	}
+	close(chunks)
+	wg.Wait()
+
+	return results
+}
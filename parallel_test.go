@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFileChunkSize(t *testing.T) {
+	tests := []struct {
+		n, threads, want int
+	}{
+		{65, 4, minFileChunk},
+		{1000, 4, 250},
+		{1001, 4, 251},
+		{1000, 100, minFileChunk},
+	}
+	for _, tt := range tests {
+		if got := fileChunkSize(tt.n, tt.threads); got != tt.want {
+			t.Errorf("fileChunkSize(%d, %d) = %d, want %d", tt.n, tt.threads, got, tt.want)
+		}
+	}
+}
+
+func TestProcessInChunks(t *testing.T) {
+	for _, n := range []int{0, 1, minFileChunk, minFileChunk + 1, 1000} {
+		for _, threads := range []int{1, 3, 16} {
+			t.Run(fmt.Sprintf("%d matches on %d threads", n, threads), func(t *testing.T) {
+				matches := make([]FileMatch, n)
+				for i := range matches {
+					matches[i].LineNumber = i + 1
+				}
+				var calls atomic.Int32
+				got := processInChunks(matches, threads, func(i int, match FileMatch) string {
+					calls.Add(1)
+					return strconv.Itoa(i) + ":" + strconv.Itoa(match.LineNumber)
+				})
+				if len(got) != n || int(calls.Load()) != n {
+					t.Fatalf("%d results from %d calls, want %d", len(got), calls.Load(), n)
+				}
+				for i, result := range got {
+					if want := strconv.Itoa(i) + ":" + strconv.Itoa(i+1); result != want {
+						t.Fatalf("result %d = %q, want %q", i, result, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkProcessInChunks formats file matches like a search does,
+// serially and on pools of workers; compare with -cpu to see what the pool
+// gains on several cores
+func BenchmarkProcessInChunks(b *testing.B) {
+	patterns := []string{"token"}
+	format := func(i int, match FileMatch) string {
+		text := truncateAround(match.Content, patterns, false, 0)
+		return fmt.Sprintf("%d. %s:%d:", i+1, match.Path, match.LineNumber) + highlightPatterns(text, patterns, false)
+	}
+	for _, n := range []int{20, 200, 2000, 20000} {
+		matches := make([]FileMatch, n)
+		for i := range matches {
+			matches[i] = FileMatch{Path: "internal/api/client.go", LineNumber: i + 1,
+				Content: strings.Repeat("x", 40) + " token " + strings.Repeat("y", 40)}
+		}
+		for _, threads := range []int{1, 4, 16} {
+			name := fmt.Sprintf("%d matches/serial", n)
+			if threads > 1 {
+				name = fmt.Sprintf("%d matches/%d workers", n, threads)
+			}
+			b.Run(name, func(b *testing.B) {
+				for b.Loop() {
+					processInChunks(matches, threads, format)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// patchEntry is a single message of an mbox or format-patch file
+type patchEntry struct {
+	subject string
+	author  string
+	date    string
+	message []string
+	diff    []patchLine
+}
+
+// patchLine is a line of a patch's diff together with the file it belongs to
+type patchLine struct {
+	file string
+	text string
+}
+
+// patchMatch is a line of a patch that matched a query
+type patchMatch struct {
+	section string
+	file    string
+	text    string
+}
+
+// parsePatchFile reads an mbox or git format-patch file into its messages
+func parsePatchFile(path string) ([]patchEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open patch file: %v", err)
+	}
+	defer f.Close()
+
+	var (
+		entries []patchEntry
+		current *patchEntry
+		state   string
+		header  string
+		file    string
+	)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// An mbox "From " separator line starts a new message
+		if isMboxSeparator(line) && (current == nil || state != "headers") {
+			entries = append(entries, patchEntry{})
+			current = &entries[len(entries)-1]
+			state = "headers"
+			header = ""
+			file = ""
+			continue
+		}
+		if current == nil {
+			// A bare patch without an mbox envelope
+			entries = append(entries, patchEntry{})
+			current = &entries[len(entries)-1]
+			state = "headers"
+		}
+
+		// A diff can start anywhere: plain git diff output has no headers
+		// or message before it
+		if strings.HasPrefix(line, "diff --git ") && state != "signature" {
+			state = "diff"
+			file = diffFileName(line)
+			continue
+		}
+
+		switch state {
+		case "headers":
+			if line == "" {
+				state = "message"
+				continue
+			}
+			if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && header == "subject" {
+				current.subject += " " + strings.TrimSpace(line)
+				continue
+			}
+			header = ""
+			name, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(name) {
+			case "subject":
+				current.subject = value
+				header = "subject"
+			case "from":
+				current.author = value
+			case "date":
+				current.date = value
+			}
+		case "message":
+			if line == "---" {
+				state = "stat"
+				continue
+			}
+			current.message = append(current.message, line)
+		case "diff":
+			if line == "-- " {
+				state = "signature"
+				continue
+			}
+			if isDiffContentLine(line) {
+				current.diff = append(current.diff, patchLine{file: file, text: line})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read patch file: %v", err)
+	}
+
+	for i := range entries {
+		entries[i].subject = strings.TrimSpace(entries[i].subject)
+		entries[i].message = trimBlankLines(entries[i].message)
+	}
+
+	return entries, nil
+}
+
+// mboxSeparator matches the "From " line starting each message of an mbox:
+// the sender, a commit hash in git format-patch output or an address, and
+// an asctime date such as "Mon Sep 17 00:00:00 2001"
+var mboxSeparator = regexp.MustCompile(`^From (?:[0-9a-f]{40}|[0-9a-f]{64}|\S*@\S*|MAILER-DAEMON) +(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun) (?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) +\d{1,2} \d{2}:\d{2}`)
+
+// isMboxSeparator reports whether line starts a new mbox message, telling
+// it apart from a message line that merely starts with "From "
+func isMboxSeparator(line string) bool {
+	return mboxSeparator.MatchString(line)
+}
+
+// diffFileName extracts the destination path from a "diff --git a/x b/x" line
+func diffFileName(line string) string {
+	if i := strings.LastIndex(line, " b/"); i >= 0 {
+		return line[i+3:]
+	}
+	return strings.TrimPrefix(line, "diff --git ")
+}
+
+// isDiffContentLine reports whether a diff line is hunk content rather than
+// file headers or hunk markers
+func isDiffContentLine(line string) bool {
+	if strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- ") {
+		return false
+	}
+	return strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") || strings.HasPrefix(line, " ")
+}
+
+// trimBlankLines drops leading and trailing empty lines
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// searchPatch returns the lines of a patch's message and diff matching the
+// query like the repository searches: the message with the commit options,
+// the changed lines without their +, - or space marker with the file ones
+func (g *GitSearchTool) searchPatch(entry patchEntry, query string) []patchMatch {
+	var matches []patchMatch
+
+	messageOpts := g.searchOptions(query)
+	if g.matchesPatchText(entry.subject, messageOpts) {
+		matches = append(matches, patchMatch{section: "subject", text: entry.subject})
+	}
+	for _, line := range entry.message {
+		if g.matchesPatchText(line, messageOpts) {
+			matches = append(matches, patchMatch{section: "message", text: line})
+		}
+	}
+	diffOpts := g.fileSearchOptions(query)
+	for _, line := range entry.diff {
+		if g.matchesPatchText(line.text[1:], diffOpts) {
+			matches = append(matches, patchMatch{section: "diff", file: line.file, text: line.text})
+		}
+	}
+
+	return matches
+}
+
+// matchesPatchText reports whether a line of a patch matches the query
+// terms of opts like matchesTerms, at word boundaries with -word as git grep
+// -w does
+func (g *GitSearchTool) matchesPatchText(text string, opts SearchOptions) bool {
+	terms := g.queryTerms(opts.Query)
+	matched := 0
+	for _, term := range terms {
+		if opts.WholeWord {
+			if _, err := regexp.Compile(term); err != nil {
+				term = regexp.QuoteMeta(term)
+			}
+			term = `\b(?:` + term + `)\b`
+		}
+		if matchesPattern(text, term, opts.CaseSensitive) {
+			matched++
+		}
+	}
+	if g.matchAll {
+		return matched == len(terms)
+	}
+	return matched > 0
+}
+
+// displayPatchSearch searches every message of a patch file, reports which
+// patches matched and returns the exit status of the search
+func (g *GitSearchTool) displayPatchSearch(path, query string) int {
+	entries, err := parsePatchFile(path)
+	if err != nil {
+		errorf("Error reading patch file: %v", err)
+		return exitError
+	}
+
+	fmt.Printf("\n=== Patch Search Results for: \"%s\" in %s ===\n", query, path)
+
+	matched := 0
+	for _, entry := range entries {
+		matches := g.searchPatch(entry, query)
+		if len(matches) == 0 {
+			continue
+		}
+		matched++
+
+		subject := entry.subject
+		if subject == "" {
+			// Plain diffs have no message
+			subject = "(no subject)"
+		}
+		fmt.Printf("\n--- %s ---\n", subject)
+		if entry.author != "" {
+			fmt.Printf("Author: %s (%s)\n", entry.author, entry.date)
+		}
+		for i, match := range matches {
+			if match.file != "" {
+				fmt.Printf("%d. [%s] %s: %s\n", i+1, match.section, match.file, match.text)
+			} else {
+				fmt.Printf("%d. [%s] %s\n", i+1, match.section, match.text)
+			}
+		}
+	}
+
+	if matched == 0 {
+		fmt.Println("No matches found in patch file.")
+		fmt.Println()
+		return exitNoMatch
+	}
+	fmt.Printf("\n%d of %d patches matched.\n", matched, len(entries))
+	fmt.Println()
+	return exitMatch
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultConfigFilePatterns are the file name patterns searched by -config-files
+var defaultConfigFilePatterns = []string{
+	"*.yaml", "*.yml", "*.json", "*.toml", "*.ini", "*.env",
+	"Dockerfile", "*/Dockerfile",
+}
+
+// filePresets are the file name patterns of the language groups searched by
+// -preset
+var filePresets = map[string][]string{
+	"go":   {"*.go", "go.mod", "*/go.mod", "go.sum", "*/go.sum"},
+	"web":  {"*.js", "*.jsx", "*.ts", "*.tsx", "*.css", "*.scss", "*.html"},
+	"docs": {"*.md", "*.txt", "*.rst", "*.adoc"},
+}
+
+// presetNames returns the names of the file presets, sorted
+func presetNames() []string {
+	names := make([]string, 0, len(filePresets))
+	for name := range filePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getConfigFilePatterns returns the gst.configFiles git config values, which
+// replace the built-in config file patterns when set
+func (g *GitSearchTool) getConfigFilePatterns() []string {
+	cmd := g.gitCommand("config", "--get-all", "gst.configFiles")
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return defaultConfigFilePatterns
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+	if len(patterns) == 0 {
+		return defaultConfigFilePatterns
+	}
+	return patterns
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add files",
+		"main.go", "token\n",
+		"go.mod", "module token\n",
+		"tools/go.mod", "module token/tools\n",
+		"app.ts", "token\n",
+		"README.md", "token\n",
+		"script.py", "token\n")
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantSpecs string
+		wantPaths []string
+	}{
+		{"go", []string{"-preset", "go"}, `'*.go' go.mod '*/go.mod' go.sum '*/go.sum'`,
+			[]string{"go.mod", "main.go", "tools/go.mod"}},
+		{"docs", []string{"-preset", "docs"}, `'*.md' '*.txt' '*.rst' '*.adoc'`,
+			[]string{"README.md"}},
+		{"two presets", []string{"-preset", "docs", "-preset", "web"}, `'*.md' '*.txt' '*.rst' '*.adoc' '*.js' '*.jsx' '*.ts' '*.tsx' '*.css' '*.scss' '*.html'`,
+			[]string{"README.md", "app.ts"}},
+		{"with -ext", []string{"-preset", "go", "-ext", "py"}, `'*.py' '*.go' go.mod '*/go.mod' go.sum '*/go.sum'`,
+			[]string{"go.mod", "main.go", "script.py", "tools/go.mod"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-quiet", "-head-only"}, append(tt.args, "-query", "token")...)
+			_, stderr, _ := r.gst(append([]string{"-dry-run"}, args...)...)
+			if want := "git grep -n -z -i -e token -- " + tt.wantSpecs + "\n"; stderr != want {
+				t.Errorf("command = %q, want %q", stderr, want)
+			}
+
+			stdout, stderr, status := r.gst(args...)
+			if status != exitMatch {
+				t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+			}
+			var paths []string
+			for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+				_, rest, _ := strings.Cut(line, ". ")
+				path, _, _ := strings.Cut(rest, ":")
+				paths = append(paths, path)
+			}
+			if !slices.Equal(paths, tt.wantPaths) {
+				t.Errorf("paths = %q, want %q", paths, tt.wantPaths)
+			}
+		})
+	}
+
+	_, stderr, status := r.gst("-preset", "rust", "-query", "token")
+	if status != exitError || !strings.Contains(stderr, `unknown -preset "rust", use one of docs, go, web`) {
+		t.Errorf("unknown preset: exit status %d, stderr:\n%s", status, stderr)
+	}
+}
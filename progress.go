@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// progressDelay is how long a search runs before progress is shown, so that
+// quick searches don't flash a message
+const progressDelay = 500 * time.Millisecond
+
+// progressFrames are the frames of the spinner
+const progressFrames = `|/-\`
+
+// progress shows message with a spinner on stderr once progressDelay has
+// passed, until the returned function is called. That function clears the
+// line again and returns once the spinner has stopped. Nothing is shown
+// with -quiet or when stderr isn't a terminal, so results and JSON on stdout
+// are never affected.
+func (g *GitSearchTool) progress(message string) func() {
+	if g.quiet || !stderrIsTerminal() {
+		return func() {}
+	}
+	return spin(os.Stderr, message, progressDelay)
+}
+
+// spin writes message with a spinner to w once delay has passed, until the
+// returned function is called
+func spin(w io.Writer, message string, delay time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			fmt.Fprintf(w, "\r%c %s", progressFrames[i%len(progressFrames)], message)
+			select {
+			case <-done:
+				fmt.Fprintf(w, "\r%s\r", strings.Repeat(" ", len(message)+2))
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpin(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		runFor   time.Duration
+		wantShow bool
+	}{
+		{"quick search", time.Hour, 10 * time.Millisecond, false},
+		{"slow search", 0, 250 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			stop := spin(&out, "Searching files...", tt.delay)
+			time.Sleep(tt.runFor)
+
+			// stop only returns once the spinner goroutine has ended
+			stopped := make(chan struct{})
+			go func() {
+				stop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the spinner didn't stop")
+			}
+
+			got := out.String()
+			if !tt.wantShow {
+				if got != "" {
+					t.Errorf("output = %q, want nothing before the delay", got)
+				}
+				return
+			}
+			if !strings.HasPrefix(got, "\r| Searching files...") || !strings.Contains(got, "\r/ Searching files...") {
+				t.Errorf("output = %q, want spinner frames", got)
+			}
+			if clear := "\r" + strings.Repeat(" ", len("Searching files...")+2) + "\r"; !strings.HasSuffix(got, clear) {
+				t.Errorf("output = %q, want it to end by clearing the line", got)
+			}
+			time.Sleep(150 * time.Millisecond)
+			if out.String() != got {
+				t.Errorf("the spinner wrote %q after it was stopped", strings.TrimPrefix(out.String(), got))
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReflogMatch is a reflog entry of HEAD whose message matched a search
+type ReflogMatch struct {
+	Hash     string `json:"hash"`
+	Selector string `json:"selector"`
+	Message  string `json:"message"`
+}
+
+// searchInReflog searches the messages of the HEAD reflog, such as
+// "checkout: moving from main to fix" or "reset: moving to HEAD~1", which
+// also finds commits no longer reachable from any branch
+func (g *GitSearchTool) searchInReflog(opts SearchOptions) ([]ReflogMatch, error) {
+	opts.MaxResults = opts.limit(g.maxCommits)
+	cmd := g.gitCommand("reflog", "show", logEncoding, "--format=%h%x1f%gd%x1f%gs%x1e")
+
+	output, err := g.run(cmd)
+	if err != nil {
+		// Without any commits HEAD has no reflog yet
+		if g.noCommitsYet() {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read reflog: %v", err)
+	}
+
+	var matches []ReflogMatch
+	for _, record := range strings.Split(toUTF8(string(output)), "\x1e") {
+		parts := strings.SplitN(strings.TrimSpace(record), "\x1f", 3)
+		if len(parts) < 3 || !g.matchesTerms(parts[2], opts) {
+			continue
+		}
+
+		matches = append(matches, ReflogMatch{Hash: parts[0], Selector: parts[1], Message: parts[2]})
+		if len(matches) == opts.MaxResults {
+			break
+		}
+	}
+
+	return matches, nil
+}
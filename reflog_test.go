@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchInReflog(t *testing.T) {
+	r := newTestRepo(t)
+	added := r.commit("Add parser")
+	lost := r.commit("Lost parser fix")
+	r.git("reset", "-q", "--hard", "HEAD~1")
+	r.git("checkout", "-q", "-b", "fix")
+	r.git("checkout", "-q", "main")
+	short := func(hash string) string {
+		return strings.TrimSpace(r.git("rev-parse", "--short", hash))
+	}
+
+	tests := []struct {
+		query string
+		want  []ReflogMatch
+	}{
+		// The lost commit is only reachable from the reflog
+		{"parser", []ReflogMatch{
+			{Hash: short(lost), Selector: "HEAD@{3}", Message: "commit: Lost parser fix"},
+			{Hash: short(added), Selector: "HEAD@{4}", Message: "commit (initial): Add parser"},
+		}},
+		{"reset", []ReflogMatch{
+			{Hash: short(added), Selector: "HEAD@{2}", Message: "reset: moving to HEAD~1"},
+		}},
+		{"moving from main", []ReflogMatch{
+			{Hash: short(added), Selector: "HEAD@{1}", Message: "checkout: moving from main to fix"},
+		}},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := r.tool().searchInReflog(SearchOptions{Query: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchInReflog() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	stdout, stderr, status := r.gst("-no-banner", "-search-reflog", "-query", "lost")
+	if status != exitMatch || !strings.Contains(stdout, "--- Reflog ---") || !strings.Contains(stdout, "HEAD@{3}") {
+		t.Errorf("-search-reflog: exit status %d, output:\n%s\nstderr:\n%s", status, stdout, stderr)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileRename is a rename of the -follow file by a commit
+type FileRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// followedRenames returns the renames of the -follow file over its history
+// by the hash of the commit making each, from one git log --follow of the
+// revisions the commit search walks
+func (g *GitSearchTool) followedRenames() (map[string]FileRename, error) {
+	cmd := g.gitCommand("log", "-z", "--follow", "--name-status", "--diff-filter=R", "--format=%x1e%H")
+	if g.allBranches {
+		cmd.Args = append(cmd.Args, "--all")
+	} else if g.revRange != "" {
+		cmd.Args = append(cmd.Args, g.revRange)
+	}
+	cmd.Args = append(cmd.Args, "--", g.follow)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the renames of %s: %v", g.follow, err)
+	}
+	return parseRenames(toUTF8(string(output))), nil
+}
+
+// parseRenames reads the records of git log -z --name-status with a
+// format of %x1e%H: the hash, then a status, source and destination path
+// for each rename
+func parseRenames(output string) map[string]FileRename {
+	renames := make(map[string]FileRename)
+	for _, record := range strings.Split(output, "\x1e") {
+		fields := strings.Split(record, "\x00")
+		hash := strings.TrimSpace(fields[0])
+		for i := 1; i+2 < len(fields); i += 3 {
+			if status := strings.TrimSpace(fields[i]); strings.HasPrefix(status, "R") {
+				renames[hash] = FileRename{From: fields[i+1], To: fields[i+2]}
+				break
+			}
+		}
+	}
+	return renames
+}
+
+// addRenames marks the commits that renamed the -follow file, with one git
+// log for all of them
+func (g *GitSearchTool) addRenames(commits []CommitMatch) error {
+	if len(commits) == 0 {
+		return nil
+	}
+	renames, err := g.followedRenames()
+	if err != nil {
+		return err
+	}
+	for i := range commits {
+		if rename, ok := renames[commits[i].Hash]; ok {
+			commits[i].Rename = &rename
+		}
+	}
+	return nil
+}
+
+// printRename prints the rename of the -follow file made by a commit under
+// it
+func (g *GitSearchTool) printRename(commit CommitMatch) {
+	if commit.Rename != nil {
+		fmt.Printf("   renamed %s to %s\n", commit.Rename.From, commit.Rename.To)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRenames(t *testing.T) {
+	output := "\x1eaaa\x00\nR100\x00api/client.go\x00internal/api/client.go\x00" +
+		"\x1ebbb\x00\nR087\x00client.go\x00api/client.go\x00" +
+		"\x1eccc\x00\nM\x00main.go\x00"
+	want := map[string]FileRename{
+		"aaa": {From: "api/client.go", To: "internal/api/client.go"},
+		"bbb": {From: "client.go", To: "api/client.go"},
+	}
+	if got := parseRenames(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRenames() = %+v, want %+v", got, want)
+	}
+	if got := parseRenames(""); len(got) != 0 {
+		t.Errorf("parseRenames(\"\") = %+v, want none", got)
+	}
+}
+
+func TestShowRenames(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add client timeout", "client.go", "package api\n\nconst timeout = 5\n")
+	r.git("mv", "client.go", "api client.go")
+	first := r.commit("Move client timeout into a folder")
+	r.git("mv", "api client.go", "internal.go")
+	second := r.commit("Move client timeout again")
+	r.commit("Raise client timeout", "internal.go", "package api\n\nconst timeout = 10\n")
+
+	stdout, stderr, status := r.gst("-quiet", "-follow", "internal.go", "-show-renames-in-history", "-query", "timeout")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	for _, want := range []string{
+		"Move client timeout again - Test User (2024-01-01)\n   renamed api client.go to internal.go\n",
+		"Move client timeout into a folder - Test User (2024-01-01)\n   renamed client.go to api client.go\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output is missing %q:\n%s", want, stdout)
+		}
+	}
+	if n := strings.Count(stdout, "renamed "); n != 2 {
+		t.Errorf("%d renames marked, want 2:\n%s", n, stdout)
+	}
+
+	stdout, stderr, status = r.gst("-format", "json", "-follow", "internal.go", "-show-renames-in-history", "-query", "timeout")
+	if status != exitMatch {
+		t.Fatalf("-format json: exit status %d; stderr:\n%s", status, stderr)
+	}
+	var results SearchResults
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatal(err)
+	}
+	renames := make(map[string]FileRename)
+	for _, commit := range results.Commits {
+		if commit.Rename != nil {
+			renames[commit.Hash] = *commit.Rename
+		}
+	}
+	want := map[string]FileRename{
+		first:  {From: "client.go", To: "api client.go"},
+		second: {From: "api client.go", To: "internal.go"},
+	}
+	if !reflect.DeepEqual(renames, want) {
+		t.Errorf("JSON renames = %+v, want %+v", renames, want)
+	}
+
+	// Without -show-renames-in-history nothing is marked
+	if stdout, _, _ := r.gst("-quiet", "-follow", "internal.go", "-query", "timeout"); strings.Contains(stdout, "renamed ") {
+		t.Errorf("renames marked without -show-renames-in-history:\n%s", stdout)
+	}
+	if _, stderr, status := r.gst("-show-renames-in-history", "-query", "timeout"); status != exitError || !strings.Contains(stderr, "-show-renames-in-history requires -follow") {
+		t.Errorf("without -follow: exit status %d; stderr:\n%s", status, stderr)
+	}
+}
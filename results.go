@@ -0,0 +1,547 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CommitMatch is a commit whose message matched a search
+type CommitMatch struct {
+	Hash    string `json:"hash"`
+	Author  string `json:"author"`
+	Email   string `json:"email,omitempty"`
+	Date    string `json:"date"`
+	Subject string `json:"subject"`
+	Body    string `json:"body,omitempty"`
+
+	// Score is the similarity of the subject to the query with -fuzzy
+	Score float64 `json:"score,omitempty"`
+
+	// Stat lists the files the commit changed and a summary, filled in
+	// with -with-stat
+	Stat []string `json:"stat,omitempty"`
+
+	// Rename is the rename of the -follow file the commit made, filled in
+	// with -show-renames-in-history
+	Rename *FileRename `json:"rename,omitempty"`
+
+	// Snippet is the excerpt of the body around the match with
+	// -body-snippets, and Offsets the rune offsets of the match in it
+	Snippet string `json:"snippet,omitempty"`
+	Offsets []int  `json:"offsets,omitempty"`
+
+	// Explain says which field made the commit match, with -explain
+	Explain string `json:"explain,omitempty"`
+}
+
+// FileMatch is a line of a tracked file that matched a search
+type FileMatch struct {
+	Path       string `json:"file"`
+	LineNumber int    `json:"line"`
+	Content    string `json:"text"`
+
+	// Tree is the tree-ish the file was searched in, with -at or in a bare
+	// repository
+	Tree string `json:"tree,omitempty"`
+
+	// BlameCommit and BlameAuthor are the last change of the line, filled
+	// in with -blame
+	BlameCommit string `json:"blame_commit,omitempty"`
+	BlameAuthor string `json:"blame_author,omitempty"`
+
+	// Explain says which patterns matched the line, with -explain
+	Explain string `json:"explain,omitempty"`
+}
+
+// CommandLog lists the git commands run so far in a JSON document, filled
+// in with -debug-json so that the document shows how it was produced
+type CommandLog struct {
+	Commands [][]string `json:"commands,omitempty"`
+}
+
+// commandLog returns the git commands recorded for -debug-json
+func (g *GitSearchTool) commandLog() CommandLog {
+	if !g.recordCommands {
+		return CommandLog{}
+	}
+	g.recorder.mu.Lock()
+	defer g.recorder.mu.Unlock()
+	return CommandLog{Commands: append([][]string{}, g.recorder.commands...)}
+}
+
+// SearchResults is the JSON document written for a search
+type SearchResults struct {
+	Query      string        `json:"query,omitempty"`
+	Queries    []string      `json:"queries,omitempty"`
+	Match      string        `json:"match,omitempty"`
+	Invert     bool          `json:"invert,omitempty"`
+	Expression string        `json:"expression,omitempty"`
+	Fallback   string        `json:"fallback,omitempty"`
+	Commits    []CommitMatch `json:"commits"`
+	Changes    []CommitMatch `json:"changes,omitempty"`
+	Tags       []TagMatch    `json:"tags,omitempty"`
+	Stashes    []StashMatch  `json:"stashes,omitempty"`
+	Notes      []NoteMatch   `json:"notes,omitempty"`
+	Reflog     []ReflogMatch `json:"reflog,omitempty"`
+	Files      []FileMatch   `json:"files"`
+	Paths      []string      `json:"paths,omitempty"`
+	Counts     []FileCount   `json:"counts,omitempty"`
+	Tree       *TreeNode     `json:"tree,omitempty"`
+	Suppressed int           `json:"suppressed,omitempty"`
+
+	// CommitCount counts the commits and changes, FileMatchCount the file
+	// matches and FileCount the distinct files they are in
+	CommitCount    int `json:"commit_count"`
+	FileMatchCount int `json:"file_match_count"`
+	FileCount      int `json:"file_count"`
+
+	CommandLog
+}
+
+// collectSearchResults runs the searches of performSearch without printing,
+// using the same limits and fallback
+func (g *GitSearchTool) collectSearchResults(query string) (SearchResults, error) {
+	results := SearchResults{
+		Query:      query,
+		Expression: g.fileExpr,
+		Invert:     g.invert,
+		Commits:    []CommitMatch{},
+		Files:      []FileMatch{},
+	}
+	if len(g.extraQueries) > 0 {
+		results.Queries = g.queryTerms(query)
+		results.Match = "any"
+		if g.matchAll {
+			results.Match = "all"
+		}
+	}
+
+	// Files are grepped while the commit history is searched; -tree,
+	// -files-only and -count grep files their own way
+	var files func() ([]FileMatch, error)
+	if !g.tree && !g.filesOnly && !g.countOnly {
+		files = g.startFileSearch(query)
+		defer files()
+	}
+
+	if query != "" && !g.headOnly && !g.noIndex {
+		seen := make(map[string]bool)
+		commits, err := g.searchInCommitHistory(g.searchOptions(query))
+		if err == nil && len(commits) == 0 && g.fallback != "" {
+			commits, err = g.searchFallbackCommits()
+			results.Fallback = g.fallback
+		}
+		if err != nil {
+			return results, err
+		}
+		results.Commits = append(results.Commits, dropSeen(commits[:g.allowResults(len(commits))], seen)...)
+		for i := range results.Commits {
+			g.annotateCommit(&results.Commits[i], query)
+		}
+		if g.withStat {
+			if err := g.addStats(results.Commits); err != nil {
+				return results, err
+			}
+		}
+		if g.showRenames {
+			if err := g.addRenames(results.Commits); err != nil {
+				return results, err
+			}
+		}
+
+		if g.diffSearch {
+			changes, err := g.searchInDiffs(g.searchOptions(query))
+			if err != nil {
+				return results, err
+			}
+			changes = dropSeen(changes, seen)
+			results.Changes = append([]CommitMatch{}, changes[:g.allowResults(len(changes))]...)
+			if g.withStat {
+				if err := g.addStats(results.Changes); err != nil {
+					return results, err
+				}
+			}
+			if g.showRenames {
+				if err := g.addRenames(results.Changes); err != nil {
+					return results, err
+				}
+			}
+		}
+
+		if g.searchTags {
+			tags, err := g.searchInTags(g.searchOptions(query))
+			if err != nil {
+				return results, err
+			}
+			results.Tags = append([]TagMatch{}, tags[:g.allowResults(len(tags))]...)
+		}
+
+		if g.searchStashes {
+			stashes, err := g.searchInStashes(g.searchOptions(query), g.diffSearch)
+			if err != nil {
+				return results, err
+			}
+			results.Stashes = append([]StashMatch{}, stashes[:g.allowResults(len(stashes))]...)
+		}
+
+		if g.searchNotes {
+			notes, err := g.searchInNotes(g.searchOptions(query))
+			if err != nil {
+				return results, err
+			}
+			results.Notes = append([]NoteMatch{}, notes[:g.allowResults(len(notes))]...)
+		}
+
+		if g.searchReflog {
+			entries, err := g.searchInReflog(g.searchOptions(query))
+			if err != nil {
+				return results, err
+			}
+			results.Reflog = append([]ReflogMatch{}, entries[:g.allowResults(len(entries))]...)
+		}
+	}
+
+	treeMatches, treeFiles := 0, 0
+	if g.tree {
+		counts, err := g.countFileMatches(query)
+		if err != nil {
+			return results, err
+		}
+		g.emitted += len(counts)
+		node := buildPathTree(counts).node()
+		node.Name = g.pathPrefix + "."
+		results.Tree = &node
+		treeMatches, treeFiles = node.Matches, len(counts)
+	} else if g.filesOnly {
+		paths, err := g.listMatchingFiles(query)
+		if err != nil {
+			return results, err
+		}
+		results.Paths = append([]string{}, paths[:g.allowResults(min(len(paths), g.maxFiles))]...)
+	} else if g.countOnly {
+		counts, err := g.fileCounts(query)
+		if err != nil {
+			return results, err
+		}
+		results.Counts = append([]FileCount{}, counts[:g.allowResults(min(len(counts), g.maxFiles))]...)
+	} else {
+		matches, err := files()
+		if err == nil && len(matches) == 0 && g.fallback != "" {
+			matches, err = g.searchFallbackFiles()
+			results.Fallback = g.fallback
+		}
+		if err != nil {
+			return results, err
+		}
+		results.Files = append(results.Files, matches[:g.allowResults(len(matches))]...)
+		if g.explain {
+			for i := range results.Files {
+				results.Files[i].Explain = g.explainFileMatch(results.Files[i].Content, query)
+			}
+		}
+		if g.blame {
+			if err := g.addBlame(results.Files); err != nil {
+				return results, err
+			}
+		}
+	}
+
+	results.Suppressed = g.suppressed
+	results.CommitCount = len(results.Commits) + len(results.Changes)
+	results.FileMatchCount = len(results.Files) + treeMatches
+	results.FileCount = countFiles(results.Files) + len(results.Paths) + len(results.Counts) + treeFiles
+	return results, nil
+}
+
+// annotateCommit fills in the body snippet of a commit that matched query
+// outside its subject with -body-snippets, and why it matched with -explain
+func (g *GitSearchTool) annotateCommit(commit *CommitMatch, query string) {
+	if g.bodySnippets && !containsCase(commit.Subject, query, g.commitsCaseSensitive()) {
+		if excerpt, start, end, ok := bodyExcerpt(commit.Body, query, snippetContext, g.commitsCaseSensitive()); ok {
+			commit.Snippet, commit.Offsets = excerpt, []int{start, end}
+		}
+	}
+	if g.explain {
+		commit.Explain = explainCommit(*commit, query)
+	}
+}
+
+// dropSeen returns the commits whose hash isn't in seen, adding their hashes
+// to it, so that a commit matching several sections is only listed once
+func dropSeen(commits []CommitMatch, seen map[string]bool) []CommitMatch {
+	var unseen []CommitMatch
+	for _, commit := range commits {
+		if !seen[commit.Hash] {
+			seen[commit.Hash] = true
+			unseen = append(unseen, commit)
+		}
+	}
+	return unseen
+}
+
+// countFiles returns the number of distinct files among matches
+func countFiles(matches []FileMatch) int {
+	files := make(map[string]bool)
+	for _, match := range matches {
+		files[match.Path] = true
+	}
+	return len(files)
+}
+
+// writeSearchJSON writes the results of a search to stdout as a JSON document
+func (g *GitSearchTool) writeSearchJSON(query string) {
+	results, err := g.collectSearchResults(query)
+	if err != nil {
+		g.searchErrorf("Error searching: %v", err)
+		return
+	}
+	results.CommandLog = g.commandLog()
+	g.writeJSON(results)
+}
+
+// writeJSON writes v to stdout as a JSON document, indented with
+// -json-pretty
+func (g *GitSearchTool) writeJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	if g.jsonPretty {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		g.searchErrorf("Error writing JSON: %v", err)
+	}
+}
+
+// writeSearchCSV writes the results of a search to stdout as two CSV tables
+// separated by a blank line, the commits (hash, author, date, subject) and
+// the file matches (file, line, content), each with a header row
+func (g *GitSearchTool) writeSearchCSV(query string) {
+	results, err := g.collectSearchResults(query)
+	if err != nil {
+		g.searchErrorf("Error searching: %v", err)
+		return
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"hash", "author", "date", "subject"})
+	for _, commit := range append(results.Commits, results.Changes...) {
+		w.Write([]string{commit.Hash, commit.Author, commit.Date, commit.Subject})
+	}
+	w.Flush()
+	fmt.Println()
+
+	w.Write([]string{"file", "line", "content"})
+	for _, match := range results.Files {
+		w.Write([]string{match.Path, strconv.Itoa(match.LineNumber), match.Content})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		g.searchErrorf("Error writing CSV: %v", err)
+	}
+}
+
+// parseSeparator returns the -separator given as a single character, or
+// as \t for a tab
+func parseSeparator(s string) (string, bool) {
+	if s == `\t` {
+		return "\t", true
+	}
+	return s, utf8.RuneCountInString(s) == 1
+}
+
+// writeSearchDelimited writes the commits and code changes of a search as
+// hash, author, date and subject and the file matches as path, line and
+// content, one per line with their fields joined by -separator, for cut and
+// awk. Nothing is escaped, so a field can contain the separator.
+func (g *GitSearchTool) writeSearchDelimited(query string) {
+	results, err := g.collectSearchResults(query)
+	if err != nil {
+		g.searchErrorf("Error searching: %v", err)
+		return
+	}
+
+	for _, commit := range append(results.Commits, results.Changes...) {
+		fmt.Println(strings.Join([]string{commit.Hash, commit.Author, commit.Date, commit.Subject}, g.separator))
+	}
+	for _, match := range results.Files {
+		fmt.Println(strings.Join([]string{match.Path, strconv.Itoa(match.LineNumber), match.Content}, g.separator))
+	}
+}
+
+// createOutput creates the -output file, refusing to replace an existing
+// file unless force is set
+func createOutput(path string, force bool) (*os.File, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+
+	f, err := os.OpenFile(path, flags, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("%s already exists, use -force to overwrite it", path)
+	}
+	return f, err
+}
+
+// commitLine, tagLine, stashLine, noteLine, reflogLine and fileLine are the
+// objects of -format jsonl output, one per result, tagged with the kind of
+// result
+type commitLine struct {
+	Type string `json:"type"`
+	CommitMatch
+}
+
+type tagLine struct {
+	Type string `json:"type"`
+	TagMatch
+}
+
+type stashLine struct {
+	Type string `json:"type"`
+	StashMatch
+}
+
+type noteLine struct {
+	Type string `json:"type"`
+	NoteMatch
+}
+
+type reflogLine struct {
+	Type string `json:"type"`
+	ReflogMatch
+}
+
+type fileLine struct {
+	Type string `json:"type"`
+	FileMatch
+}
+
+// streamSearchJSONL writes the results of a search to stdout as JSON Lines.
+// File matches are written while git grep is still running instead of being
+// collected first, so that large result sets aren't held in memory.
+func (g *GitSearchTool) streamSearchJSONL(query string) {
+	enc := json.NewEncoder(os.Stdout)
+	write := func(v any) bool {
+		if err := enc.Encode(v); err != nil {
+			g.searchErrorf("Error writing JSON: %v", err)
+			return false
+		}
+		return true
+	}
+
+	if query != "" && !g.headOnly && !g.noIndex {
+		seen := make(map[string]bool)
+		commits, err := g.searchInCommitHistory(g.searchOptions(query))
+		if err != nil {
+			g.searchErrorf("Error searching commits: %v", err)
+			return
+		}
+		commits = dropSeen(commits[:g.allowResults(len(commits))], seen)
+		if g.withStat {
+			if err := g.addStats(commits); err != nil {
+				g.searchErrorf("Error reading commit changes: %v", err)
+				return
+			}
+		}
+		if g.showRenames {
+			if err := g.addRenames(commits); err != nil {
+				g.searchErrorf("Error reading renames: %v", err)
+				return
+			}
+		}
+		for _, commit := range commits {
+			g.annotateCommit(&commit, query)
+			write(commitLine{"commit", commit})
+		}
+
+		if g.diffSearch {
+			changes, err := g.searchInDiffs(g.searchOptions(query))
+			if err != nil {
+				g.searchErrorf("Error searching code changes: %v", err)
+				return
+			}
+			changes = dropSeen(changes, seen)
+			changes = changes[:g.allowResults(len(changes))]
+			if g.withStat {
+				if err := g.addStats(changes); err != nil {
+					g.searchErrorf("Error reading commit changes: %v", err)
+					return
+				}
+			}
+			if g.showRenames {
+				if err := g.addRenames(changes); err != nil {
+					g.searchErrorf("Error reading renames: %v", err)
+					return
+				}
+			}
+			for _, commit := range changes {
+				write(commitLine{"change", commit})
+			}
+		}
+
+		if g.searchTags {
+			tags, err := g.searchInTags(g.searchOptions(query))
+			if err != nil {
+				g.searchErrorf("Error searching tags: %v", err)
+				return
+			}
+			for _, tag := range tags[:g.allowResults(len(tags))] {
+				write(tagLine{"tag", tag})
+			}
+		}
+
+		if g.searchStashes {
+			stashes, err := g.searchInStashes(g.searchOptions(query), g.diffSearch)
+			if err != nil {
+				g.searchErrorf("Error searching stashes: %v", err)
+				return
+			}
+			for _, stash := range stashes[:g.allowResults(len(stashes))] {
+				write(stashLine{"stash", stash})
+			}
+		}
+
+		if g.searchNotes {
+			notes, err := g.searchInNotes(g.searchOptions(query))
+			if err != nil {
+				g.searchErrorf("Error searching notes: %v", err)
+				return
+			}
+			for _, note := range notes[:g.allowResults(len(notes))] {
+				write(noteLine{"note", note})
+			}
+		}
+
+		if g.searchReflog {
+			entries, err := g.searchInReflog(g.searchOptions(query))
+			if err != nil {
+				g.searchErrorf("Error searching reflog: %v", err)
+				return
+			}
+			for _, entry := range entries[:g.allowResults(len(entries))] {
+				write(reflogLine{"reflog", entry})
+			}
+		}
+	}
+
+	streamed := 0
+	err := g.streamFiles(g.grepArgs(g.fileSearchOptions(query), "-n", "-z"), func(match FileMatch) bool {
+		if g.explain {
+			match.Explain = g.explainFileMatch(match.Content, query)
+		}
+		if g.allowResults(1) == 0 || !write(fileLine{"file", match}) {
+			return false
+		}
+		streamed++
+		return streamed < g.maxFiles
+	})
+	if err != nil {
+		g.searchErrorf("Error searching files: %v", err)
+	}
+}
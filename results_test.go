@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOutputFile(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser", "parser.go", "parser\nparser again\n")
+
+	plain, _, status := r.gst("-query", "parser")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d", status, exitMatch)
+	}
+
+	path := filepath.Join(t.TempDir(), "results.txt")
+	stdout, stderr, status := r.gst("-query", "parser", "-output", path)
+	if status != exitMatch {
+		t.Fatalf("-output: exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := string(data)
+
+	// The file has the results as they were printed to stdout, and the
+	// banner and status lines around them go to stderr instead
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing", stdout)
+	}
+	if !strings.Contains(results, "1. parser.go:1:parser") || !strings.Contains(plain, results) {
+		t.Errorf("file content:\n%s\nisn't the results printed without -output:\n%s", results, plain)
+	}
+	for _, banner := range []string{"Git repository: " + r.dir, "=== Last Commit Information ===", "Subject: Add parser", "Goodbye!"} {
+		if !strings.Contains(stderr, banner) {
+			t.Errorf("stderr is missing %q:\n%s", banner, stderr)
+		}
+		if strings.Contains(results, banner) {
+			t.Errorf("file has banner line %q", banner)
+		}
+	}
+	if got, want := strings.Replace(plain, results, "", 1), stderr; got != want {
+		t.Errorf("stdout without -output, less the file content = %q, want the stderr of -output %q", got, want)
+	}
+
+	// An existing file is only overwritten with -force
+	if _, stderr, status := r.gst("-query", "parser", "-output", path); status != exitError || !strings.Contains(stderr, "already exists, use -force") {
+		t.Errorf("existing output file: exit status %d, stderr:\n%s", status, stderr)
+	}
+	if _, _, status := r.gst("-query", "parser", "-output", path, "-force"); status != exitMatch {
+		t.Errorf("-force: exit status %d, want %d", status, exitMatch)
+	}
+}
+
+func TestFormatJSONL(t *testing.T) {
+	r := newTestRepo(t)
+	var lines strings.Builder
+	for i := range 30 {
+		fmt.Fprintf(&lines, "value %d: \"quoted\"\tand \\ escaped, ünïcode\n", i)
+	}
+	r.commit("Add value table", "values.txt", lines.String())
+
+	stdout, stderr, status := r.gst("-no-banner", "-format", "jsonl", "-max-files", "25", "-query", "value")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+
+	// Every line is a JSON object of its own: the commit, then the file
+	// matches up to -max-files
+	output := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if len(output) != 26 {
+		t.Fatalf("got %d lines, want 1 commit and 25 file matches:\n%s", len(output), stdout)
+	}
+	for i, line := range output {
+		var result struct {
+			Type    string `json:"type"`
+			Subject string `json:"subject"`
+			File    string `json:"file"`
+			Line    int    `json:"line"`
+			Text    string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("line %d isn't valid JSON: %v\n%s", i+1, err, line)
+		}
+		if i == 0 {
+			if result.Type != "commit" || result.Subject != "Add value table" {
+				t.Errorf("line 1 = %+v, want the commit", result)
+			}
+			continue
+		}
+		want := fmt.Sprintf("value %d: \"quoted\"\tand \\ escaped, ünïcode", i-1)
+		if result.Type != "file" || result.File != "values.txt" || result.Line != i || result.Text != want {
+			t.Errorf("line %d = %+v, want values.txt:%d:%q", i+1, result, i, want)
+		}
+	}
+}
+
+func TestDropSeen(t *testing.T) {
+	seen := make(map[string]bool)
+	first := dropSeen([]CommitMatch{{Hash: "aaa", Subject: "A"}, {Hash: "bbb", Subject: "B"}, {Hash: "aaa", Subject: "A"}}, seen)
+	if got, want := commitSubjects(first), []string{"A", "B"}; !slices.Equal(got, want) {
+		t.Errorf("first section = %q, want %q", got, want)
+	}
+	second := dropSeen([]CommitMatch{{Hash: "bbb", Subject: "B"}, {Hash: "ccc", Subject: "C"}}, seen)
+	if got, want := commitSubjects(second), []string{"C"}; !slices.Equal(got, want) {
+		t.Errorf("second section = %q, want %q", got, want)
+	}
+}
+
+func TestCommitsShownOnce(t *testing.T) {
+	r := newTestRepo(t)
+	// Both commits match by message and by change
+	add := r.commit("Add cache", "cache.go", "cache\n")
+	remove := r.commit("Remove cache", "cache.go", "")
+	r.commit("Add eviction", "evict.go", "cache eviction\n")
+
+	stdout, stderr, status := r.gst("-no-banner", "-diff-search", "-query", "cache")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	for _, hash := range []string{add, remove} {
+		if n := strings.Count(stdout, "["+hash[:8]+"]"); n != 1 {
+			t.Errorf("commit %s is listed %d times, want once:\n%s", hash[:8], n, stdout)
+		}
+	}
+	// The change of the third commit is only found by -diff-search
+	if !strings.Contains(stdout, "Add eviction") || !strings.Contains(stdout, "Found 3 commit matches") {
+		t.Errorf("output is missing the changed commit:\n%s", stdout)
+	}
+}
+
+func TestFormatCSV(t *testing.T) {
+	r := newTestRepo(t)
+	hash := r.commitEnv(author("Smith, Alice", "alice@example.com"), `Fix "quoted", comma-separated values`,
+		"data.csv", "id,name\n1,\"Widget, large\"\n2,plain values\n")
+
+	stdout, stderr, status := r.gst("-no-banner", "-format", "csv", "-query", "values")
+	if status != exitMatch {
+		t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	commits, files, ok := strings.Cut(stdout, "\n\n")
+	if !ok {
+		t.Fatalf("output isn't two CSV blocks separated by a blank line:\n%s", stdout)
+	}
+
+	tests := []struct {
+		block string
+		want  [][]string
+	}{
+		{commits, [][]string{
+			{"hash", "author", "date", "subject"},
+			{hash, "Smith, Alice", "2024-01-01", `Fix "quoted", comma-separated values`},
+		}},
+		{files, [][]string{
+			{"file", "line", "content"},
+			{"data.csv", "3", "2,plain values"},
+		}},
+	}
+	for _, tt := range tests {
+		records, err := csv.NewReader(strings.NewReader(tt.block)).ReadAll()
+		if err != nil {
+			t.Errorf("parsing CSV %q: %v", tt.block, err)
+			continue
+		}
+		if !reflect.DeepEqual(records, tt.want) {
+			t.Errorf("records = %q, want %q", records, tt.want)
+		}
+	}
+
+	// Quotes and commas in file content are escaped too
+	stdout, _, _ = r.gst("-no-banner", "-head-only", "-format", "csv", "-query", "Widget")
+	if want := `data.csv,2,"1,""Widget, large"""` + "\n"; !strings.HasSuffix(stdout, want) {
+		t.Errorf("file match isn't quoted:\n%s", stdout)
+	}
+}
+
+func TestJSONPretty(t *testing.T) {
+	r := newTestRepo(t)
+	r.commitEnv(author("Alice Smith", "alice@example.com"), "Add parser\n\nHandles \"quoted\" input", "parser.go", "func parse() {}\n// parse <input> & more\n")
+	r.commitEnv(author("Bob Jones", "bob@example.org"), "Fix parser crash", "parser.go", "func parse() { check() }\n// parse <input> & more\n")
+
+	tests := []struct {
+		golden string
+		args   []string
+	}{
+		{"search.json", nil},
+		{"search_pretty.json", []string{"-json-pretty"}},
+	}
+	outputs := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			stdout, stderr, status := r.gst(append([]string{"-format", "json", "-query", "parse"}, tt.args...)...)
+			if status != exitMatch {
+				t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+			}
+			want, err := os.ReadFile(filepath.Join("testdata", tt.golden))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stdout != string(want) {
+				t.Errorf("output differs from testdata/%s:\n%s", tt.golden, stdout)
+			}
+			outputs[tt.golden] = stdout
+		})
+	}
+
+	// Both are the same document
+	var compact, pretty SearchResults
+	if err := json.Unmarshal([]byte(outputs["search.json"]), &compact); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(outputs["search_pretty.json"]), &pretty); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(compact, pretty) {
+		t.Errorf("compact and pretty output differ:\n%+v\n%+v", compact, pretty)
+	}
+}
+
+func TestParseSeparator(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{`\t`, "\t", true},
+		{"|", "|", true},
+		{"§", "§", true},
+		{"", "", false},
+		{"||", "||", false},
+		{`\n`, `\n`, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseSeparator(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("parseSeparator(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSeparator(t *testing.T) {
+	r := newTestRepo(t)
+	hash := r.commitEnv(author("Alice Smith", "alice@example.com"), "Add parser", "parser.go", "package parser\n\nfunc parse() {}\n")
+
+	tests := []struct {
+		separator string
+		want      string
+		warns     bool
+	}{
+		{`\t`, hash + "\tAlice Smith\t2024-01-01\tAdd parser\nparser.go\t1\tpackage parser\nparser.go\t3\tfunc parse() {}\n", false},
+		{"|", hash + "|Alice Smith|2024-01-01|Add parser\nparser.go|1|package parser\nparser.go|3|func parse() {}\n", false},
+		{",", hash + ",Alice Smith,2024-01-01,Add parser\nparser.go,1,package parser\nparser.go,3,func parse() {}\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.separator, func(t *testing.T) {
+			stdout, stderr, status := r.gst("-separator", tt.separator, "-query", "parse")
+			if status != exitMatch {
+				t.Fatalf("exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+			}
+			if stdout != tt.want {
+				t.Errorf("output = %q, want %q", stdout, tt.want)
+			}
+			if warned := stderr != ""; warned != tt.warns {
+				t.Errorf("warned %v, want %v; stderr:\n%s", warned, tt.warns, stderr)
+			}
+		})
+	}
+
+	for _, args := range [][]string{{"-separator", "::"}, {"-separator", "|", "-format", "json"}} {
+		if _, stderr, status := r.gst(append(args, "-query", "parse")...); status != exitError {
+			t.Errorf("%q: exit status %d, want %d; stderr:\n%s", args, status, exitError, stderr)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// retryDelay is the wait before the first retry of a git command, doubled
+// for every further one
+const retryDelay = 100 * time.Millisecond
+
+// isRetryable reports whether a git command failed for a reason that may go
+// away when it's run again, another git process holding a lock on a busy or
+// network mounted repository. Other failures, such as git grep exiting with
+// 1 for no matches, are never retried.
+func isRetryable(err error) bool {
+	var exitError *exec.ExitError
+	if !errors.As(err, &exitError) || exitError.ExitCode() != 128 {
+		return false
+	}
+	stderr := string(exitError.Stderr)
+	return strings.Contains(stderr, ".lock") || strings.Contains(stderr, "Unable to create") ||
+		strings.Contains(stderr, "another git process")
+}
+
+// retryCommand waits before the given retry of cmd, doubling the wait each
+// time, and returns a copy of cmd to run again, or nil when its input can't
+// be replayed or the search has timed out
+func (g *GitSearchTool) retryCommand(cmd *exec.Cmd, attempt int) *exec.Cmd {
+	if cmd.Stdin != nil {
+		seeker, ok := cmd.Stdin.(io.Seeker)
+		if !ok {
+			return nil
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return nil
+		}
+	}
+
+	time.Sleep(retryDelay << attempt)
+	if g.timedOut() {
+		return nil
+	}
+	retry := g.gitCommand(cmd.Args[1:]...)
+	retry.Stdin = cmd.Stdin
+	return retry
+}
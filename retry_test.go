@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIsRetryable(t *testing.T) {
+	if isRetryable(nil) || isRetryable(errors.New("fatal: Unable to create index.lock")) {
+		t.Error("isRetryable() is true for an error that isn't a git exit")
+	}
+}
+
+func TestRetries(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add lock handling", "lock.go", "package lock\n")
+
+	tests := []struct {
+		name     string
+		failures int
+		message  string
+		retries  string
+		status   int
+		logRuns  int
+	}{
+		{"no failure", 0, "", "2", exitMatch, 1},
+		{"lock released", 2, "fatal: Unable to create '.git/index.lock': File exists.", "2", exitMatch, 3},
+		{"another git process", 1, "error: another git process seems to be running", "1", exitMatch, 2},
+		{"lock held too long", 3, "fatal: Unable to create '.git/index.lock': File exists.", "2", exitError, 3},
+		{"retries off", 1, "fatal: Unable to create '.git/index.lock': File exists.", "0", exitError, 1},
+		{"other failure", 1, "fatal: bad object HEAD", "2", exitError, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := filepath.Join(t.TempDir(), "runs")
+			// git log fails with the message for the first runs, then works
+			flaky := fakeGit(t, `if [ "$1" = log ]; then
+	echo log >> `+shellQuote([]string{runs})+`
+	if [ "$(wc -l < `+shellQuote([]string{runs})+`)" -le `+strconv.Itoa(tt.failures)+` ]; then
+		echo `+shellQuote([]string{tt.message})+` >&2
+		exit 128
+	fi
+fi
+exec "$realGit" "$@"
+`)
+
+			stdout, stderr, status := r.gst("-quiet", "-git-bin", flaky, "-retries", tt.retries, "-query", "lock")
+			if status != tt.status {
+				t.Fatalf("exit status %d, want %d; stderr:\n%s", status, tt.status, stderr)
+			}
+			if status == exitMatch && !strings.Contains(stdout, "Add lock handling") {
+				t.Errorf("output is missing the commit:\n%s", stdout)
+			}
+			data, err := os.ReadFile(runs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Count(string(data), "log\n"); got != tt.logRuns {
+				t.Errorf("git log ran %d times, want %d", got, tt.logRuns)
+			}
+		})
+	}
+}
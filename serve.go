@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// serveAddr completes a -serve address, listening on localhost unless
+// another host is named
+func serveAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// A bare port such as 8080
+		host, port = "", addr
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// isLoopback reports whether a -serve address only accepts connections from
+// this host
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return host == "localhost" || (ip != nil && ip.IsLoopback())
+}
+
+// serve answers GET /search requests with the JSON results of a search
+// until the server fails
+func (g *GitSearchTool) serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /search", g.handleSearch)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	g.statusf("Serving searches of %s on http://%s/search\n", g.repoPath, addr)
+	return server.ListenAndServe()
+}
+
+// handleSearch runs the search of a request on a copy of the tool, so that
+// requests don't share their limits and counts, and writes the results as
+// the SearchResults document of -format json
+func (g *GitSearchTool) handleSearch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	queries := params["q"]
+	if len(queries) == 0 || queries[0] == "" {
+		writeHTTPError(w, http.StatusBadRequest, "missing q parameter")
+		return
+	}
+
+	// A copy would keep the deadline of the command line's timeout, which
+	// started when the server did; each request gets its own instead, set
+	// before any git command runs, and is canceled when the client goes away
+	ctx := r.Context()
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	s := g.forRepo(g.repoPath)
+	s.ctx = ctx
+	if repo := params.Get("repo"); repo != "" {
+		path, err := filepath.Abs(repo)
+		if err != nil {
+			writeHTTPError(w, http.StatusBadRequest, fmt.Sprintf("invalid repo %q: %v", repo, err))
+			return
+		}
+		// The search path of the command line is within its own repository
+		s = g.forRepo(path)
+		s.ctx = ctx
+		s.pathspecs = nil
+		s.findWorkTreeRoot()
+	}
+	if !s.isGitRepo() {
+		writeHTTPError(w, http.StatusBadRequest, fmt.Sprintf("not a git repository: %s", s.repoPath))
+		return
+	}
+	if err := s.applySearchParams(params); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.extraQueries = queries[1:]
+
+	s.shallow = s.isShallowRepository()
+	specs, err := readIgnoreFile(filepath.Join(s.repoPath, ignoreFileName))
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("reading %s: %v", ignoreFileName, err))
+		return
+	}
+	s.ignoreSpecs = specs
+
+	results, err := s.collectSearchResults(queries[0])
+	switch {
+	case err != nil && s.timedOut():
+		writeHTTPError(w, http.StatusGatewayTimeout, err.Error())
+		return
+	case err != nil:
+		writeHTTPError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		errorf("Error writing search response: %v", err)
+	}
+}
+
+// applySearchParams sets the search options given as request parameters,
+// on top of those of the command line
+func (g *GitSearchTool) applySearchParams(params url.Values) error {
+	for name, limit := range map[string]*int{"max_commits": &g.maxCommits, "max_files": &g.maxFiles, "max_results": &g.maxResults} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s %q, expected a number", name, v)
+			}
+			*limit = n
+		}
+	}
+	for name, option := range map[string]*bool{"case_sensitive": &g.caseSensitive, "regex": &g.regex, "word": &g.wholeWord, "all_branches": &g.allBranches} {
+		if v := params.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q, expected true or false", name, v)
+			}
+			*option = b
+		}
+	}
+	for name, filter := range map[string]*string{"author": &g.author, "since": &g.since, "until": &g.until} {
+		if params.Has(name) {
+			*filter = params.Get(name)
+		}
+	}
+	if filters := params["path"]; len(filters) > 0 {
+		g.pathFilters = filters
+	}
+	if v := params.Get("match"); v != "" {
+		if v != "any" && v != "all" {
+			return fmt.Errorf("invalid match %q, expected any or all", v)
+		}
+		g.matchAll = v == "all"
+	}
+	return nil
+}
+
+// writeHTTPError answers a request with an error status and a JSON document
+// with the message
+func writeHTTPError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
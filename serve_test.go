@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHandleSearch(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser", "cmd/parser.go", "func parse() {}\n")
+	other := newTestRepo(t)
+	other.commit("Add lexer", "lexer.go", "func lex() {}\n")
+	bare := filepath.Join(testDir(t), "bare.git")
+	r.git("clone", "-q", "--bare", other.dir, bare)
+
+	g := r.tool()
+	g.timeout = 5 * time.Second
+	// The -timeout of the command line started with the server, and has
+	// long passed when the requests come in
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	g.ctx = ctx
+
+	tests := []struct {
+		name    string
+		params  url.Values
+		status  int
+		subject string
+	}{
+		{"repository of the command line", url.Values{"q": {"parse"}}, http.StatusOK, "Add parser"},
+		{"other repository", url.Values{"q": {"lex"}, "repo": {other.dir}}, http.StatusOK, "Add lexer"},
+		{"subdirectory", url.Values{"q": {"parse"}, "repo": {filepath.Join(r.dir, "cmd")}}, http.StatusOK, "Add parser"},
+		{"bare repository", url.Values{"q": {"lex"}, "repo": {bare}}, http.StatusOK, "Add lexer"},
+		{"not a repository", url.Values{"q": {"lex"}, "repo": {t.TempDir()}}, http.StatusBadRequest, ""},
+		{"missing query", url.Values{"repo": {other.dir}}, http.StatusBadRequest, ""},
+		{"invalid parameter", url.Values{"q": {"lex"}, "max_files": {"many"}}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			g.handleSearch(rec, httptest.NewRequest(http.MethodGet, "/search?"+tt.params.Encode(), nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d; body:\n%s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				var body struct{ Error string }
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error == "" {
+					t.Errorf("error body %q isn't a JSON error: %v", rec.Body, err)
+				}
+				return
+			}
+			var results SearchResults
+			if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+				t.Fatal(err)
+			}
+			if len(results.Commits) != 1 || results.Commits[0].Subject != tt.subject {
+				t.Errorf("commits = %+v, want %q", results.Commits, tt.subject)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// snippetContext is the number of runes shown either side of a body match
+const snippetContext = 40
+
+// indexRunes returns the rune offsets of the first occurrence of substr in
+// s, ignoring case unless caseSensitive is set, or -1, -1 if there is none
+func indexRunes(s, substr string, caseSensitive bool) (start, end int) {
+	haystack := []rune(s)
+	needle := []rune(substr)
+	if len(needle) == 0 {
+		return -1, -1
+	}
+
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		matched := true
+		for j, r := range needle {
+			if c := haystack[i+j]; c != r && (caseSensitive || unicode.ToLower(c) != unicode.ToLower(r)) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return i, i + len(needle)
+		}
+	}
+
+	return -1, -1
+}
+
+// bodyExcerpt extracts a single-line excerpt of a commit body around the
+// first occurrence of the query, in the case mode of the search, returning
+// it with the rune offsets of the match within it
+func bodyExcerpt(body, query string, context int, caseSensitive bool) (excerpt string, start, end int, ok bool) {
+	text := []rune(strings.Join(strings.Fields(body), " "))
+	start, end = indexRunes(string(text), query, caseSensitive)
+	if start < 0 {
+		return "", 0, 0, false
+	}
+
+	from := start - context
+	if from < 0 {
+		from = 0
+	}
+	to := end + context
+	if to > len(text) {
+		to = len(text)
+	}
+
+	excerpt = string(text[from:to])
+	start, end = start-from, end-from
+	if from > 0 {
+		excerpt = "..." + excerpt
+		start, end = start+3, end+3
+	}
+	if to < len(text) {
+		excerpt += "..."
+	}
+	return excerpt, start, end, true
+}
+
+// bodySnippet extracts a single-line excerpt of a commit body around the first
+// occurrence of the query, with the match highlighted
+func bodySnippet(body, query string, context int, caseSensitive bool) (string, bool) {
+	excerpt, start, end, ok := bodyExcerpt(body, query, context, caseSensitive)
+	if !ok {
+		return "", false
+	}
+	text := []rune(excerpt)
+	return string(text[:start]) + highlight(string(text[start:end])) + string(text[end:]), true
+}
+
+// truncateAround shortens text to at most width runes, keeping the first
+// match of the patterns in view by centering the window on it, and marks the
+// cut ends with "...". Cutting at rune boundaries keeps multibyte characters
+// whole.
+func truncateAround(text string, patterns []string, caseSensitive bool, width int) string {
+	runes := []rune(text)
+	if width <= 0 || len(runes) <= width {
+		return text
+	}
+
+	// Rune offsets of the first match, the start of the line without one
+	start, end := 0, 0
+	marked := matchedBytes(text, patterns, caseSensitive)
+	for i := range marked {
+		if marked[i] {
+			j := i
+			for j < len(marked) && marked[j] {
+				j++
+			}
+			start, end = utf8.RuneCountInString(text[:i]), utf8.RuneCountInString(text[:j])
+			break
+		}
+	}
+
+	from := (start+end)/2 - width/2
+	if from > len(runes)-width {
+		from = len(runes) - width
+	}
+	if from < 0 {
+		from = 0
+	}
+
+	truncated := string(runes[from : from+width])
+	if from > 0 {
+		truncated = "..." + truncated
+	}
+	if from+width < len(runes) {
+		truncated += "..."
+	}
+	return truncated
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateAround(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		patterns []string
+		width    int
+		want     string
+	}{
+		{"no limit", "ééééé match ééééé", []string{"match"}, 0, "ééééé match ééééé"},
+		{"short enough", "ééééé match ééééé", []string{"match"}, 17, "ééééé match ééééé"},
+		{"centered on the match", "ééééé match ééééé", []string{"match"}, 9, "...é match é..."},
+		{"match at the end", "αβγδεζηθ end", []string{"end"}, 5, "...θ end"},
+		{"match at the start", "start 日本語のテキスト", []string{"start"}, 7, "start 日..."},
+		{"no match", "日本語のテキスト", []string{"x"}, 4, "日本語の..."},
+		{"case insensitive", "🙂🙂🙂🙂 MATCH 🙂🙂🙂🙂", []string{"match"}, 9, "...🙂 MATCH 🙂..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateAround(tt.text, tt.patterns, false, tt.width); got != tt.want {
+				t.Errorf("truncateAround(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+			}
+		})
+	}
+
+	// No width cuts a multibyte character apart
+	text := "naïve café 日本語 🙂 token résumé 🙂 ok"
+	for width := 1; width <= utf8.RuneCountInString(text); width++ {
+		got := truncateAround(text, []string{"token"}, false, width)
+		if !utf8.ValidString(got) {
+			t.Errorf("width %d: %q isn't valid UTF-8", width, got)
+		}
+		if n := utf8.RuneCountInString(strings.Trim(got, ".")); n > width {
+			t.Errorf("width %d: %q has %d runes", width, got, n)
+		}
+		if width >= len("token") && !strings.Contains(got, "token") {
+			t.Errorf("width %d: %q doesn't show the match", width, got)
+		}
+	}
+}
+
+func TestBodyExcerpt(t *testing.T) {
+	body := "Retry the Token refresh\nwhen the token expired"
+	tests := []struct {
+		query         string
+		caseSensitive bool
+		excerpt       string
+		start, end    int
+		ok            bool
+	}{
+		{"token", false, "Retry the Token refresh when the token expired", 10, 15, true},
+		{"token", true, "Retry the Token refresh when the token expired", 33, 38, true},
+		{"TOKEN", false, "Retry the Token refresh when the token expired", 10, 15, true},
+		{"TOKEN", true, "", 0, 0, false},
+	}
+	for _, tt := range tests {
+		excerpt, start, end, ok := bodyExcerpt(body, tt.query, snippetContext, tt.caseSensitive)
+		if excerpt != tt.excerpt || start != tt.start || end != tt.end || ok != tt.ok {
+			t.Errorf("bodyExcerpt(%q, case sensitive %v) = %q, %d, %d, %v, want %q, %d, %d, %v",
+				tt.query, tt.caseSensitive, excerpt, start, end, ok, tt.excerpt, tt.start, tt.end, tt.ok)
+		}
+	}
+}
+
+func TestBodySnippetsCase(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Fix Parser crash\n\nThe parser crashed on empty input")
+
+	tests := []struct {
+		args    []string
+		snippet string
+	}{
+		// The subject matches, so there is nothing to add
+		{nil, ""},
+		// Only the body matches the exact case
+		{[]string{"-case-sensitive"}, "The parser crashed on empty input"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			args := append([]string{"-body-snippets"}, append(tt.args, "-query", "parser")...)
+			stdout, stderr, status := r.gst(append([]string{"-format", "json"}, args...)...)
+			if status != exitMatch {
+				t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+			}
+			var results SearchResults
+			if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+				t.Fatal(err)
+			}
+			if len(results.Commits) != 1 || results.Commits[0].Snippet != tt.snippet {
+				t.Errorf("commits = %+v, want the snippet %q", results.Commits, tt.snippet)
+			}
+
+			// Text output shows the same snippet under the commit
+			stdout, _, _ = r.gst(append([]string{"-quiet"}, args...)...)
+			if shown := strings.Contains(stdout, "\n   The **parser** crashed on empty input\n"); shown != (tt.snippet != "") {
+				t.Errorf("snippet shown %v, want %v:\n%s", shown, tt.snippet != "", stdout)
+			}
+		})
+	}
+}
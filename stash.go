@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stashLines is the number of matched lines shown under each stash
+const stashLines = 5
+
+// StashMatch is a stash whose message or, with -diff-search, changes matched
+// a search
+type StashMatch struct {
+	Ref     string `json:"ref"`
+	Message string `json:"message"`
+
+	// Lines are the changed lines that matched, as "path: +line"
+	Lines []string `json:"lines,omitempty"`
+}
+
+// searchInStashes searches the messages of the stash entries and, when
+// withDiffs is set, the lines they add or remove
+func (g *GitSearchTool) searchInStashes(opts SearchOptions, withDiffs bool) ([]StashMatch, error) {
+	opts.MaxResults = opts.limit(g.maxCommits)
+	// Each entry starts with an RS and its ref and message end in US, so
+	// the patch that follows can be told apart
+	args := []string{"stash", "list", logEncoding, "--format=%x1e%gd%x1f%s%x1f"}
+	if withDiffs {
+		args = append(args, "-p")
+	}
+	cmd := g.gitCommand(args...)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stashes: %v", err)
+	}
+
+	var matches []StashMatch
+	for _, record := range strings.Split(toUTF8(string(output)), "\x1e") {
+		parts := strings.SplitN(record, "\x1f", 3)
+		if len(parts) < 3 {
+			continue
+		}
+
+		stash := StashMatch{Ref: parts[0], Message: parts[1]}
+		matched := g.matchesTerms(stash.Message, opts)
+		var file string
+		for _, line := range strings.Split(parts[2], "\n") {
+			if strings.HasPrefix(line, "diff --git ") {
+				file = diffFileName(line)
+				continue
+			}
+			if isDiffContentLine(line) && !strings.HasPrefix(line, " ") && g.matchesTerms(line[1:], opts) {
+				stash.Lines = append(stash.Lines, file+": "+line)
+			}
+		}
+		if !matched && len(stash.Lines) == 0 {
+			continue
+		}
+
+		matches = append(matches, stash)
+		if len(matches) == opts.MaxResults {
+			break
+		}
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchInStashes(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add config", "config.txt", "base\n")
+	r.write("config.txt", "base\nenable feature flag\n")
+	r.git("stash", "push", "-q", "-m", "toggle")
+	r.write("config.txt", "base\nunrelated\n")
+	r.git("stash", "push", "-q", "-m", "feature work")
+	r.write("config.txt", "base\nnothing\n")
+	r.git("stash", "push", "-q", "-m", "misc")
+
+	tests := []struct {
+		name      string
+		withDiffs bool
+		want      []StashMatch
+	}{
+		{"messages", false, []StashMatch{
+			{Ref: "stash@{1}", Message: "On main: feature work"},
+		}},
+		{"messages and changes", true, []StashMatch{
+			{Ref: "stash@{1}", Message: "On main: feature work"},
+			{Ref: "stash@{2}", Message: "On main: toggle", Lines: []string{"config.txt: +enable feature flag"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.tool().searchInStashes(SearchOptions{Query: "feature"}, tt.withDiffs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchInStashes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Without stashes there is nothing to find
+	empty := newTestRepo(t)
+	empty.commit("Initial commit")
+	if got, err := empty.tool().searchInStashes(SearchOptions{Query: "feature"}, true); err != nil || len(got) != 0 {
+		t.Errorf("searchInStashes() without stashes = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commitStat returns the git diff-tree --stat lines of a commit, the changed
+// files and the summary line. Merges are compared with their first parent
+// and root commits with the empty tree, like -commit.
+func (g *GitSearchTool) commitStat(hash string) ([]string, error) {
+	cmd := g.gitCommand("diff-tree", "--stat", "--no-commit-id", "--root", "-m", "--first-parent", hash)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the changes of %s: %v", abbreviateHash(hash), err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(toUTF8(string(output)), "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// addStats fills in the changed files of commits with one git diff-tree
+// each, which is why -with-stat is opt-in
+func (g *GitSearchTool) addStats(commits []CommitMatch) error {
+	for i := range commits {
+		stat, err := g.commitStat(commits[i].Hash)
+		if err != nil {
+			return err
+		}
+		commits[i].Stat = stat
+	}
+	return nil
+}
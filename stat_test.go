@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommitStat(t *testing.T) {
+	r := newTestRepo(t)
+	root := r.commit("Add parser", "parser.go", "package parser\n")
+	second := r.commit("Add lexer", "lexer.go", "package lexer\n\nfunc lex() {}\n", "parser.go", "")
+
+	tests := []struct {
+		hash string
+		want []string
+	}{
+		{root, []string{"parser.go | 1 +", "1 file changed, 1 insertion(+)"}},
+		{second, []string{"lexer.go  | 3 +++", "parser.go | 1 -", "2 files changed, 3 insertions(+), 1 deletion(-)"}},
+	}
+	for _, tt := range tests {
+		got, err := r.tool().commitStat(tt.hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("commitStat(%s) = %q, want %q", tt.hash[:8], got, tt.want)
+		}
+	}
+	if _, err := r.tool().commitStat("0000000000000000000000000000000000000000"); err == nil {
+		t.Error("commitStat() of a missing commit succeeded")
+	}
+}
+
+func TestWithStat(t *testing.T) {
+	r := newTestRepo(t)
+	for i := range 5 {
+		r.commit(fmt.Sprintf("Tune cache %d", i), fmt.Sprintf("cache%d.go", i), "package cache\n")
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		diffTree int
+	}{
+		{"off", nil, 0},
+		{"on", []string{"-with-stat"}, 5},
+		{"bounded by the results shown", []string{"-with-stat", "-max-commits", "2"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-quiet", "-debug-json"}, tt.args...)
+			stdout, stderr, status := r.gst(append(args, "-query", "cache")...)
+			if status != exitMatch {
+				t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+			}
+			calls := 0
+			for _, command := range debugCommands(t, stderr) {
+				if len(command) > 1 && command[1] == "diff-tree" {
+					calls++
+				}
+			}
+			if calls != tt.diffTree {
+				t.Errorf("ran git diff-tree %d times, want %d", calls, tt.diffTree)
+			}
+			if got := strings.Count(stdout, "1 file changed, 1 insertion(+)"); got != tt.diffTree {
+				t.Errorf("%d stat summaries, want %d:\n%s", got, tt.diffTree, stdout)
+			}
+			// The stat follows its own commit
+			if tt.diffTree > 0 && !strings.Contains(stdout, "Tune cache 4 - Test User (2024-01-01)\n   cache4.go | 1 +\n") {
+				t.Errorf("the stat isn't under its commit:\n%s", stdout)
+			}
+		})
+	}
+}
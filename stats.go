@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commitCount returns the number of commits in the searched history
+func (g *GitSearchTool) commitCount() (int, error) {
+	cmd := g.gitCommand(append([]string{"rev-list", "--count"}, g.historyRevs()...)...)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count commits: %v", err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
+// contributorCount returns the number of distinct author names in the
+// searched history
+func (g *GitSearchTool) contributorCount() (int, error) {
+	cmd := g.gitCommand(append([]string{"shortlog", "-sn"}, g.historyRevs()...)...)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count contributors: %v", err)
+	}
+
+	count := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// trackedFileCount returns the number of files tracked in the search path
+func (g *GitSearchTool) trackedFileCount() (int, error) {
+	files, err := g.listMatchingFiles("")
+	return len(files), err
+}
+
+// commitDateRange returns the author dates of the first and last commits of
+// the searched history
+func (g *GitSearchTool) commitDateRange() (first, last time.Time, err error) {
+	cmd := g.gitCommand(append([]string{"log", "--format=%at"}, g.historyRevs()...)...)
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return first, last, fmt.Errorf("failed to read commit dates: %v", err)
+	}
+
+	// Author dates needn't be in commit order after a rebase, so every date
+	// is compared
+	for _, line := range strings.Fields(string(output)) {
+		seconds, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			continue
+		}
+		date := time.Unix(seconds, 0)
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if last.IsZero() || date.After(last) {
+			last = date
+		}
+	}
+	return first, last, nil
+}
+
+// displayStats prints an overview of the repository
+func (g *GitSearchTool) displayStats() {
+	fmt.Println("\n=== Repository Statistics ===")
+
+	files, err := g.trackedFileCount()
+	if err != nil {
+		g.searchErrorf("Error counting files: %v", err)
+		return
+	}
+	if !g.hasCommits() {
+		fmt.Println("No commits yet.")
+		fmt.Printf("Tracked files: %d\n", files)
+		return
+	}
+
+	commits, err := g.commitCount()
+	if err != nil {
+		g.searchErrorf("Error counting commits: %v", err)
+		return
+	}
+	contributors, err := g.contributorCount()
+	if err != nil {
+		g.searchErrorf("Error counting contributors: %v", err)
+		return
+	}
+	first, last, err := g.commitDateRange()
+	if err != nil {
+		g.searchErrorf("Error reading commit dates: %v", err)
+		return
+	}
+
+	fmt.Printf("Commits:       %d\n", commits)
+	fmt.Printf("Contributors:  %d\n", contributors)
+	fmt.Printf("Tracked files: %d\n", files)
+	if commits > 0 {
+		fmt.Printf("First commit:  %s\n", first.Format(time.DateOnly))
+		fmt.Printf("Last commit:   %s\n", last.Format(time.DateOnly))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	r := newTestRepo(t)
+	r.commitEnv(author("Alice Smith", "alice@example.com"), "Add main", "main.go", "package main\n")
+	r.commitEnv(author("Bob Jones", "bob@example.org"), "Add docs", "docs/README.md", "docs\n", "docs/guide.md", "guide\n")
+	r.commitEnv(author("Alice Smith", "alice@example.com"), "Update main", "main.go", "package main\n\nfunc main() {}\n")
+	r.gitEnv(append(author("Carol White", "carol@example.com"),
+		"GIT_AUTHOR_DATE=2024-03-01T12:00:00Z", "GIT_COMMITTER_DATE=2024-03-01T12:00:00Z"),
+		"commit", "-q", "--allow-empty", "-m", "Release")
+	// Untracked files aren't counted
+	r.write("notes.txt", "scratch\n")
+
+	g := r.tool()
+	if commits, err := g.commitCount(); err != nil || commits != 4 {
+		t.Errorf("commitCount() = %d, %v, want 4", commits, err)
+	}
+	if contributors, err := g.contributorCount(); err != nil || contributors != 3 {
+		t.Errorf("contributorCount() = %d, %v, want 3", contributors, err)
+	}
+	if files, err := g.trackedFileCount(); err != nil || files != 3 {
+		t.Errorf("trackedFileCount() = %d, %v, want 3", files, err)
+	}
+	first, last, err := g.commitDateRange()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testEpoch; !first.Equal(want) {
+		t.Errorf("first commit date = %v, want %v", first, want)
+	}
+	if want := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC); !last.Equal(want) {
+		t.Errorf("last commit date = %v, want %v", last, want)
+	}
+
+	stdout, stderr, status := r.gst("-no-banner", "-stats")
+	if status != exitMatch {
+		t.Fatalf("-stats: exit status %d, want %d; stderr:\n%s", status, exitMatch, stderr)
+	}
+	for _, line := range []string{"Commits:       4", "Contributors:  3", "Tracked files: 3", "First commit:  2024-01-01", "Last commit:   2024-03-01"} {
+		if !strings.Contains(stdout, line) {
+			t.Errorf("-stats output is missing %q:\n%s", line, stdout)
+		}
+	}
+}
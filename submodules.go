@@ -0,0 +1,28 @@
+package main
+
+import "strings"
+
+// uninitializedSubmodules lists the paths of the submodules that haven't
+// been checked out, which git grep --recurse-submodules silently skips.
+// Repositories without submodules, or where git can't tell, have none.
+func (g *GitSearchTool) uninitializedSubmodules() []string {
+	cmd := g.gitCommand("submodule", "status")
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return nil
+	}
+
+	// Each line is "<state><hash> <path>[ (<describe>)]", with a "-" state
+	// for submodules that aren't initialized
+	var paths []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasPrefix(line, "-") {
+			continue
+		}
+		if _, path, ok := strings.Cut(line[1:], " "); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRecurseSubmodules(t *testing.T) {
+	lib := newTestRepo(t)
+	lib.commit("Add lib", "lib.go", "func token() {}\n")
+
+	r := newTestRepo(t)
+	r.commit("Add main", "main.go", "token()\n")
+	r.git("-c", "protocol.file.allow=always", "submodule", "add", "-q", lib.dir, "vendor/lib")
+	r.commit("Add lib submodule")
+
+	tests := []struct {
+		recurse bool
+		want    []string
+	}{
+		{false, []string{"main.go"}},
+		{true, []string{"main.go", "vendor/lib/lib.go"}},
+	}
+	for _, tt := range tests {
+		g := r.tool()
+		g.recurseSubmodules = tt.recurse
+		if got := slices.Contains(g.grepArgs(SearchOptions{Query: "token"}), "--recurse-submodules"); got != tt.recurse {
+			t.Errorf("recurseSubmodules=%v: --recurse-submodules in git grep arguments is %v", tt.recurse, got)
+		}
+		matches, err := g.searchInFiles(SearchOptions{Query: "token"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := matchPaths(matches); !slices.Equal(got, tt.want) {
+			t.Errorf("recurseSubmodules=%v: paths = %q, want %q", tt.recurse, got, tt.want)
+		}
+	}
+	if missing := r.tool().uninitializedSubmodules(); len(missing) != 0 {
+		t.Errorf("uninitializedSubmodules() of a checked out submodule = %q", missing)
+	}
+
+	// A clone doesn't check out submodules until asked to
+	clone := &testRepo{t: t, dir: testDir(t)}
+	clone.git("clone", "-q", r.dir, clone.dir)
+	if missing := clone.tool().uninitializedSubmodules(); !slices.Equal(missing, []string{"vendor/lib"}) {
+		t.Errorf("uninitializedSubmodules() of a clone = %q, want [vendor/lib]", missing)
+	}
+	stdout, stderr, status := clone.gst("-quiet", "-head-only", "-recurse-submodules", "-query", "token")
+	if status != exitMatch || stdout != "1. main.go:1:token()\n" {
+		t.Errorf("clone: exit status %d, output %q", status, stdout)
+	}
+	if !strings.Contains(stderr, "submodules vendor/lib aren't checked out") {
+		t.Errorf("stderr is missing the warning about vendor/lib:\n%s", stderr)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// symbol is a definition read from a ctags tags file
+type symbol struct {
+	name string
+	kind string
+	line int
+}
+
+// symbolIndex maps repository relative paths to their definitions sorted by line
+type symbolIndex map[string][]symbol
+
+// ctagsKinds expands the single letter kinds written by ctags
+var ctagsKinds = map[string]string{
+	"c": "class",
+	"d": "macro",
+	"e": "enumerator",
+	"f": "function",
+	"g": "enum",
+	"i": "interface",
+	"m": "member",
+	"n": "namespace",
+	"p": "package",
+	"s": "struct",
+	"t": "type",
+	"v": "variable",
+}
+
+// loadSymbols reads the repository's ctags tags file, returning nil when
+// there is none so callers can carry on without symbol information
+func (g *GitSearchTool) loadSymbols() symbolIndex {
+	for _, name := range []string{"tags", ".tags"} {
+		path := filepath.Join(g.repoPath, name)
+		if _, err := os.Stat(path); err == nil {
+			index, err := g.parseTagsFile(path)
+			if err != nil || len(index) == 0 {
+				return nil
+			}
+			return index
+		}
+	}
+	return nil
+}
+
+// parseTagsFile parses a ctags file in the "name<TAB>file<TAB>address;"<TAB>fields" format
+func (g *GitSearchTool) parseTagsFile(path string) (symbolIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	index := symbolIndex{}
+	fileLines := map[string][]string{}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "!_TAG_") {
+			continue
+		}
+
+		parts := strings.Split(line, "\t")
+		if len(parts) < 3 {
+			continue
+		}
+
+		sym := symbol{name: parts[0]}
+		file := filepath.ToSlash(parts[1])
+		address, fields, _ := strings.Cut(strings.Join(parts[2:], "\t"), ";\"")
+
+		for _, field := range strings.Split(fields, "\t") {
+			if field == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(field, ":")
+			switch {
+			case !ok:
+				sym.kind = field
+			case key == "kind":
+				sym.kind = value
+			case key == "line":
+				sym.line, _ = strconv.Atoi(value)
+			}
+		}
+		if kind, ok := ctagsKinds[sym.kind]; ok {
+			sym.kind = kind
+		}
+
+		if sym.line == 0 {
+			if n, err := strconv.Atoi(address); err == nil {
+				sym.line = n
+			} else {
+				if _, ok := fileLines[file]; !ok {
+					fileLines[file] = readLines(filepath.Join(g.repoPath, file))
+				}
+				sym.line = findTagAddress(fileLines[file], address)
+			}
+		}
+		if sym.line == 0 {
+			continue
+		}
+
+		index[file] = append(index[file], sym)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for file := range index {
+		sort.Slice(index[file], func(i, j int) bool {
+			return index[file][i].line < index[file][j].line
+		})
+	}
+
+	return index, nil
+}
+
+// readLines returns the lines of a file, or nil if it can't be read
+func readLines(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// findTagAddress resolves a /^pattern$/ tag address to a 1-based line number
+func findTagAddress(lines []string, address string) int {
+	if len(address) < 2 || (address[0] != '/' && address[0] != '?') {
+		return 0
+	}
+
+	pattern := address[1 : len(address)-1]
+	anchoredEnd := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimPrefix(pattern, "^")
+	pattern = strings.TrimSuffix(pattern, "$")
+	pattern = strings.ReplaceAll(pattern, `\/`, "/")
+	pattern = strings.ReplaceAll(pattern, `\\`, `\`)
+
+	for i, line := range lines {
+		if anchoredEnd && line == pattern || !anchoredEnd && strings.HasPrefix(line, pattern) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// enclosingSymbol returns the closest definition at or above a line
+func (s symbolIndex) enclosingSymbol(path string, line int) (symbol, bool) {
+	symbols := s[path]
+	i := sort.Search(len(symbols), func(i int) bool {
+		return symbols[i].line > line
+	})
+	if i == 0 {
+		return symbol{}, false
+	}
+	return symbols[i-1], true
+}
+
+// annotation returns the enclosing symbol of a file match as a suffix for
+// its line, or an empty string when there is none
+func (s symbolIndex) annotation(match FileMatch) string {
+	sym, ok := s.enclosingSymbol(match.Path, match.LineNumber)
+	if !ok {
+		return ""
+	}
+	if sym.kind == "" {
+		return "  [" + sym.name + "]"
+	}
+	return "  [" + sym.kind + " " + sym.name + "]"
+}
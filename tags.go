@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// getLastTag returns the most recent tag reachable from HEAD, or an empty
+// string when the repository has no tags
+func (g *GitSearchTool) getLastTag() (string, error) {
+	cmd := g.gitCommand("describe", "--tags", "--abbrev=0")
+
+	output, err := g.run(cmd)
+	if err != nil {
+		// git describe exits with 128 when there is nothing to describe
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 128 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to resolve last tag: %v", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// TagMatch is a tag whose name or annotation matched a search
+type TagMatch struct {
+	Name   string `json:"name"`
+	Tagger string `json:"tagger,omitempty"`
+	Date   string `json:"date,omitempty"`
+
+	// Line is the first annotation line that matched, empty when only the
+	// name did
+	Line string `json:"line,omitempty"`
+}
+
+// searchInTags searches the names of all tags and the messages of annotated
+// tags; lightweight tags have no tagger or message of their own
+func (g *GitSearchTool) searchInTags(opts SearchOptions) ([]TagMatch, error) {
+	opts.MaxResults = opts.limit(g.maxCommits)
+	cmd := g.gitCommand("for-each-ref", "--sort=-creatordate",
+		"--format=%(refname:short)%1f%(objecttype)%1f%(taggername)%1f%(taggerdate:"+g.dateFormat+")%1f%(contents)%1e",
+		"refs/tags")
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tags: %v", err)
+	}
+
+	var matches []TagMatch
+	for _, record := range strings.Split(toUTF8(string(output)), "\x1e") {
+		parts := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(parts) < 5 {
+			continue
+		}
+
+		tag := TagMatch{Name: parts[0]}
+		var message string
+		if parts[1] == "tag" {
+			tag.Tagger, tag.Date, message = parts[2], parts[3], parts[4]
+		}
+		matched := g.matchesTerms(tag.Name, opts)
+		for _, line := range strings.Split(message, "\n") {
+			if line = strings.TrimSpace(line); line != "" && g.matchesTerms(line, opts) {
+				tag.Line = line
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		matches = append(matches, tag)
+		if len(matches) == opts.MaxResults {
+			break
+		}
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchInTags(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Initial commit")
+	r.git("tag", "v0.9")
+	r.git("tag", "hotfix-parser")
+	r.gitEnv([]string{"GIT_COMMITTER_DATE=2024-02-01T12:00:00Z"}, "tag", "-a", "v1.0", "-m", "Release 1.0\n\nIncludes the parser hotfix")
+	r.gitEnv([]string{"GIT_COMMITTER_DATE=2024-03-01T12:00:00Z"}, "tag", "-a", "v2.0", "-m", "Release 2.0")
+
+	tests := []struct {
+		query string
+		want  []TagMatch
+	}{
+		// Newest first: the annotated tags by their tagger date, then the
+		// lightweight ones by the date of their commit
+		{"hotfix", []TagMatch{
+			{Name: "v1.0", Tagger: "Test User", Date: "2024-02-01", Line: "Includes the parser hotfix"},
+			{Name: "hotfix-parser"},
+		}},
+		{"release", []TagMatch{
+			{Name: "v2.0", Tagger: "Test User", Date: "2024-03-01", Line: "Release 2.0"},
+			{Name: "v1.0", Tagger: "Test User", Date: "2024-02-01", Line: "Release 1.0"},
+		}},
+		{"v0.9", []TagMatch{{Name: "v0.9"}}},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := r.tool().searchInTags(SearchOptions{Query: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchInTags() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSinceLastTag(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Fix parser crash")
+	r.commit("Add parser docs")
+
+	// Without tags all history is searched
+	if tag, err := r.tool().getLastTag(); err != nil || tag != "" {
+		t.Errorf("getLastTag() without tags = %q, %v", tag, err)
+	}
+	stdout, stderr, status := r.gst("-since-last-tag", "-query", "parser")
+	if status != exitMatch || !strings.Contains(stdout, "No tags found, searching all history.") ||
+		!strings.Contains(stdout, "Add parser docs") || !strings.Contains(stdout, "Fix parser crash") {
+		t.Errorf("untagged: exit status %d, output:\n%s\nstderr:\n%s", status, stdout, stderr)
+	}
+
+	r.git("tag", "v1.0")
+	r.commit("Tidy parser")
+	r.git("tag", "-a", "v1.1", "-m", "Release 1.1")
+	r.commit("Speed up parser")
+	r.commit("Update changelog")
+
+	if tag, err := r.tool().getLastTag(); err != nil || tag != "v1.1" {
+		t.Errorf("getLastTag() = %q, %v, want v1.1", tag, err)
+	}
+	stdout, stderr, status = r.gst("-quiet", "-since-last-tag", "-query", "parser")
+	if status != exitMatch || !strings.Contains(stdout, "Speed up parser") {
+		t.Errorf("tagged: exit status %d, output:\n%s\nstderr:\n%s", status, stdout, stderr)
+	}
+	for _, old := range []string{"Tidy parser", "Add parser docs", "Fix parser crash"} {
+		if strings.Contains(stdout, old) {
+			t.Errorf("tagged: output has %q from before v1.1:\n%s", old, stdout)
+		}
+	}
+}
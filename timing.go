@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// commandTiming is how long a git command ran
+type commandTiming struct {
+	Args     []string
+	Duration time.Duration
+}
+
+// timeCommand measures cmd from now until the returned function is called,
+// when timing is enabled
+func (g *GitSearchTool) timeCommand(cmd *exec.Cmd) func() {
+	if !g.timing {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		g.recorder.mu.Lock()
+		g.recorder.timings = append(g.recorder.timings, commandTiming{Args: append([]string(nil), cmd.Args...), Duration: elapsed})
+		g.recorder.mu.Unlock()
+	}
+}
+
+// writeTimings writes the duration of each git command and the total
+// running time of gst since start. The command durations may add up to more
+// than the total, as the commit and file searches overlap.
+func (g *GitSearchTool) writeTimings(w io.Writer, start time.Time) {
+	for _, timing := range g.recorder.timings {
+		fmt.Fprintf(w, "%10s  %s\n", timing.Duration.Round(time.Microsecond), shellQuote(timing.Args))
+	}
+	fmt.Fprintf(w, "%10s  total\n", time.Since(start).Round(time.Microsecond))
+}
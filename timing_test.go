@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTiming(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser", "parser.go", "parser\n")
+
+	g := r.tool()
+	g.timing = true
+	if _, err := g.searchInCommitHistory(SearchOptions{Query: "parser"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.searchInFiles(SearchOptions{Query: "parser"}); err != nil {
+		t.Fatal(err)
+	}
+
+	timings := g.recorder.timings
+	if len(timings) != 2 {
+		t.Fatalf("got %d timings, want one per git command: %+v", len(timings), timings)
+	}
+	for i, want := range []string{"log", "grep"} {
+		if len(timings[i].Args) < 2 || timings[i].Args[1] != want {
+			t.Errorf("timing %d is of %q, want git %s", i, timings[i].Args, want)
+		}
+		if timings[i].Duration < 0 {
+			t.Errorf("git %s took %v", want, timings[i].Duration)
+		}
+	}
+
+	var out strings.Builder
+	g.writeTimings(&out, time.Now().Add(-time.Second))
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "  git log ") || !strings.Contains(lines[1], "  git grep ") {
+		t.Fatalf("writeTimings() =\n%s", out.String())
+	}
+	total, err := time.ParseDuration(strings.TrimSpace(strings.TrimSuffix(lines[2], "total")))
+	if err != nil || total < time.Second {
+		t.Errorf("total line %q, want at least 1s: %v", lines[2], err)
+	}
+
+	// Without -timing nothing is recorded
+	g = r.tool()
+	if _, err := g.searchInFiles(SearchOptions{Query: "parser"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.recorder.timings) != 0 {
+		t.Errorf("timings recorded without -timing: %+v", g.recorder.timings)
+	}
+}
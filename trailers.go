@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// trailerFilter keeps the commits with a trailer such as "Signed-off-by:",
+// whose value contains value when hasValue is set
+type trailerFilter struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+// parseTrailerFilter reads a -trailer value, "key" or "key=value"
+func parseTrailerFilter(spec string) (trailerFilter, error) {
+	key, value, hasValue := strings.Cut(spec, "=")
+	key = strings.TrimSuffix(strings.TrimSpace(key), ":")
+	if key == "" || strings.ContainsAny(key, " \t:") {
+		return trailerFilter{}, fmt.Errorf("invalid trailer %q, use key or key=value", spec)
+	}
+	return trailerFilter{key: key, value: strings.TrimSpace(value), hasValue: hasValue}, nil
+}
+
+// matchesTrailers reports whether the trailers of a commit, "Key: value"
+// lines as printed by %(trailers:only,unfold), satisfy every filter. Keys
+// are compared ignoring case like git does, values have to contain the
+// filter's value, ignoring case unless caseSensitive is set.
+func (g *GitSearchTool) matchesTrailers(trailers string, caseSensitive bool) bool {
+	for _, filter := range g.trailers {
+		found := false
+		for _, line := range strings.Split(trailers, "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok || !strings.EqualFold(strings.TrimSpace(key), filter.key) {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if !filter.hasValue || (caseSensitive && strings.Contains(value, filter.value)) ||
+				(!caseSensitive && containsFold(value, filter.value)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
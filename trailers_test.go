@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseTrailerFilter(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    trailerFilter
+		wantErr bool
+	}{
+		{spec: "Signed-off-by", want: trailerFilter{key: "Signed-off-by"}},
+		{spec: "Signed-off-by:", want: trailerFilter{key: "Signed-off-by"}},
+		{spec: "Reviewed-by=alice", want: trailerFilter{key: "Reviewed-by", value: "alice", hasValue: true}},
+		{spec: " Reviewed-by = alice ", want: trailerFilter{key: "Reviewed-by", value: "alice", hasValue: true}},
+		{spec: "Reviewed-by=", want: trailerFilter{key: "Reviewed-by", hasValue: true}},
+		{spec: "", wantErr: true},
+		{spec: "=alice", wantErr: true},
+		{spec: "Reviewed by=alice", wantErr: true},
+		{spec: "Fixes:#12=x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTrailerFilter(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTrailerFilter(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseTrailerFilter(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTrailers(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Fix parser crash\n\nSigned-off-by: Alice Smith <alice@example.com>\nReviewed-by: Bob Jones <bob@example.org>")
+	r.commit("Fix parser leak\n\nSigned-off-by: Carol White <carol@example.com>")
+	r.commit("Fix parser typo")
+	// Only the last paragraph holds trailers
+	r.commit("Fix parser docs\n\nReviewed-by: Bob Jones in the body\n\nmore text")
+
+	tests := []struct {
+		name          string
+		specs         []string
+		caseSensitive bool
+		want          []string
+	}{
+		{"none", nil, false, []string{"Fix parser docs", "Fix parser typo", "Fix parser leak", "Fix parser crash"}},
+		{"key", []string{"Signed-off-by"}, false, []string{"Fix parser leak", "Fix parser crash"}},
+		{"key ignoring case", []string{"signed-off-by"}, true, []string{"Fix parser leak", "Fix parser crash"}},
+		{"value", []string{"Signed-off-by=carol white"}, false, []string{"Fix parser leak"}},
+		{"value with case", []string{"Signed-off-by=carol white"}, true, nil},
+		{"every filter", []string{"Signed-off-by", "Reviewed-by=bob"}, false, []string{"Fix parser crash"}},
+		{"missing key", []string{"Acked-by"}, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := r.tool()
+			for _, spec := range tt.specs {
+				filter, err := parseTrailerFilter(spec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				g.trailers = append(g.trailers, filter)
+			}
+			commits, err := g.searchInCommitHistory(SearchOptions{Query: "parser", CaseSensitive: tt.caseSensitive})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := commitSubjects(commits); !slices.Equal(got, tt.want) {
+				t.Errorf("subjects = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A bad value is reported before searching
+	if _, stderr, status := r.gst("-trailer", "Reviewed by", "-query", "parser"); status != exitError {
+		t.Errorf("invalid -trailer: exit status %d, want %d; stderr:\n%s", status, exitError, stderr)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pathTree is a directory tree of matched files with their match counts
+type pathTree struct {
+	name     string
+	matches  int
+	children map[string]*pathTree
+}
+
+// buildPathTree arranges per-file match counts into a directory tree
+func buildPathTree(counts map[string]int) *pathTree {
+	root := &pathTree{children: map[string]*pathTree{}}
+	for path, count := range counts {
+		node := root
+		for _, part := range strings.Split(path, "/") {
+			child, ok := node.children[part]
+			if !ok {
+				child = &pathTree{name: part, children: map[string]*pathTree{}}
+				node.children[part] = child
+			}
+			child.matches += count
+			node = child
+		}
+		root.matches += count
+	}
+	return root
+}
+
+// TreeNode is a directory or file of the -tree listing in JSON output, with
+// the number of matches below it
+type TreeNode struct {
+	Name     string     `json:"name"`
+	Matches  int        `json:"matches"`
+	Children []TreeNode `json:"children,omitempty"`
+}
+
+// node converts the tree below t for JSON output, children ordered by name
+func (t *pathTree) node() TreeNode {
+	node := TreeNode{Name: t.name, Matches: t.matches}
+	for _, child := range t.sortedChildren() {
+		node.Children = append(node.Children, child.node())
+	}
+	return node
+}
+
+// sortedChildren returns a node's children ordered by name
+func (t *pathTree) sortedChildren() []*pathTree {
+	children := make([]*pathTree, 0, len(t.children))
+	for _, child := range t.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// render writes the tree below t using tree(1) style connectors
+func (t *pathTree) render(sb *strings.Builder, indent string) {
+	children := t.sortedChildren()
+	for i, child := range children {
+		connector, nextIndent := "├── ", "│   "
+		if i == len(children)-1 {
+			connector, nextIndent = "└── ", "    "
+		}
+
+		if len(child.children) > 0 {
+			fmt.Fprintf(sb, "%s%s%s/ (%d)\n", indent, connector, child.name, child.matches)
+			child.render(sb, indent+nextIndent)
+		} else {
+			fmt.Fprintf(sb, "%s%s%s (%d)\n", indent, connector, child.name, child.matches)
+		}
+	}
+}
+
+// displayFileTree prints the files matching a query as a directory tree
+func (g *GitSearchTool) displayFileTree(query string) {
+	counts, err := g.countFileMatches(query)
+	if err != nil {
+		g.searchErrorf("Error searching files: %v", err)
+		return
+	}
+	if len(counts) == 0 {
+		fmt.Println("No matches found in tracked files.")
+		return
+	}
+	g.emitted += len(counts)
+
+	tree := buildPathTree(counts)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s. (%d matches in %d files)\n", g.pathPrefix, tree.matches, len(counts))
+	tree.render(&sb, "")
+	fmt.Print(sb.String())
+}
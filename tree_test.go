@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTreeJSON(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add parser", "src/parser.go", "parser\nparser again\n")
+	r.commit("Add docs", "docs/parser.md", "parser\n")
+
+	stdout, stderr, status := r.gst("-format", "json", "-tree", "-debug-json", "-query", "parser")
+	if status != exitMatch {
+		t.Fatalf("exit status %d; stderr:\n%s", status, stderr)
+	}
+	var results SearchResults
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatalf("parsing output %q: %v", stdout, err)
+	}
+	if results.Tree == nil || results.Tree.Matches != 3 || len(results.Tree.Children) != 2 {
+		t.Errorf("tree = %+v, want 3 matches in docs and src", results.Tree)
+	}
+	if len(results.Files) != 0 {
+		t.Errorf("files = %+v, want none with -tree", results.Files)
+	}
+
+	// The matches are counted by the one grep of -tree, without the file
+	// search of the other modes
+	greps := 0
+	for _, command := range debugCommands(t, stderr) {
+		if len(command) > 1 && command[1] == "grep" {
+			greps++
+		}
+	}
+	if greps != 1 {
+		t.Errorf("ran git grep %d times, want 1; stderr:\n%s", greps, stderr)
+	}
+}
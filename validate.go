@@ -0,0 +1,423 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// modeFlags are flags that replace the normal search with a different report;
+// at most one of them can be used at a time
+var modeFlags = []string{
+	"patch-file", "size-histogram", "top-files", "hotspots", "recent-files",
+	"search-authors", "author-map", "commit-template-check", "stats", "show", "group-by-author", "in-diff", "first-introduced", "coauthors", "serve",
+}
+
+// jsonReports are the modes and displays that also write a JSON document
+// with -format json
+var jsonReports = []string{"size-histogram", "top-files", "hotspots", "recent-files", "search-authors", "tree"}
+
+// historyFlags are flags that need commit history
+var historyFlags = []string{
+	"range", "since-last-tag", "merge-base", "size-histogram", "hotspots", "recent-files",
+	"search-authors", "author-map", "commit-template-check", "ahead-behind",
+	"body-snippets", "ignore-whitespace", "author", "since", "until",
+	"all-branches", "diff-search", "body-only", "blame", "search-tags", "search-stashes", "search-notes", "search-reflog", "reverse", "fuzzy", "no-merges", "merges-only", "first-parent", "depth", "author-regex", "stats", "committer", "date-field", "date-order", "commit-case-sensitive", "show", "follow", "show-renames-in-history", "group-by-author", "trailer", "with-stat", "first-introduced", "coauthors",
+}
+
+// diffFlags are the searches that look at commit diffs
+var diffFlags = []string{"size-histogram", "diff-search"}
+
+// flagChecker answers questions about the flags of a parsed command line
+type flagChecker struct {
+	fs  *flag.FlagSet
+	set map[string]bool
+}
+
+func newFlagChecker(fs *flag.FlagSet) *flagChecker {
+	c := &flagChecker{fs: fs, set: make(map[string]bool)}
+	fs.Visit(func(f *flag.Flag) {
+		c.set[f.Name] = true
+	})
+	return c
+}
+
+// active reports whether a flag was given a non-zero value
+func (c *flagChecker) active(name string) bool {
+	f := c.fs.Lookup(name)
+	if f == nil || !c.set[name] {
+		return false
+	}
+	switch v := f.Value.String(); v {
+	case "", "false", "0":
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *flagChecker) intValue(name string) int {
+	n, _ := strconv.Atoi(c.fs.Lookup(name).Value.String())
+	return n
+}
+
+// activeOf returns the flags from names that are active, formatted for messages
+func (c *flagChecker) activeOf(names []string) []string {
+	var found []string
+	for _, name := range names {
+		if c.active(name) {
+			found = append(found, "-"+name)
+		}
+	}
+	return found
+}
+
+// validateFlags checks a parsed command line, with given the flags set on it
+// and revArgs its revision range arguments, for mutually exclusive or
+// nonsensical flag combinations and returns every problem found. Flags only
+// set by a config file are defaults and don't count as given, so a -threads
+// in ~/.gstrc doesn't fail every search that doesn't use it; their values
+// are still checked.
+func validateFlags(fs *flag.FlagSet, given map[string]bool, revArgs []string) []string {
+	c := &flagChecker{fs: fs, set: given}
+	var problems []string
+	hasQuery := c.active("query") || c.active("expr")
+
+	if modes := c.activeOf(modeFlags); len(modes) > 1 {
+		problems = append(problems, fmt.Sprintf("%s are mutually exclusive", strings.Join(modes, ", ")))
+	}
+
+	scopes := c.activeOf([]string{"range", "since-last-tag", "merge-base", "all-branches"})
+	if len(revArgs) > 0 {
+		scopes = append(scopes, "a revision range")
+	}
+	if len(scopes) > 1 {
+		problems = append(problems, fmt.Sprintf("%s cannot be combined", strings.Join(scopes, ", ")))
+	}
+	if len(revArgs) > 1 {
+		problems = append(problems, fmt.Sprintf("expected at most one revision range argument, got %d (%s); separate paths with --",
+			len(revArgs), strings.Join(revArgs, " ")))
+	}
+
+	if scopes := c.activeOf([]string{"commit", "staged", "modified"}); len(scopes) > 1 {
+		problems = append(problems, fmt.Sprintf("%s are mutually exclusive", strings.Join(scopes, ", ")))
+	}
+	if c.active("at") {
+		if conflicts := c.activeOf([]string{"no-index", "untracked", "staged", "modified", "multi", "in-diff"}); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-at searches a tree instead of the working tree and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if c.active("untracked") && c.active("recurse-submodules") {
+		problems = append(problems, "-untracked and -recurse-submodules are mutually exclusive, git grep supports only one of them")
+	}
+	if c.active("no-merges") && c.active("merges-only") {
+		problems = append(problems, "-no-merges and -merges-only are mutually exclusive")
+	}
+
+	if c.active("multi") {
+		conflicts := c.activeOf(append([]string{"no-index", "repo-root", "patch-file", "commit", "staged", "modified", "untracked",
+			"recurse-submodules", "range", "since-last-tag", "merge-base", "tree", "files-only", "count", "blame",
+			"search-tags", "search-stashes", "search-notes", "search-reflog", "output", "batch"}, modeFlags...))
+		if len(revArgs) > 0 {
+			conflicts = append(conflicts, "a revision range")
+		}
+		if len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-multi cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+		if !hasQuery {
+			problems = append(problems, "-multi requires -query or -expr")
+		}
+	}
+	if c.active("serve") {
+		if conflicts := c.activeOf([]string{"query", "expr", "no-index", "output", "tree", "files-only", "count"}); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-serve takes its queries from requests and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if c.active("show-renames-in-history") && !c.active("follow") {
+		problems = append(problems, "-show-renames-in-history requires -follow")
+	}
+	if c.active("in-diff") && !c.active("query") {
+		problems = append(problems, "-in-diff requires -query")
+	}
+	if c.set["concurrency"] && !c.active("multi") {
+		problems = append(problems, "-concurrency has no effect without -multi")
+	}
+	if c.active("checkpoint") && !c.active("multi") {
+		problems = append(problems, "-checkpoint records the repositories of -multi and has no effect without it")
+	}
+	if c.active("resume-checkpoint") && !c.active("checkpoint") {
+		problems = append(problems, "-resume-checkpoint requires -checkpoint")
+	}
+	if c.active("no-index") {
+		conflicts := c.activeOf(append([]string{"repo-root", "head-only", "patch-file", "commit", "staged", "modified", "untracked", "recurse-submodules", "in-diff"}, historyFlags...))
+		if len(revArgs) > 0 {
+			conflicts = append(conflicts, "a revision range")
+		}
+		if len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-no-index has no history and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+		if !hasQuery {
+			problems = append(problems, "-no-index requires -query or -expr")
+		}
+	}
+	if c.active("head-only") {
+		conflicts := c.activeOf(historyFlags)
+		if len(revArgs) > 0 {
+			conflicts = append(conflicts, "a revision range")
+		}
+		if len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-head-only skips history and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+
+	if c.active("batch") {
+		if conflicts := c.activeOf(append([]string{"query", "expr"}, modeFlags...)); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-batch reads queries from stdin and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if c.active("output") && !hasQuery && !c.active("batch") && len(c.activeOf(modeFlags)) == 0 {
+		problems = append(problems, "-output cannot be used in interactive mode, give -query, -expr or -batch")
+	}
+	if c.set["force"] && !c.active("output") {
+		problems = append(problems, "-force has no effect without -output")
+	}
+	if c.active("patch-file") && !c.active("query") {
+		problems = append(problems, "-patch-file requires -query")
+	}
+	if c.active("search-authors") && !c.active("query") {
+		problems = append(problems, "-search-authors requires -query")
+	}
+	if c.active("top-files") && !hasQuery {
+		problems = append(problems, "-top-files requires -query or -expr")
+	}
+
+	if c.set["noise-threshold"] && !c.active("dim-noise") {
+		problems = append(problems, "-noise-threshold has no effect without -dim-noise")
+	}
+	if c.set["similarity"] && !c.active("author-map") {
+		problems = append(problems, "-similarity has no effect without -author-map")
+	}
+	if f := fs.Lookup("similarity"); f != nil {
+		if v, err := strconv.ParseFloat(f.Value.String(), 64); err == nil && (v <= 0 || v > 1) {
+			problems = append(problems, "-similarity must be greater than 0 and at most 1")
+		}
+	}
+	for _, name := range []string{"commit-template", "check-count"} {
+		if c.set[name] && !c.active("commit-template-check") {
+			problems = append(problems, fmt.Sprintf("-%s has no effect without -commit-template-check", name))
+		}
+	}
+
+	if c.active("ignore-whitespace") && len(c.activeOf(diffFlags)) == 0 {
+		problems = append(problems, fmt.Sprintf("-ignore-whitespace has no effect without one of -%s", strings.Join(diffFlags, ", -")))
+	}
+	if c.set["fuzzy-window"] && !c.active("fuzzy") {
+		problems = append(problems, "-fuzzy-window has no effect without -fuzzy")
+	}
+	if c.active("fuzzy") {
+		if conflicts := c.activeOf([]string{"body-only", "reverse", "depth"}); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-fuzzy ranks subjects by score and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if c.active("files-only") {
+		if conflicts := c.activeOf([]string{"tree", "blame", "symbols", "top-files", "underline", "count"}); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-files-only lists paths and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if c.active("count") {
+		if conflicts := c.activeOf([]string{"tree", "blame", "symbols", "top-files", "underline"}); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-count lists counts per file and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if c.active("invert") {
+		if conflicts := c.activeOf([]string{"body-only", "fuzzy", "search-tags", "search-stashes", "search-notes", "search-reflog", "fallback"}); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-invert cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if c.set["page-size"] && c.active("no-pager") {
+		problems = append(problems, "-page-size has no effect with -no-pager")
+	}
+	if c.active("line-min") && c.active("line-max") && c.intValue("line-min") > c.intValue("line-max") {
+		problems = append(problems, fmt.Sprintf("-line-min %d is after -line-max %d", c.intValue("line-min"), c.intValue("line-max")))
+	}
+	if lines := c.activeOf([]string{"line-min", "line-max"}); len(lines) > 0 {
+		if conflicts := c.activeOf([]string{"tree", "files-only", "count", "top-files"}); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("%s cannot be combined with %s, where matches are counted per file",
+				strings.Join(lines, ", "), strings.Join(conflicts, ", ")))
+		}
+	}
+	if c.set["threads"] && !c.active("parallel-file-chunks") {
+		problems = append(problems, "-threads has no effect without -parallel-file-chunks")
+	}
+
+	for _, name := range []string{"noise-threshold", "check-count", "threads", "max-commits", "max-files", "fuzzy-window", "depth", "page-size", "concurrency"} {
+		if c.set[name] && c.intValue(name) <= 0 {
+			problems = append(problems, fmt.Sprintf("-%s must be positive", name))
+		}
+	}
+	for _, name := range []string{"max-binary-preview", "top-files", "hotspots", "recent-files", "body-lines", "max-results",
+		"min-body-length", "history-size", "line-min", "line-max", "truncate", "retries"} {
+		if c.intValue(name) < 0 {
+			problems = append(problems, fmt.Sprintf("-%s must not be negative", name))
+		}
+	}
+
+	if f := fs.Lookup("timeout"); f != nil && strings.HasPrefix(f.Value.String(), "-") {
+		problems = append(problems, "-timeout must not be negative")
+	}
+	if f := fs.Lookup("date-format"); f != nil && !slices.Contains(dateFormats, f.Value.String()) {
+		problems = append(problems, fmt.Sprintf("unknown -date-format %q (available: %s)", f.Value.String(), strings.Join(dateFormats, ", ")))
+	}
+	if f := fs.Lookup("log-level"); f != nil {
+		if _, ok := parseLogLevel(f.Value.String()); !ok {
+			problems = append(problems, fmt.Sprintf("unknown -log-level %q (available: %s)", f.Value.String(), strings.Join(logLevelNames, ", ")))
+		}
+	}
+	if f := fs.Lookup("max-file-size"); f != nil && f.Value.String() != "" {
+		if _, err := parseSize(f.Value.String()); err != nil {
+			problems = append(problems, fmt.Sprintf("-max-file-size: %v", err))
+		}
+		if conflicts := c.activeOf([]string{"files-only", "count"}); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-max-file-size filters file matches and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if c.active("group") {
+		if f := fs.Lookup("format"); f != nil && f.Value.String() != "text" && (hasQuery || c.active("batch")) {
+			problems = append(problems, "-group only applies to -format text")
+		}
+		if conflicts := c.activeOf([]string{"files-only", "count", "separator", "multi"}); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-group cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if f := fs.Lookup("sort"); f != nil && !slices.Contains(fileSorts, f.Value.String()) {
+		problems = append(problems, fmt.Sprintf("unknown -sort %q (available: %s)", f.Value.String(), strings.Join(fileSorts, ", ")))
+	}
+	if f := fs.Lookup("date-field"); f != nil && f.Value.String() != "author" && f.Value.String() != "committer" {
+		// -date-order is another name for -date-field and shares its value
+		name := "date-field"
+		if c.set["date-order"] {
+			name = "date-order"
+		}
+		problems = append(problems, fmt.Sprintf("unknown -%s %q (available: author, committer)", name, f.Value.String()))
+	}
+	if f := fs.Lookup("match"); f != nil && f.Value.String() != "any" && f.Value.String() != "all" {
+		problems = append(problems, fmt.Sprintf("unknown -match %q (available: any, all)", f.Value.String()))
+	}
+	if f := fs.Lookup("query"); f != nil {
+		if queries, ok := f.Value.(*stringList); ok && len(*queries) > 1 {
+			if conflicts := c.activeOf([]string{"patch-file", "search-authors", "diff-search"}); len(conflicts) > 0 {
+				problems = append(problems, fmt.Sprintf("-query can only be given once with %s", strings.Join(conflicts, ", ")))
+			}
+		}
+	}
+	if f := fs.Lookup("color"); f != nil && !slices.Contains(colorModes, f.Value.String()) {
+		problems = append(problems, fmt.Sprintf("unknown -color %q (available: %s)", f.Value.String(), strings.Join(colorModes, ", ")))
+	}
+	if f := fs.Lookup("format"); f != nil {
+		format := f.Value.String()
+		if !slices.Contains(outputFormats, format) {
+			problems = append(problems, fmt.Sprintf("unknown -format %q (available: %s)", format, strings.Join(outputFormats, ", ")))
+		} else if format != "text" {
+			reports := append(modeFlags, "tree")
+			if format == "json" {
+				reports = slices.DeleteFunc(reports, func(name string) bool { return slices.Contains(jsonReports, name) })
+			}
+			if conflicts := c.activeOf(reports); len(conflicts) > 0 {
+				problems = append(problems, fmt.Sprintf("-format %s only applies to searches and cannot be combined with %s", format, strings.Join(conflicts, ", ")))
+			}
+		}
+	}
+
+	// The format can still be switched to json in interactive mode
+	if f := fs.Lookup("format"); f != nil && f.Value.String() != "json" && c.active("json-pretty") && (hasQuery || c.active("batch")) {
+		problems = append(problems, "-json-pretty only applies to -format json")
+	}
+	if f := fs.Lookup("separator"); f != nil && f.Value.String() != "" {
+		if _, ok := parseSeparator(f.Value.String()); !ok {
+			problems = append(problems, fmt.Sprintf("-separator must be a single character or \\t, got %q", f.Value.String()))
+		}
+		if format := fs.Lookup("format"); c.set["separator"] && format != nil && format.Value.String() != "text" {
+			problems = append(problems, "-separator only applies to -format text")
+		}
+		if conflicts := c.activeOf(append([]string{"files-only", "count", "multi"}, modeFlags...)); c.set["separator"] && len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-separator cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if f := fs.Lookup("format"); f != nil && f.Value.String() == "csv" {
+		if conflicts := c.activeOf([]string{"files-only", "count"}); len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-format csv lists file matches and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+	if f := fs.Lookup("format"); f != nil && f.Value.String() == "jsonl" {
+		conflicts := c.activeOf([]string{"blame", "files-only", "count", "fallback"})
+		if f := fs.Lookup("sort"); c.set["sort"] && f.Value.String() != "none" {
+			conflicts = append(conflicts, "sort")
+		}
+		if len(conflicts) > 0 {
+			problems = append(problems, fmt.Sprintf("-format jsonl streams file matches and cannot be combined with %s", strings.Join(conflicts, ", ")))
+		}
+	}
+
+	// git ORs pathspecs together, so an including filter would widen the
+	// search path rather than narrow it. File name patterns are nested under
+	// including filters, which only works for plain directories.
+	if f := fs.Lookup("path-filter"); f != nil {
+		if filters, ok := f.Value.(*stringList); ok {
+			for _, spec := range *filters {
+				if isExcludePathspec(spec) {
+					continue
+				}
+				if c.active("repo-root") {
+					problems = append(problems, fmt.Sprintf("-path-filter %q cannot be combined with -repo-root, only exclude pathspecs can", spec))
+				}
+				if conflicts := c.activeOf([]string{"ext", "preset", "config-files"}); len(conflicts) > 0 && strings.ContainsAny(spec, ":*?[") {
+					problems = append(problems, fmt.Sprintf("-path-filter %q must be a plain directory to combine with %s", spec, strings.Join(conflicts, ", ")))
+				}
+			}
+		}
+	}
+
+	// git log --follow only works for a single file
+	if f := fs.Lookup("follow"); f != nil {
+		if files, ok := f.Value.(*stringList); ok && len(*files) > 1 {
+			problems = append(problems, fmt.Sprintf("-follow takes a single file, got %d: %s", len(*files), strings.Join(*files, ", ")))
+		}
+	}
+	if f := fs.Lookup("trailer"); f != nil {
+		if specs, ok := f.Value.(*stringList); ok {
+			for _, spec := range *specs {
+				if _, err := parseTrailerFilter(spec); err != nil {
+					problems = append(problems, err.Error())
+				}
+			}
+		}
+	}
+	if f := fs.Lookup("preset"); f != nil {
+		if presets, ok := f.Value.(*stringList); ok {
+			for _, preset := range *presets {
+				if _, ok := filePresets[preset]; !ok {
+					problems = append(problems, fmt.Sprintf("unknown -preset %q, use one of %s", preset, strings.Join(presetNames(), ", ")))
+				}
+			}
+		}
+	}
+
+	if c.active("commit-format") {
+		if _, err := parseCommitFormat(fs.Lookup("commit-format").Value.String()); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid -commit-format: %v", err))
+		}
+	}
+	if c.active("author-regex") && c.active("author") {
+		// git ORs repeated --author patterns
+		problems = append(problems, "-author and -author-regex are mutually exclusive, combine them into one pattern")
+	}
+	if c.active("expr") {
+		if _, err := parseGrepExpr(fs.Lookup("expr").Value.String()); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid -expr: %v", err))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// gitVersion is a git release as major, minor and patch numbers
+type gitVersion [3]int
+
+func (v gitVersion) String() string {
+	if v[2] == 0 {
+		return fmt.Sprintf("%d.%d", v[0], v[1])
+	}
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// atLeast reports whether v is min or a later release
+func (v gitVersion) atLeast(min gitVersion) bool {
+	for i := range v {
+		if v[i] != min[i] {
+			return v[i] > min[i]
+		}
+	}
+	return true
+}
+
+// parseGitVersion reads the release from git --version output such as
+// "git version 2.39.3 (Apple Git-145)" or "git version 2.42.0.windows.1"
+func parseGitVersion(output string) (gitVersion, error) {
+	var v gitVersion
+	fields := strings.Fields(output)
+	if len(fields) < 3 || fields[0] != "git" || fields[1] != "version" {
+		return v, fmt.Errorf("unexpected git --version output %q", strings.TrimSpace(output))
+	}
+
+	parts := strings.Split(fields[2], ".")
+	for i := 0; i < len(v) && i < len(parts); i++ {
+		// Only the leading digits count, as in "0-rc1"
+		digits := parts[i]
+		if j := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); j >= 0 {
+			digits = digits[:j]
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			if i < 2 {
+				return v, fmt.Errorf("unexpected git version %q", fields[2])
+			}
+			break
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+// checkGitVersion runs git --version and keeps the release for
+// supportsFeature
+func (g *GitSearchTool) checkGitVersion() error {
+	cmd := g.gitCommand("--version")
+
+	output, err := g.run(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to run git --version: %v", err)
+	}
+
+	g.version, err = parseGitVersion(string(output))
+	return err
+}
+
+// supportsFeature reports whether the git being run is min or later
+func (g *GitSearchTool) supportsFeature(min gitVersion) bool {
+	return g.version.atLeast(min)
+}
+
+// flagGitVersions are the flags that rely on git options added after the
+// git releases gst otherwise works with
+var flagGitVersions = []struct {
+	name    string
+	version gitVersion
+}{
+	{"invert", gitVersion{2, 4, 0}},              // git log --invert-grep
+	{"blame", gitVersion{1, 8, 4}},               // several git blame -L ranges at once
+	{"recurse-submodules", gitVersion{2, 12, 0}}, // git grep --recurse-submodules
+	{"trailer", gitVersion{2, 15, 0}},            // %(trailers:only,unfold)
+}
+
+// unsupportedFlags returns a problem for every active flag the git being
+// run is too old for
+func (g *GitSearchTool) unsupportedFlags(fs *flag.FlagSet) []string {
+	c := newFlagChecker(fs)
+	var problems []string
+	for _, f := range flagGitVersions {
+		if c.active(f.name) && !g.supportsFeature(f.version) {
+			problems = append(problems, fmt.Sprintf("-%s requires git >= %s, found %s", f.name, f.version, g.version))
+		}
+	}
+	return problems
+}
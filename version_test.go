@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGitVersion(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    gitVersion
+		wantErr bool
+	}{
+		{"git version 2.39.5\n", gitVersion{2, 39, 5}, false},
+		{"git version 2.39.3 (Apple Git-145)\n", gitVersion{2, 39, 3}, false},
+		{"git version 2.42.0.windows.1\n", gitVersion{2, 42, 0}, false},
+		{"git version 2.45.0-rc1", gitVersion{2, 45, 0}, false},
+		{"git version 2.30", gitVersion{2, 30, 0}, false},
+		{"git version 1.8.3.1", gitVersion{1, 8, 3}, false},
+		{"git version x.y", gitVersion{}, true},
+		{"hub version 2.14.2", gitVersion{}, true},
+		{"", gitVersion{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseGitVersion(tt.output)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGitVersion(%q) error = %v, want error %v", tt.output, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseGitVersion(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestGitVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		v, min gitVersion
+		want   bool
+	}{
+		{gitVersion{2, 39, 5}, gitVersion{2, 4, 0}, true},
+		{gitVersion{2, 4, 0}, gitVersion{2, 4, 0}, true},
+		{gitVersion{2, 3, 9}, gitVersion{2, 4, 0}, false},
+		{gitVersion{1, 9, 5}, gitVersion{2, 0, 0}, false},
+		{gitVersion{3, 0, 0}, gitVersion{2, 45, 1}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.v.atLeast(tt.min); got != tt.want {
+			t.Errorf("%v.atLeast(%v) = %v, want %v", tt.v, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestUnsupportedFlags(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Initial commit")
+	old := fakeGit(t, `if [ "$1" = --version ]; then
+	echo "git version 2.3.0"
+	exit 0
+fi
+exec "$realGit" "$@"
+`)
+
+	_, stderr, status := r.gst("-git-bin", old, "-invert", "-query", "ABC-")
+	if status != exitError || !strings.Contains(stderr, "-invert requires git >= 2.4, found 2.3") {
+		t.Errorf("-invert with git 2.3: exit status %d, stderr:\n%s", status, stderr)
+	}
+	if _, stderr, status := r.gst("-git-bin", old, "-quiet", "-query", "Initial"); status != exitMatch {
+		t.Errorf("plain search with git 2.3: exit status %d, stderr:\n%s", status, stderr)
+	}
+}